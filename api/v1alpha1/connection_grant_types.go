@@ -0,0 +1,88 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+
+// ConnectionGrant allows Workflows in other namespaces to consume Connections in the namespace of the ConnectionGrant
+type ConnectionGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	//+required
+	Spec ConnectionGrantSpec `json:"spec"`
+}
+
+// ConnectionGrantSpec defines who may consume which Connections
+type ConnectionGrantSpec struct {
+	// From contains the namespaces, and optionally the service accounts within them, that are granted access
+	// +kubebuilder:validation:MinItems=1
+	From []ConnectionGrantFrom `json:"from"`
+
+	// To contains the Connections in the namespace of the ConnectionGrant that may be consumed
+	// +kubebuilder:validation:MinItems=1
+	To []ConnectionGrantTo `json:"to"`
+}
+
+// ConnectionGrantFrom describes the consumers that are granted access
+type ConnectionGrantFrom struct {
+	// Namespace of the consuming Workflows
+	// +required
+	Namespace string `json:"namespace"`
+
+	// ServiceAccounts restricts the grant to Workflows injected by one of these service accounts.
+	// If empty, every service account in the namespace is granted access.
+	// +optional
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"`
+}
+
+// ConnectionGrantTo describes a Connection that may be consumed
+type ConnectionGrantTo struct {
+	// Name of the Connection
+	// +required
+	Name string `json:"name"`
+}
+
+// Allows returns whether the ConnectionGrant allows the service account in the given namespace to consume the Connection
+func (g *ConnectionGrant) Allows(connection, namespace, serviceAccount string) bool {
+	to := false
+	for _, t := range g.Spec.To {
+		if t.Name == connection {
+			to = true
+			break
+		}
+	}
+	if !to {
+		return false
+	}
+
+	for _, f := range g.Spec.From {
+		if f.Namespace != namespace {
+			continue
+		}
+		if len(f.ServiceAccounts) == 0 {
+			return true
+		}
+		for _, sa := range f.ServiceAccounts {
+			if sa == serviceAccount {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// +kubebuilder:object:root=true
+
+// ConnectionGrantList contains a list of ConnectionGrants
+type ConnectionGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ConnectionGrant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ConnectionGrant{}, &ConnectionGrantList{})
+}
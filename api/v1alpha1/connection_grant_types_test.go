@@ -0,0 +1,30 @@
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ConnectionGrant", func() {
+	grant := ConnectionGrant{
+		Spec: ConnectionGrantSpec{
+			From: []ConnectionGrantFrom{
+				{Namespace: "analytics"},
+				{Namespace: "reporting", ServiceAccounts: []string{"injector"}},
+			},
+			To: []ConnectionGrantTo{{Name: "warehouse"}},
+		},
+	}
+
+	DescribeTable("Allows",
+		func(connection, namespace, serviceAccount string, allowed bool) {
+			Expect(grant.Allows(connection, namespace, serviceAccount)).To(Equal(allowed))
+		},
+		Entry("any service account in a granted namespace", "warehouse", "analytics", "default", true),
+		Entry("a granted service account", "warehouse", "reporting", "injector", true),
+		Entry("a service account that is not granted", "warehouse", "reporting", "default", false),
+		Entry("a namespace that is not granted", "warehouse", "sales", "default", false),
+		Entry("a Connection that is not granted", "crm", "analytics", "default", false),
+	)
+})
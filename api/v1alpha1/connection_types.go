@@ -17,8 +17,11 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"time"
+
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 // ConnectionSpec defines the desired state of Connection
@@ -31,9 +34,113 @@ type ConnectionSpec struct {
 	// All required information to achieve the connection is stored in the credentials
 	//+required
 	Credentials Credentials `json:"credentials"`
+
+	// HealthCheck configures if and when the Connection reconciler checks the health of this Connection.
+	// If omitted, no health checks are performed.
+	// +optional
+	HealthCheck *ConnectionHealthCheck `json:"healthCheck,omitempty"`
+
+	// CredentialIssuer issues short-lived credentials for every Workflow run that injects this Connection.
+	// +optional
+	CredentialIssuer *CredentialIssuer `json:"credentialIssuer,omitempty"`
+}
+
+// FieldNames returns the sorted names of the fields the Connection provides with the fields of its ConnectionType:
+// its Credentials, the fields of its CredentialIssuer and the derived fields
+func (c *Connection) FieldNames(fields []CredentialFieldSpec) []string {
+	var issued []string
+	if c.Spec.CredentialIssuer != nil {
+		issued = c.Spec.CredentialIssuer.Fields
+	}
+	return EffectiveFieldNames(c.Spec.Credentials, issued, fields)
+}
+
+// ExpandedField is a field of a Connection that an InjectableValue with ExpandFields injects as environment variable
+type ExpandedField struct {
+	// Name of the field
+	Name string
+	// EnvKey is the name of the environment variable
+	EnvKey string
+	// Value is the credential the environment variable refers to. It is nil for issued and derived fields
+	// and for credentials read from an external secret backend, which are rendered into the connection secret.
+	Value *Value
+}
+
+// ExpandedFields returns the fields of FieldNames that have an EnvKey in the fields of the ConnectionType
+func (c *Connection) ExpandedFields(fields []CredentialFieldSpec) []ExpandedField {
+	names := sets.NewString(c.FieldNames(fields)...)
+	var expanded []ExpandedField
+	for _, f := range fields {
+		if f.EnvKey == "" || !names.Has(f.Name) {
+			continue
+		}
+		ef := ExpandedField{Name: f.Name, EnvKey: f.EnvKey}
+		if v, ok := c.Spec.Credentials[f.Name]; ok && (v.ValueFrom == nil || v.ValueFrom.ExternalRef == nil) {
+			ef.Value = v.DeepCopy()
+		}
+		expanded = append(expanded, ef)
+	}
+	return expanded
+}
+
+// HealthCheckMode defines when the health of a Connection is checked.
+// +kubebuilder:validation:Enum=Interval;OnUse;Disabled
+type HealthCheckMode string
+
+const (
+	// HealthCheckInterval performs a health check periodically.
+	HealthCheckInterval HealthCheckMode = "Interval"
+
+	// HealthCheckOnUse performs a health check whenever a Workflow using the Connection changes.
+	HealthCheckOnUse HealthCheckMode = "OnUse"
+
+	// HealthCheckDisabled disables health checks.
+	HealthCheckDisabled HealthCheckMode = "Disabled"
+)
+
+// ConnectionConditionValuesValid indicates whether the values read from Secrets and ConfigMaps
+// pass the validation of their ConnectionType fields. It is only set if the ConnectionType enables DeepValidation.
+const ConnectionConditionValuesValid = "ValuesValid"
+
+// DefaultHealthCheckInterval is the interval used when an Interval health check does not specify one.
+const DefaultHealthCheckInterval = 5 * time.Minute
+
+// ConnectionHealthCheck defines how the health of a Connection is determined.
+type ConnectionHealthCheck struct {
+	// Mode defines when the health check is performed. Defaults to Interval.
+	// +optional
+	Mode HealthCheckMode `json:"mode,omitempty"`
+
+	// Interval between two health checks if Mode is Interval. Defaults to 5m.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// Workflow is a reference to a Workflow that probes the Connection.
+	// The latest run of the Workflow is used as an indication of the Connection health.
+	// If omitted, the built-in probe is used, which verifies that every credential
+	// of the Connection can be resolved.
+	// +optional
+	Workflow *WorkflowReference `json:"workflow,omitempty"`
+}
+
+// GetMode returns the configured HealthCheckMode, defaulting to HealthCheckInterval.
+func (hc *ConnectionHealthCheck) GetMode() HealthCheckMode {
+	if hc.Mode == "" {
+		return HealthCheckInterval
+	}
+	return hc.Mode
+}
+
+// GetInterval returns the configured health check interval, defaulting to DefaultHealthCheckInterval.
+func (hc *ConnectionHealthCheck) GetInterval() time.Duration {
+	if hc.Interval == nil || hc.Interval.Duration <= 0 {
+		return DefaultHealthCheckInterval
+	}
+	return hc.Interval.Duration
 }
 
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
 
 // ConnectionType defines the structure, validation and behavior of a connection
 type ConnectionType struct {
@@ -41,7 +148,8 @@ type ConnectionType struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
 	//+required
-	Spec ConnectionTypeSpec `json:"spec,omitempty"`
+	Spec   ConnectionTypeSpec `json:"spec,omitempty"`
+	Status TypeStatus         `json:"status,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -62,6 +170,48 @@ type ConnectionTypeSpec struct {
 	// Allow extra fields to be submitted that do not match any CredentialField
 	//+optional
 	AllowExtraFields bool `json:"allowExtraFields,omitempty"`
+
+	// DeepValidation enables the validation of values that are read from a Secret or ConfigMap.
+	// The Connection reconciler resolves these values and validates them against the Validation of their field.
+	// The result is reported in the ValuesValid condition of the Connection, which never contains the values themselves.
+	//+optional
+	DeepValidation bool `json:"deepValidation,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+
+// ClusterConnectionType is a ConnectionType that is available in every namespace.
+// A ConnectionType with the same name in the namespace of a Connection takes precedence.
+type ClusterConnectionType struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	//+required
+	Spec   ConnectionTypeSpec `json:"spec,omitempty"`
+	Status TypeStatus         `json:"status,omitempty"`
+}
+
+// ConnectionType returns the ClusterConnectionType as a ConnectionType without a namespace
+func (c *ClusterConnectionType) ConnectionType() *ConnectionType {
+	return &ConnectionType{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: GroupVersion.String(),
+			Kind:       "ConnectionType",
+		},
+		ObjectMeta: *c.ObjectMeta.DeepCopy(),
+		Spec:       *c.Spec.DeepCopy(),
+	}
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterConnectionTypeList contains a list of ClusterConnectionTypes
+type ClusterConnectionTypeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterConnectionType `json:"items"`
 }
 
 type ConnectionRef struct {
@@ -73,9 +223,30 @@ type ConnectionRef struct {
 }
 
 // ConnectionStatus defines the observed state of Connection
-type ConnectionStatus struct{}
+type ConnectionStatus struct {
+	// Healthy indicates the status of the most recent Connection health check.
+	// +optional
+	Healthy HealthEnum `json:"healthy,omitempty"`
+
+	// LastCheckTime is the time the most recent health check was performed.
+	// +optional
+	LastCheckTime *metav1.Time `json:"lastCheckTime,omitempty"`
+
+	// Message contains a human readable explanation of the health check result.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Conditions contains the latest observations of the Connection state
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Usage lists the resources that use the Connection
+	// +optional
+	Usage *Usage `json:"usage,omitempty"`
+}
 
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
 
 // Connection is the Schema for the connections API
 type Connection struct {
@@ -96,5 +267,5 @@ type ConnectionList struct {
 }
 
 func init() {
-	SchemeBuilder.Register(&Connection{}, &ConnectionList{}, &ConnectionType{}, &ConnectionTypeList{})
+	SchemeBuilder.Register(&Connection{}, &ConnectionList{}, &ConnectionType{}, &ConnectionTypeList{}, &ClusterConnectionType{}, &ClusterConnectionTypeList{})
 }
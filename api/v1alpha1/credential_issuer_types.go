@@ -0,0 +1,129 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CredentialLeasesAnnotation is the annotation of a connection secret that records the credentials issued for it
+const CredentialLeasesAnnotation = "etl.dataworkz.nl/credential-leases"
+
+// DefaultCredentialTTL is the lifetime of issued credentials if neither the Argo Workflow
+// nor the CredentialIssuer limits it
+const DefaultCredentialTTL = time.Hour
+
+// CredentialIssuer issues short-lived credentials for every run of a Workflow that injects the Connection.
+// The issued values are injected next to the Credentials and revoked when the Argo Workflow completes.
+// Exactly one of Vault or Webhook must be set.
+type CredentialIssuer struct {
+	// Fields are the credential fields the issuer provides, e.g. username and password.
+	// They cannot be set in the Credentials of the Connection.
+	//+required
+	Fields []string `json:"fields"`
+
+	// Vault issues credentials from a HashiCorp Vault database secrets engine,
+	// using the Vault server configured as secret backend
+	// +optional
+	Vault *VaultCredentialIssuer `json:"vault,omitempty"`
+
+	// Webhook issues credentials by calling an HTTP endpoint
+	// +optional
+	Webhook *WebhookCredentialIssuer `json:"webhook,omitempty"`
+
+	// DefaultTTL is the lifetime of credentials issued for Argo Workflows without activeDeadlineSeconds.
+	// Defaults to 1h.
+	// +optional
+	DefaultTTL *metav1.Duration `json:"defaultTTL,omitempty"`
+}
+
+// VaultCredentialIssuer issues credentials from a HashiCorp Vault database secrets engine
+type VaultCredentialIssuer struct {
+	// Mount is the path the database secrets engine is mounted at. Defaults to database.
+	// +optional
+	Mount string `json:"mount,omitempty"`
+
+	// Role is the database role to issue credentials for
+	//+required
+	Role string `json:"role"`
+}
+
+// WebhookCredentialIssuer issues credentials by calling an HTTP endpoint.
+// The endpoint receives a POST request with a JSON body containing the action (issue or revoke),
+// the namespace and name of the Connection, the ttlSeconds of the credentials and, when revoking, the leaseID.
+// When issuing, it responds with the leaseID, the ttlSeconds it granted and the credentials.
+type WebhookCredentialIssuer struct {
+	// URL of the endpoint
+	//+required
+	URL string `json:"url"`
+
+	// TokenSecretRef selects a key of a Secret in the namespace of the Connection
+	// that is sent to the endpoint as bearer token
+	// +optional
+	TokenSecretRef *corev1.SecretKeySelector `json:"tokenSecretRef,omitempty"`
+}
+
+// IssuedCredentialsMessage explains why a WorkflowTemplate cannot inject the Connection that issues credentials:
+// its connection secret is shared by all the runs of the template, while issued credentials are revoked after a run
+func IssuedCredentialsMessage(connection string) string {
+	return fmt.Sprintf("Connection %s issues credentials per run, which WorkflowTemplates do not support", connection)
+}
+
+// TTL returns the lifetime of credentials issued for the WorkflowSpec,
+// which matches the activeDeadlineSeconds of its Argo Workflow
+func (ci *CredentialIssuer) TTL(wfs *WorkflowSpec) time.Duration {
+	if deadline := wfs.ArgoWorkflowSpec.ActiveDeadlineSeconds; deadline != nil && *deadline > 0 {
+		return time.Duration(*deadline) * time.Second
+	}
+	if ci.DefaultTTL != nil {
+		return ci.DefaultTTL.Duration
+	}
+	return DefaultCredentialTTL
+}
+
+// CredentialLease records credentials issued for a connection secret, so they can be revoked when the run completes
+type CredentialLease struct {
+	// Namespace of the Connection that issued the credentials
+	Namespace string `json:"namespace"`
+	// Name of the Connection that issued the credentials
+	Name string `json:"name"`
+	// LeaseID identifies the credentials in the issuer
+	LeaseID string `json:"leaseID"`
+	// ExpiresAt is the time after which the issuer revokes the credentials itself
+	ExpiresAt metav1.Time `json:"expiresAt"`
+}
+
+// GetCredentialLeases returns the CredentialLeases recorded on the connection secret
+func GetCredentialLeases(secret *corev1.Secret) ([]CredentialLease, error) {
+	data, ok := secret.Annotations[CredentialLeasesAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	var leases []CredentialLease
+	if err := json.Unmarshal([]byte(data), &leases); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation on secret %s: %w", CredentialLeasesAnnotation, secret.Name, err)
+	}
+	return leases, nil
+}
+
+// SetCredentialLeases records the CredentialLeases on the connection secret and removes the record if there are none
+func SetCredentialLeases(secret *corev1.Secret, leases []CredentialLease) error {
+	if len(leases) == 0 {
+		delete(secret.Annotations, CredentialLeasesAnnotation)
+		return nil
+	}
+
+	data, err := json.Marshal(leases)
+	if err != nil {
+		return err
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	secret.Annotations[CredentialLeasesAnnotation] = string(data)
+	return nil
+}
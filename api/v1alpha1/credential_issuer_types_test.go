@@ -0,0 +1,44 @@
+package v1alpha1
+
+import (
+	"time"
+
+	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+var _ = Describe("CredentialIssuer", func() {
+	DescribeTable("TTL",
+		func(deadline *int64, defaultTTL *metav1.Duration, expected time.Duration) {
+			ci := CredentialIssuer{DefaultTTL: defaultTTL}
+			wfs := WorkflowSpec{ArgoWorkflowSpec: wfv1.WorkflowSpec{ActiveDeadlineSeconds: deadline}}
+			Expect(ci.TTL(&wfs)).To(Equal(expected))
+		},
+		Entry("the active deadline of the Argo Workflow", pointer.Int64Ptr(600), &metav1.Duration{Duration: time.Minute}, 10*time.Minute),
+		Entry("the default TTL without an active deadline", nil, &metav1.Duration{Duration: time.Minute}, time.Minute),
+		Entry("one hour without either", nil, nil, DefaultCredentialTTL),
+	)
+
+	It("Should record CredentialLeases on a connection secret", func() {
+		secret := corev1.Secret{}
+		leases, err := GetCredentialLeases(&secret)
+		Expect(err).To(Succeed())
+		Expect(leases).To(BeEmpty())
+
+		expiresAt := metav1.NewTime(time.Now().Add(time.Hour).Truncate(time.Second))
+		Expect(SetCredentialLeases(&secret, []CredentialLease{{Namespace: "default", Name: "mysql", LeaseID: "lease-1", ExpiresAt: expiresAt}})).To(Succeed())
+		leases, err = GetCredentialLeases(&secret)
+		Expect(err).To(Succeed())
+		Expect(leases).To(HaveLen(1))
+		Expect(leases[0].LeaseID).To(Equal("lease-1"))
+		Expect(leases[0].ExpiresAt.Equal(&expiresAt)).To(BeTrue())
+
+		Expect(SetCredentialLeases(&secret, nil)).To(Succeed())
+		Expect(secret.Annotations).ToNot(HaveKey(CredentialLeasesAnnotation))
+	})
+})
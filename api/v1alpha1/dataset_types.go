@@ -70,6 +70,14 @@ type DataSetStatus struct {
 	// Healthy indicates the status of the recent DataSet health check.
 	// +optional
 	Healthy HealthEnum `json:"healthy,omitempty"`
+
+	// Usage lists the resources that use the DataSet
+	// +optional
+	Usage *Usage `json:"usage,omitempty"`
+
+	// Conditions contains the latest observations of the DataSet state
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -94,6 +102,7 @@ type DataSetList struct {
 }
 
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
 
 // DataSetType defines the structure of a DataSet
 type DataSetType struct {
@@ -101,7 +110,8 @@ type DataSetType struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
 	//+required
-	Spec DataSetTypeSpec `json:"spec,omitempty"`
+	Spec   DataSetTypeSpec `json:"spec,omitempty"`
+	Status TypeStatus      `json:"status,omitempty"`
 }
 
 type DataSetTypeSpec struct {
@@ -118,6 +128,42 @@ type DataSetTypeList struct {
 	Items           []DataSetType `json:"items"`
 }
 
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+
+// ClusterDataSetType is a DataSetType that is available in every namespace.
+// A DataSetType with the same name in the namespace of a DataSet takes precedence.
+type ClusterDataSetType struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	//+required
+	Spec   DataSetTypeSpec `json:"spec,omitempty"`
+	Status TypeStatus      `json:"status,omitempty"`
+}
+
+// DataSetType returns the ClusterDataSetType as a DataSetType without a namespace
+func (c *ClusterDataSetType) DataSetType() *DataSetType {
+	return &DataSetType{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: GroupVersion.String(),
+			Kind:       "DataSetType",
+		},
+		ObjectMeta: *c.ObjectMeta.DeepCopy(),
+		Spec:       *c.Spec.DeepCopy(),
+	}
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterDataSetTypeList contains a list of ClusterDataSetTypes
+type ClusterDataSetTypeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterDataSetType `json:"items"`
+}
+
 func init() {
-	SchemeBuilder.Register(&DataSet{}, &DataSetList{}, &DataSetType{}, &DataSetTypeList{})
+	SchemeBuilder.Register(&DataSet{}, &DataSetList{}, &DataSetType{}, &DataSetTypeList{}, &ClusterDataSetType{}, &ClusterDataSetTypeList{})
 }
@@ -1,10 +1,33 @@
 package v1alpha1
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
 	apiv1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
+// InUseProtectionFinalizer blocks the deletion of Connections, DataSets, (Cluster)ConnectionTypes and (Cluster)DataSetTypes
+// as long as other resources refer to them
+const InUseProtectionFinalizer = "etl.dataworkz.nl/in-use-protection"
+
+// ConditionDeletionBlocked indicates that the InUseProtectionFinalizer blocks the deletion of the resource,
+// its message lists the resources that refer to it
+const ConditionDeletionBlocked = "DeletionBlocked"
+
+// TypeStatus defines the observed state of (Cluster)ConnectionTypes and (Cluster)DataSetTypes
+type TypeStatus struct {
+	// Conditions contains the latest observations of the type state
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
 type MetadataValidation struct {
 	// List of fields specified for validation.
 	//+optional
@@ -31,8 +54,8 @@ type CredentialFieldSpec struct {
 	Required bool `json:"required"`
 
 	// Whether or not this field is sensitive.
-	// If a field is sensitive, the only valid ValueSource
-	// is a SecretKeyRef. Plain text values and ConfigMapKeyRefs
+	// If a field is sensitive, the only valid ValueSources
+	// are a SecretKeyRef and an ExternalRef. Plain text values and ConfigMapKeyRefs
 	// are not allowed.
 	//+optional
 	Sensitive bool `json:"sensitive"`
@@ -40,6 +63,135 @@ type CredentialFieldSpec struct {
 	// Optional methods of validating the field's value
 	//+optional
 	Validation *Validation `json:"validation,omitempty"`
+
+	// Default is the value of the field if it is not set.
+	// Defaults are applied when a Connection or DataSet is created or updated.
+	// Sensitive fields cannot have a default, as it would be stored as plain text value.
+	//+optional
+	Default string `json:"default,omitempty"`
+
+	// Derived is a Go template that computes the value of the field from the other fields,
+	// e.g. jdbc:mysql://{{.host}}:{{.port}}/{{.database}}. Derived fields are computed when a Connection
+	// or DataSet is created or updated if they only use fields with a plain text value. Otherwise they are
+	// computed when the values are injected, so they may use sensitive fields. Derived fields that use a field
+	// that is not set are left out. Derived fields cannot be set on a Connection or DataSet.
+	//+optional
+	Derived ContentTemplate `json:"derived,omitempty"`
+}
+
+// HasDefault returns true if the Default of the field is applied when it is not set
+func (f CredentialFieldSpec) HasDefault() bool {
+	return f.Default != "" && f.Derived == "" && !f.Sensitive
+}
+
+// ApplyDefaults sets the Default of every field that is not set in the Credentials.
+// It returns true if any default has been applied.
+func (c *Credentials) ApplyDefaults(fields []CredentialFieldSpec) bool {
+	applied := false
+	for _, f := range fields {
+		if !f.HasDefault() {
+			continue
+		}
+		if _, ok := (*c)[f.Name]; ok {
+			continue
+		}
+		if *c == nil {
+			*c = make(Credentials)
+		}
+		(*c)[f.Name] = Value{Value: f.Default}
+		applied = true
+	}
+	return applied
+}
+
+// ApplyDerived sets the derived fields that only use fields with a plain text value to their computed value.
+// Derived fields that use values read from a Secret, ConfigMap or external secret backend, and sensitive
+// derived fields, are removed, as they are computed when the values are injected.
+// It returns true if the Credentials changed.
+func (c *Credentials) ApplyDerived(fields []CredentialFieldSpec) bool {
+	derived := make(map[string]bool)
+	for _, f := range fields {
+		if f.Derived != "" {
+			derived[f.Name] = true
+		}
+	}
+	values := make(map[string]string, len(*c))
+	for name, v := range *c {
+		if v.ValueFrom == nil && !derived[name] {
+			values[name] = v.Value
+		}
+	}
+
+	changed := false
+	for _, f := range fields {
+		if f.Derived == "" {
+			continue
+		}
+		existing, ok := (*c)[f.Name]
+		value, err := f.Derived.Render(values)
+		if err != nil || value == "" || f.Sensitive {
+			if ok {
+				delete(*c, f.Name)
+				changed = true
+			}
+			continue
+		}
+		values[f.Name] = value
+		if ok && existing.ValueFrom == nil && existing.Value == value {
+			continue
+		}
+		if *c == nil {
+			*c = make(Credentials)
+		}
+		(*c)[f.Name] = Value{Value: value}
+		changed = true
+	}
+	return changed
+}
+
+// EffectiveFieldNames returns the sorted names of the fields provided by the Credentials, the issued fields
+// and the derived fields. Those are the keys Content is rendered with and the fields that are expanded.
+func EffectiveFieldNames(c Credentials, issued []string, fields []CredentialFieldSpec) []string {
+	names := sets.NewString(issued...)
+	for name := range c {
+		names.Insert(name)
+	}
+	for _, f := range fields {
+		if f.Derived != "" {
+			names.Insert(f.Name)
+		}
+	}
+	return names.List()
+}
+
+// DeriveValues adds the values of the derived fields to the values. Each derived field is rendered
+// with the values of the other fields, including the derived fields that precede it.
+// Like ApplyDerived, a derived field that refers to a field that is not set is left out.
+func DeriveValues(fields []CredentialFieldSpec, values map[string]string) error {
+	for _, f := range fields {
+		if f.Derived == "" {
+			continue
+		}
+		value, err := f.Derived.Render(values)
+		if err != nil && missingInputs(f.Derived, values) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to derive field %s: %w", f.Name, err)
+		}
+		values[f.Name] = value
+	}
+	return nil
+}
+
+// missingInputs returns true if the template only fails to render with the values because it refers to
+// keys that are missing from them, which it does if it renders once missing keys are treated as empty
+func missingInputs(ct ContentTemplate, values map[string]string) bool {
+	tmpl, err := ct.Parse()
+	if err != nil {
+		return false
+	}
+	return tmpl.Option("missingkey=zero").Execute(ioutil.Discard, values) == nil
 }
 
 // Contains optional properties used in validating CredentialFields
@@ -55,11 +207,70 @@ type Validation struct {
 	// A regex pattern, must conform to RE2 syntax
 	//+optional
 	Regex *ValidationRegex `json:"regex,omitempty"`
+
+	// Enum contains the allowed values
+	//+optional
+	Enum []string `json:"enum,omitempty"`
+
+	// Format the value must have
+	//+optional
+	Format ValueFormat `json:"format,omitempty"`
+
+	// Minimum is the lowest allowed value. A value with a Minimum must be a number.
+	//+optional
+	Minimum *ValidationNumber `json:"minimum,omitempty"`
+
+	// Maximum is the highest allowed value. A value with a Maximum must be a number.
+	//+optional
+	Maximum *ValidationNumber `json:"maximum,omitempty"`
+
+	// Schemes contains the schemes allowed in a URL value, such as https.
+	// If empty, every scheme is allowed.
+	//+optional
+	Schemes []string `json:"schemes,omitempty"`
+
+	// JSONSchema is a JSON Schema fragment the value must conform to.
+	// The value is decoded as JSON, unless the schema has type string.
+	// The fragment uses the OpenAPI v3 schema of CustomResourceDefinitions, without $ref, and is
+	// validated when the type is created or updated.
+	//+optional
+	JSONSchema *apiextensionsv1.JSON `json:"jsonSchema,omitempty"`
 }
 
 // ValidationRegex contains a regex pattern conforming to RE2 syntax
 type ValidationRegex string
 
+// ValidationNumber contains a decimal number, such as 10, -1 or 0.5
+// +kubebuilder:validation:Pattern=`^-?[0-9]+(\.[0-9]+)?$`
+type ValidationNumber string
+
+// ValueFormat defines the format of a value
+// +kubebuilder:validation:Enum=Integer;Float;Boolean;URL;Hostname;Port;Duration
+type ValueFormat string
+
+const (
+	// ValueFormatInteger is a whole number, such as 10
+	ValueFormatInteger ValueFormat = "Integer"
+
+	// ValueFormatFloat is a decimal number, such as 0.5
+	ValueFormatFloat ValueFormat = "Float"
+
+	// ValueFormatBoolean is either true or false
+	ValueFormatBoolean ValueFormat = "Boolean"
+
+	// ValueFormatURL is an absolute URL, such as https://example.com
+	ValueFormatURL ValueFormat = "URL"
+
+	// ValueFormatHostname is an RFC 1123 hostname or an IP address
+	ValueFormatHostname ValueFormat = "Hostname"
+
+	// ValueFormatPort is a port number between 1 and 65535
+	ValueFormatPort ValueFormat = "Port"
+
+	// ValueFormatDuration is a Go duration, such as 5m or 1h30m
+	ValueFormatDuration ValueFormat = "Duration"
+)
+
 // Value contains either a direct value or a value from a source
 type Value struct {
 	// +optional
@@ -69,7 +280,7 @@ type Value struct {
 	ValueFrom *ValueSource `json:"valueFrom,omitempty"`
 }
 
-// ValueSource holds a reference to either a ConfigMap or a Secret
+// ValueSource holds a reference to either a ConfigMap, a Secret or a secret in an external secret backend
 type ValueSource struct {
 	// Select at least one
 
@@ -79,6 +290,89 @@ type ValueSource struct {
 	// Selects a key of a secret in the pod's namespace
 	// +optional
 	SecretKeyRef *apiv1.SecretKeySelector `json:"secretKeyRef,omitempty" protobuf:"bytes,4,opt,name=secretKeyRef"`
+	// Selects a key of a secret stored in an external secret backend,
+	// so the value does not have to be copied into a Kubernetes Secret
+	// +optional
+	ExternalRef *ExternalSecretKeySelector `json:"externalRef,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Vault;File
+type SecretBackendType string
+
+const (
+	// SecretBackendVault reads secrets from a HashiCorp Vault KV secrets engine
+	SecretBackendVault SecretBackendType = "Vault"
+	// SecretBackendFile reads secrets from files, e.g. mounted by a secrets store CSI driver or a Vault agent
+	SecretBackendFile SecretBackendType = "File"
+)
+
+// ExternalSecretKeySelector selects a key of a secret stored outside of Kubernetes
+type ExternalSecretKeySelector struct {
+	// Backend is the secret backend that stores the secret.
+	// The backend must be configured in the manager and, in InjectionModeDaemon, in the injection container
+	// with the SecretBackendConfig of its InjectionTemplate.
+	//+required
+	Backend SecretBackendType `json:"backend"`
+	// Path of the secret in the backend, relative to the directory of the namespace of the Connection or DataSet.
+	// For Vault the secret is read from <mount>/<namespace>/<path>, for File from the directory <root>/<namespace>/<path>.
+	// The path may not be absolute or refer to a parent directory.
+	//+required
+	Path string `json:"path"`
+	// Key of the value in the secret
+	//+required
+	Key string `json:"key"`
+}
+
+// ValidateExternalPath returns an error if the path of an ExternalSecretKeySelector is absolute, refers to
+// a parent directory, has empty segments or contains characters a backend could interpret, as it could then
+// escape the directory of its namespace in the backend
+func ValidateExternalPath(path string) error {
+	if strings.HasPrefix(path, "/") {
+		return errors.New("path must be relative")
+	}
+	if strings.ContainsAny(path, `%?#\`) {
+		return errors.New(`path must not contain %, ?, # or \`)
+	}
+	for _, segment := range strings.Split(strings.TrimSuffix(path, "/"), "/") {
+		switch segment {
+		case "", ".":
+			return errors.New("path must not contain empty or . segments")
+		case "..":
+			return errors.New("path must not refer to a parent directory")
+		}
+	}
+	return nil
+}
+
+// ValidateExternalFileKey returns an error if the key of an ExternalSecretKeySelector is not the name of a file,
+// which the File secret backend requires
+func ValidateExternalFileKey(key string) error {
+	if key == "." || key == ".." || strings.ContainsAny(key, `/\`) {
+		return errors.New("key must be a file name")
+	}
+	return nil
+}
+
+// SecretNames returns the names of the Secrets referenced by the Credentials
+func (c Credentials) SecretNames() []string {
+	var names []string
+	for _, v := range c {
+		if v.ValueFrom != nil && v.ValueFrom.SecretKeyRef != nil {
+			names = append(names, v.ValueFrom.SecretKeyRef.Name)
+		}
+	}
+	return names
+}
+
+// ConfigMapNames returns the names of the ConfigMaps referenced by the Credentials
+func (c Credentials) ConfigMapNames() []string {
+	var names []string
+	for _, v := range c {
+		if v.ValueFrom != nil && v.ValueFrom.ConfigMapKeyRef != nil {
+			names = append(names, v.ValueFrom.ConfigMapKeyRef.Name)
+		}
+	}
+	return names
 }
 
 // +kubebuilder:validation:Enum=Healthy;Unhealthy;Unknown
@@ -97,6 +391,27 @@ const (
 	Unknown HealthEnum = "Unknown"
 )
 
+// ConnectionReference holds a reference to a v1alpha1.Connection, which may be in another namespace
+type ConnectionReference struct {
+	// `name` is the name of the Connection.
+	Name string `json:"name,omitempty"`
+	// `namespace` is the namespace of the Connection. Defaults to the namespace of the referring resource.
+	// A Connection in another namespace may only be referred to if a ConnectionGrant in that namespace allows it.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// GetNamespacedName returns the name of the Connection, using the given namespace if the reference has none
+func (r *ConnectionReference) GetNamespacedName(namespace string) types.NamespacedName {
+	if r.Namespace != "" {
+		namespace = r.Namespace
+	}
+	return types.NamespacedName{
+		Name:      r.Name,
+		Namespace: namespace,
+	}
+}
+
 // WorkflowReference holds a reference to a v1alpha1.Workflow
 type WorkflowReference struct {
 	// `namespace` is the namespace of the workflow.
@@ -113,3 +428,24 @@ func (r *WorkflowReference) GetNamespacedName() types.NamespacedName {
 		Namespace: r.Namespace,
 	}
 }
+
+// Usage lists the resources that use a Connection or DataSet.
+// Resources in the namespace of the Connection or DataSet are listed by name,
+// resources in other namespaces as namespace/name.
+type Usage struct {
+	// Workflows that inject the Connection or DataSet
+	// +optional
+	Workflows []string `json:"workflows,omitempty"`
+
+	// CronWorkflows that inject the Connection or DataSet
+	// +optional
+	CronWorkflows []string `json:"cronWorkflows,omitempty"`
+
+	// WorkflowTemplates that inject the Connection or DataSet
+	// +optional
+	WorkflowTemplates []string `json:"workflowTemplates,omitempty"`
+
+	// DataSets that use the Connection
+	// +optional
+	DataSets []string `json:"dataSets,omitempty"`
+}
@@ -0,0 +1,116 @@
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Credentials", func() {
+	fields := []CredentialFieldSpec{
+		{Name: "host"},
+		{Name: "port", Default: "3306"},
+		{Name: "database", Default: "mysql"},
+		{Name: "url", Default: "ignored", Derived: "jdbc:mysql://{{.host}}:{{.port}}/{{.database}}"},
+	}
+
+	It("Should apply the defaults of the fields that are not set", func() {
+		creds := Credentials{
+			"host":     Value{Value: "localhost"},
+			"database": Value{Value: "sales"},
+		}
+		Expect(creds.ApplyDefaults(fields)).To(BeTrue())
+		Expect(creds).To(Equal(Credentials{
+			"host":     Value{Value: "localhost"},
+			"port":     Value{Value: "3306"},
+			"database": Value{Value: "sales"},
+		}))
+		Expect(creds.ApplyDefaults(fields)).To(BeFalse())
+	})
+
+	It("Should apply defaults to empty Credentials", func() {
+		var creds Credentials
+		Expect(creds.ApplyDefaults(fields)).To(BeTrue())
+		Expect(creds).To(HaveLen(2))
+	})
+
+	It("Should not apply the default of a sensitive field", func() {
+		creds := Credentials{}
+		Expect(creds.ApplyDefaults([]CredentialFieldSpec{{Name: "password", Default: "changeme", Sensitive: true}})).To(BeFalse())
+		Expect(creds).To(BeEmpty())
+	})
+
+	It("Should compute the derived fields of plain text values", func() {
+		creds := Credentials{
+			"host":     Value{Value: "localhost"},
+			"port":     Value{Value: "3306"},
+			"database": Value{Value: "sales"},
+			"url":      Value{Value: "jdbc:mysql://elsewhere"},
+		}
+		Expect(creds.ApplyDerived(fields)).To(BeTrue())
+		Expect(creds["url"]).To(Equal(Value{Value: "jdbc:mysql://localhost:3306/sales"}))
+		Expect(creds.ApplyDerived(fields)).To(BeFalse())
+	})
+
+	It("Should leave derived fields that use values from a Secret to the injection", func() {
+		creds := Credentials{
+			"host":     Value{ValueFrom: &ValueSource{SecretKeyRef: &apiv1.SecretKeySelector{Key: "host"}}},
+			"port":     Value{Value: "3306"},
+			"database": Value{Value: "sales"},
+			"url":      Value{Value: "jdbc:mysql://elsewhere"},
+		}
+		Expect(creds.ApplyDerived(fields)).To(BeTrue())
+		Expect(creds).ToNot(HaveKey("url"))
+	})
+
+	It("Should return the effective field names", func() {
+		creds := Credentials{"host": Value{Value: "localhost"}}
+		Expect(EffectiveFieldNames(creds, []string{"password"}, fields)).To(Equal([]string{"host", "password", "url"}))
+	})
+
+	It("Should derive fields from the other values", func() {
+		values := map[string]string{"host": "localhost", "port": "3306", "database": "sales"}
+		Expect(DeriveValues(fields, values)).To(Succeed())
+		Expect(values["url"]).To(Equal("jdbc:mysql://localhost:3306/sales"))
+	})
+
+	It("Should leave out a derived field that refers to an optional field that is not set", func() {
+		values := map[string]string{"host": "localhost"}
+		Expect(DeriveValues(fields, values)).To(Succeed())
+		Expect(values).To(Equal(map[string]string{"host": "localhost"}))
+
+		creds := Credentials{"host": Value{Value: "localhost"}}
+		creds.ApplyDerived(fields)
+		Expect(creds).ToNot(HaveKey("url"))
+	})
+
+	It("Should fail to derive a field whose template fails for other reasons", func() {
+		required := []CredentialFieldSpec{{Name: "url", Derived: `{{required "port must not be empty" .port}}`}}
+		err := DeriveValues(required, map[string]string{"port": ""})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("failed to derive field url"))
+	})
+})
+
+var _ = DescribeTable("ValidateExternalPath",
+	func(path, expected string) {
+		err := ValidateExternalPath(path)
+		if expected == "" {
+			Expect(err).To(Succeed())
+		} else {
+			Expect(err).To(MatchError(expected))
+		}
+	},
+	Entry("a relative path", "team/mysql", ""),
+	Entry("a trailing slash", "mysql/", ""),
+	Entry("an absolute path", "/other/mysql", "path must be relative"),
+	Entry("a parent directory", "mysql/../../other", "path must not refer to a parent directory"),
+	Entry("an escaped parent directory", "x%2f..%2fother", `path must not contain %, ?, # or \`),
+	Entry("a query", "mysql?list=true", `path must not contain %, ?, # or \`),
+	Entry("a fragment", "mysql#frag", `path must not contain %, ?, # or \`),
+	Entry("a backslash", `..\other`, `path must not contain %, ?, # or \`),
+	Entry("an empty segment", "team//mysql", "path must not contain empty or . segments"),
+	Entry("a current directory", "./mysql", "path must not contain empty or . segments"),
+	Entry("an empty path", "", "path must not contain empty or . segments"),
+)
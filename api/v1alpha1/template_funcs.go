@@ -0,0 +1,106 @@
+package v1alpha1
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// contentTemplateFuncs contains the functions available to ContentTemplates in addition to the
+// text/template builtins (such as urlquery, eq and printf). The functions only transform their
+// arguments and never read the environment, the filesystem or the network.
+var contentTemplateFuncs = template.FuncMap{
+	"urlPathEscape": url.PathEscape,
+	"b64enc":        b64enc,
+	"b64dec":        b64dec,
+	"default":       defaultValue,
+	"required":      required,
+	"quote":         quote,
+	"upper":         strings.ToUpper,
+	"lower":         strings.ToLower,
+	"join":          join,
+	"toJson":        toJSON,
+}
+
+func b64enc(value string) string {
+	return base64.StdEncoding.EncodeToString([]byte(value))
+}
+
+func b64dec(value string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", fmt.Errorf("b64dec: %w", err)
+	}
+	return string(data), nil
+}
+
+// defaultValue returns the value, or def if the value is empty.
+// It is typically used with index, which does not fail on missing keys: {{index . "port" | default "3306"}}
+func defaultValue(def interface{}, value ...interface{}) interface{} {
+	if len(value) == 0 || isEmpty(value[0]) {
+		return def
+	}
+	return value[0]
+}
+
+// required returns the value, or fails with the message if the value is empty
+func required(msg string, value interface{}) (interface{}, error) {
+	if isEmpty(value) {
+		return nil, errors.New(msg)
+	}
+	return value, nil
+}
+
+func quote(values ...interface{}) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != nil {
+			quoted = append(quoted, strconv.Quote(fmt.Sprint(v)))
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// join joins the elements of a list (or a single value) using the separator
+func join(sep string, list interface{}) string {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return fmt.Sprint(list)
+	}
+
+	elems := make([]string, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		elems = append(elems, fmt.Sprint(v.Index(i).Interface()))
+	}
+	return strings.Join(elems, sep)
+}
+
+func toJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("toJson: %w", err)
+	}
+	return string(data), nil
+}
+
+// isEmpty returns true for nil and for zero values, including empty strings, slices and maps
+func isEmpty(value interface{}) bool {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return true
+	}
+
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
@@ -0,0 +1,61 @@
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ContentTemplate functions", func() {
+	data := map[string]interface{}{
+		"user":     "admin",
+		"password": "p@ss/w:rd",
+		"encoded":  "c2VjcmV0",
+		"empty":    "",
+		"hosts":    []string{"db-0", "db-1"},
+	}
+
+	render := func(ct ContentTemplate) (string, error) {
+		return ct.Render(data)
+	}
+
+	DescribeTable("Rendering templates using the functions",
+		func(ct ContentTemplate, expected string) {
+			res, err := render(ct)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res).To(Equal(expected))
+		},
+		Entry("urlquery", ContentTemplate(`{{.password | urlquery}}`), "p%40ss%2Fw%3Ard"),
+		Entry("urlPathEscape", ContentTemplate(`{{.password | urlPathEscape}}`), "p@ss%2Fw:rd"),
+		Entry("b64enc", ContentTemplate(`{{.user | b64enc}}`), "YWRtaW4="),
+		Entry("b64dec", ContentTemplate(`{{.encoded | b64dec}}`), "secret"),
+		Entry("default with an empty value", ContentTemplate(`{{.empty | default "root"}}`), "root"),
+		Entry("default with a missing key", ContentTemplate(`{{index . "port" | default 3306}}`), "3306"),
+		Entry("default with a value", ContentTemplate(`{{.user | default "root"}}`), "admin"),
+		Entry("required with a value", ContentTemplate(`{{.user | required "user is required"}}`), "admin"),
+		Entry("quote", ContentTemplate(`{{.password | quote}}`), `"p@ss/w:rd"`),
+		Entry("upper", ContentTemplate(`{{.user | upper}}`), "ADMIN"),
+		Entry("lower", ContentTemplate(`{{"ADMIN" | lower}}`), "admin"),
+		Entry("join", ContentTemplate(`{{.hosts | join ","}}`), "db-0,db-1"),
+		Entry("toJson", ContentTemplate(`{{.hosts | toJson}}`), `["db-0","db-1"]`),
+		Entry("conditionals", ContentTemplate(`jdbc:mysql://host/db{{if .user}}?user={{.user}}{{end}}`), "jdbc:mysql://host/db?user=admin"),
+	)
+
+	It("Should fail required on an empty value", func() {
+		_, err := render(`{{.empty | required "empty is required"}}`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("empty is required"))
+	})
+
+	It("Should fail b64dec on invalid input", func() {
+		_, err := render(`{{.password | b64dec}}`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("b64dec"))
+	})
+
+	It("Should not provide functions that read the environment", func() {
+		_, err := render(`{{env "HOME"}}`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(`function "env" not defined`))
+	})
+})
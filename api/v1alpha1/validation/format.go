@@ -0,0 +1,111 @@
+package validation
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	utilvalidation "k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+// validateEnum validates that the value is one of the values of the enum
+func validateEnum(value string, path *field.Path, enum []string) field.ErrorList {
+	for _, e := range enum {
+		if value == e {
+			return nil
+		}
+	}
+	return field.ErrorList{field.NotSupported(path, value, enum)}
+}
+
+// validateFormat validates that the value has the given format
+func validateFormat(value string, path *field.Path, format v1alpha1.ValueFormat, schemes []string) field.ErrorList {
+	switch format {
+	case v1alpha1.ValueFormatInteger:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return field.ErrorList{field.Invalid(path, value, "Value is not an integer")}
+		}
+	case v1alpha1.ValueFormatFloat:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return field.ErrorList{field.Invalid(path, value, "Value is not a number")}
+		}
+	case v1alpha1.ValueFormatBoolean:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return field.ErrorList{field.Invalid(path, value, "Value is not a boolean")}
+		}
+	case v1alpha1.ValueFormatURL:
+		return validateURL(value, path, schemes)
+	case v1alpha1.ValueFormatHostname:
+		if net.ParseIP(value) != nil {
+			return nil
+		}
+		var errList field.ErrorList
+		for _, msg := range utilvalidation.IsDNS1123Subdomain(value) {
+			errList = append(errList, field.Invalid(path, value, msg))
+		}
+		return errList
+	case v1alpha1.ValueFormatPort:
+		port, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return field.ErrorList{field.Invalid(path, value, "Value is not a port number")}
+		}
+		if msgs := utilvalidation.IsValidPortNum(int(port)); len(msgs) > 0 {
+			return field.ErrorList{field.Invalid(path, value, msgs[0])}
+		}
+	case v1alpha1.ValueFormatDuration:
+		if _, err := time.ParseDuration(value); err != nil {
+			return field.ErrorList{field.Invalid(path, value, "Value is not a duration")}
+		}
+	}
+	return nil
+}
+
+// validateURL validates that the value is an absolute URL with one of the schemes.
+// Every scheme is allowed if no schemes are given.
+func validateURL(value string, path *field.Path, schemes []string) field.ErrorList {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" {
+		return field.ErrorList{field.Invalid(path, value, "Value is not an absolute URL")}
+	}
+	if len(schemes) == 0 {
+		return nil
+	}
+	for _, s := range schemes {
+		if u.Scheme == s {
+			return nil
+		}
+	}
+	return field.ErrorList{field.Invalid(path, value, fmt.Sprintf("URL scheme must be one of %v", schemes))}
+}
+
+// validateRange validates that the value is a number between the minimum and maximum, if they are set
+func validateRange(value string, path *field.Path, minimum, maximum *v1alpha1.ValidationNumber) field.ErrorList {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, value, "Value is not a number")}
+	}
+
+	var errList field.ErrorList
+	if minimum != nil {
+		min, err := strconv.ParseFloat(string(*minimum), 64)
+		if err != nil {
+			errList = append(errList, field.InternalError(path, fmt.Errorf("invalid minimum: %w", err)))
+		} else if number < min {
+			errList = append(errList, field.Invalid(path, value, fmt.Sprintf("Value below Minimum %s", *minimum)))
+		}
+	}
+	if maximum != nil {
+		max, err := strconv.ParseFloat(string(*maximum), 64)
+		if err != nil {
+			errList = append(errList, field.InternalError(path, fmt.Errorf("invalid maximum: %w", err)))
+		} else if number > max {
+			errList = append(errList, field.Invalid(path, value, fmt.Sprintf("Value above Maximum %s", *maximum)))
+		}
+	}
+	return errList
+}
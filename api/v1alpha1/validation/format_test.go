@@ -0,0 +1,166 @@
+package validation
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/pointer"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+var _ = Describe("Validating typed values", func() {
+	number := func(n string) *v1alpha1.ValidationNumber {
+		v := v1alpha1.ValidationNumber(n)
+		return &v
+	}
+	schema := func(s string) *apiextensionsv1.JSON {
+		return &apiextensionsv1.JSON{Raw: []byte(s)}
+	}
+	regex := func(r string) *v1alpha1.ValidationRegex {
+		v := v1alpha1.ValidationRegex(r)
+		return &v
+	}
+
+	DescribeTable("ValidateValue",
+		func(value string, validation v1alpha1.Validation, valid bool) {
+			errs := ValidateValue(value, field.NewPath("value"), validation)
+			if valid {
+				Expect(errs).To(BeEmpty())
+			} else {
+				Expect(errs).ToNot(BeEmpty())
+			}
+		},
+		Entry("a value in the enum", "mysql", v1alpha1.Validation{Enum: []string{"mysql", "postgres"}}, true),
+		Entry("a value not in the enum", "oracle", v1alpha1.Validation{Enum: []string{"mysql", "postgres"}}, false),
+
+		Entry("an integer", "-42", v1alpha1.Validation{Format: v1alpha1.ValueFormatInteger}, true),
+		Entry("a float as integer", "4.2", v1alpha1.Validation{Format: v1alpha1.ValueFormatInteger}, false),
+		Entry("a float", "4.2", v1alpha1.Validation{Format: v1alpha1.ValueFormatFloat}, true),
+		Entry("text as float", "four", v1alpha1.Validation{Format: v1alpha1.ValueFormatFloat}, false),
+		Entry("an integer within range", "5", v1alpha1.Validation{Format: v1alpha1.ValueFormatInteger, Minimum: number("1"), Maximum: number("10")}, true),
+		Entry("an integer below minimum", "0", v1alpha1.Validation{Format: v1alpha1.ValueFormatInteger, Minimum: number("1")}, false),
+		Entry("a float above maximum", "0.75", v1alpha1.Validation{Format: v1alpha1.ValueFormatFloat, Maximum: number("0.5")}, false),
+		Entry("text with a range", "many", v1alpha1.Validation{Minimum: number("1")}, false),
+
+		Entry("a boolean", "true", v1alpha1.Validation{Format: v1alpha1.ValueFormatBoolean}, true),
+		Entry("text as boolean", "yes please", v1alpha1.Validation{Format: v1alpha1.ValueFormatBoolean}, false),
+
+		Entry("a URL", "postgres://db.example.com:5432/etl", v1alpha1.Validation{Format: v1alpha1.ValueFormatURL}, true),
+		Entry("a relative URL", "/etl", v1alpha1.Validation{Format: v1alpha1.ValueFormatURL}, false),
+		Entry("a URL with an allowed scheme", "https://example.com", v1alpha1.Validation{Format: v1alpha1.ValueFormatURL, Schemes: []string{"https"}}, true),
+		Entry("a URL with a disallowed scheme", "http://example.com", v1alpha1.Validation{Format: v1alpha1.ValueFormatURL, Schemes: []string{"https"}}, false),
+
+		Entry("a hostname", "db.example.com", v1alpha1.Validation{Format: v1alpha1.ValueFormatHostname}, true),
+		Entry("an IP address as hostname", "10.0.0.1", v1alpha1.Validation{Format: v1alpha1.ValueFormatHostname}, true),
+		Entry("an invalid hostname", "db_example.com", v1alpha1.Validation{Format: v1alpha1.ValueFormatHostname}, false),
+
+		Entry("a port", "5432", v1alpha1.Validation{Format: v1alpha1.ValueFormatPort}, true),
+		Entry("a port out of range", "70000", v1alpha1.Validation{Format: v1alpha1.ValueFormatPort}, false),
+		Entry("port zero", "0", v1alpha1.Validation{Format: v1alpha1.ValueFormatPort}, false),
+
+		Entry("a duration", "1h30m", v1alpha1.Validation{Format: v1alpha1.ValueFormatDuration}, true),
+		Entry("an invalid duration", "90", v1alpha1.Validation{Format: v1alpha1.ValueFormatDuration}, false),
+
+		Entry("a string matching a string schema", "abc", v1alpha1.Validation{JSONSchema: schema(`{"type": "string", "pattern": "^[a-z]+$"}`)}, true),
+		Entry("a string not matching a string schema", "ABC", v1alpha1.Validation{JSONSchema: schema(`{"type": "string", "pattern": "^[a-z]+$"}`)}, false),
+		Entry("invalid JSON", "{", v1alpha1.Validation{JSONSchema: schema(`{"type": "object"}`)}, false),
+		Entry("an object matching an object schema",
+			`{"host": "localhost", "port": 5432}`,
+			v1alpha1.Validation{JSONSchema: schema(`{"type": "object", "required": ["host"], "properties": {"port": {"type": "integer", "maximum": 65535}}}`)},
+			true,
+		),
+		Entry("an object missing a required property",
+			`{"port": 5432}`,
+			v1alpha1.Validation{JSONSchema: schema(`{"type": "object", "required": ["host"]}`)},
+			false,
+		),
+		Entry("an object with an invalid property",
+			`{"host": "localhost", "port": 5432.5}`,
+			v1alpha1.Validation{JSONSchema: schema(`{"type": "object", "properties": {"port": {"type": "integer"}}}`)},
+			false,
+		),
+		Entry("an object with additional properties",
+			`{"host": "localhost", "user": "root"}`,
+			v1alpha1.Validation{JSONSchema: schema(`{"type": "object", "properties": {"host": {"type": "string"}}, "additionalProperties": false}`)},
+			false,
+		),
+		Entry("an array of allowed items",
+			`["read", "write"]`,
+			v1alpha1.Validation{JSONSchema: schema(`{"type": "array", "minItems": 1, "items": {"enum": ["read", "write"]}}`)},
+			true,
+		),
+		Entry("an array with an item that is not allowed",
+			`["read", "admin"]`,
+			v1alpha1.Validation{JSONSchema: schema(`{"type": "array", "items": {"enum": ["read", "write"]}}`)},
+			false,
+		),
+		Entry("a value matching one schema of oneOf",
+			`5`,
+			v1alpha1.Validation{JSONSchema: schema(`{"oneOf": [{"type": "integer"}, {"type": "string"}]}`)},
+			true,
+		),
+		Entry("a value matching no schema of anyOf",
+			`true`,
+			v1alpha1.Validation{JSONSchema: schema(`{"anyOf": [{"type": "integer"}, {"type": "string"}]}`)},
+			false,
+		),
+		Entry("an array with duplicate items",
+			`["read", "read"]`,
+			v1alpha1.Validation{JSONSchema: schema(`{"type": "array", "uniqueItems": true}`)},
+			false,
+		),
+		Entry("a string not matching a format",
+			"not-an-address",
+			v1alpha1.Validation{JSONSchema: schema(`{"type": "string", "format": "ipv4"}`)},
+			false,
+		),
+		Entry("an object with a property not matching patternProperties",
+			`{"x-port": "high"}`,
+			v1alpha1.Validation{JSONSchema: schema(`{"type": "object", "patternProperties": {"^x-": {"type": "integer"}}}`)},
+			false,
+		),
+		Entry("an object missing a dependency",
+			`{"user": "root"}`,
+			v1alpha1.Validation{JSONSchema: schema(`{"type": "object", "dependencies": {"user": ["password"]}}`)},
+			false,
+		),
+	)
+
+	It("Should report an invalid JSON Schema as an internal error", func() {
+		errs := ValidateValue("{}", field.NewPath("value"), v1alpha1.Validation{JSONSchema: schema(`[`)})
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Type).To(Equal(field.ErrorTypeInternal))
+	})
+
+	DescribeTable("ValidateValidationSpec",
+		func(validation v1alpha1.Validation, valid bool) {
+			errs := ValidateValidationSpec(validation, field.NewPath("validation"))
+			if valid {
+				Expect(errs).To(BeEmpty())
+			} else {
+				Expect(errs).ToNot(BeEmpty())
+			}
+		},
+		Entry("a consistent validation", v1alpha1.Validation{Format: v1alpha1.ValueFormatInteger, Minimum: number("1"), Maximum: number("10"), Enum: []string{"1", "5"}}, true),
+		Entry("a minimum above the maximum", v1alpha1.Validation{Minimum: number("10"), Maximum: number("1")}, false),
+		Entry("a minLength above the maxLength", v1alpha1.Validation{MinLength: pointer.Int32Ptr(5), MaxLength: pointer.Int32Ptr(1)}, false),
+		Entry("an invalid regex", v1alpha1.Validation{Regex: regex("[")}, false),
+		Entry("an enum value of the wrong format", v1alpha1.Validation{Format: v1alpha1.ValueFormatInteger, Enum: []string{"1", "one"}}, false),
+		Entry("an enum value out of range", v1alpha1.Validation{Minimum: number("1"), Enum: []string{"0"}}, false),
+
+		Entry("a supported JSON Schema", v1alpha1.Validation{JSONSchema: schema(`{"type": "object", "properties": {"port": {"type": "integer", "minimum": 1, "maximum": 65535}}}`)}, true),
+		Entry("a JSON Schema that is not JSON", v1alpha1.Validation{JSONSchema: schema(`[`)}, false),
+		Entry("a JSON Schema with an unknown keyword", v1alpha1.Validation{JSONSchema: schema(`{"type": "string", "minlength": 1}`)}, false),
+		Entry("a JSON Schema with an unknown nested keyword", v1alpha1.Validation{JSONSchema: schema(`{"type": "array", "items": {"type": "string", "patern": "^a"}}`)}, false),
+		Entry("a JSON Schema with a $ref", v1alpha1.Validation{JSONSchema: schema(`{"$ref": "#/definitions/port"}`)}, false),
+		Entry("a JSON Schema with an unsupported format", v1alpha1.Validation{JSONSchema: schema(`{"type": "string", "format": "color"}`)}, false),
+		Entry("a JSON Schema with an invalid pattern", v1alpha1.Validation{JSONSchema: schema(`{"type": "string", "pattern": "["}`)}, false),
+		Entry("a JSON Schema with an invalid nested pattern", v1alpha1.Validation{JSONSchema: schema(`{"type": "array", "items": {"type": "string", "pattern": "("}}`)}, false),
+		Entry("a JSON Schema with a minimum above the maximum", v1alpha1.Validation{JSONSchema: schema(`{"type": "integer", "minimum": 10, "maximum": 1}`)}, false),
+		Entry("a JSON Schema with an enum value of the wrong type", v1alpha1.Validation{JSONSchema: schema(`{"type": "integer", "enum": [1, "two"]}`)}, false),
+	)
+})
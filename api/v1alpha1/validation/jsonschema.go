@@ -0,0 +1,226 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiservervalidation "k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+)
+
+// schemaValidators caches the validators of the JSON Schema fragments by their raw JSON,
+// as every value of a field is validated against the same fragment
+var schemaValidators sync.Map
+
+// validateJSONSchema validates the value against the JSON Schema fragment.
+// The value is decoded as JSON, unless the schema has type string.
+func validateJSONSchema(value string, path *field.Path, raw *apiextensionsv1.JSON) field.ErrorList {
+	validator, schema, err := compileJSONSchema(raw)
+	if err != nil {
+		return field.ErrorList{field.InternalError(path, fmt.Errorf("invalid JSON Schema: %w", err))}
+	}
+
+	var data interface{} = value
+	if !schema.Type.Contains("string") {
+		if err := utiljson.Unmarshal([]byte(value), &data); err != nil {
+			return field.ErrorList{field.Invalid(path, value, "Value is not valid JSON")}
+		}
+	}
+
+	return apiservervalidation.ValidateCustomResource(path, data, validator)
+}
+
+// ValidateJSONSchema validates that the JSON Schema fragment only uses keywords the validator supports
+// and that its patterns, formats, bounds and enum values are valid
+func ValidateJSONSchema(raw *apiextensionsv1.JSON, path *field.Path) field.ErrorList {
+	if _, _, err := compileJSONSchema(raw); err != nil {
+		return field.ErrorList{field.Invalid(path, string(raw.Raw), err.Error())}
+	}
+	return nil
+}
+
+type compiledSchema struct {
+	validator *validate.SchemaValidator
+	schema    *spec.Schema
+}
+
+// compileJSONSchema decodes the JSON Schema fragment and returns its validator.
+// Fragments that are not valid are rejected, as the validator ignores or panics on them.
+func compileJSONSchema(raw *apiextensionsv1.JSON) (*validate.SchemaValidator, *spec.Schema, error) {
+	if cached, ok := schemaValidators.Load(string(raw.Raw)); ok {
+		c := cached.(compiledSchema)
+		return c.validator, c.schema, nil
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(raw.Raw, &decoded); err != nil {
+		return nil, nil, err
+	}
+	if err := checkKeywords(decoded); err != nil {
+		return nil, nil, err
+	}
+	var props apiextensionsv1.JSONSchemaProps
+	if err := json.Unmarshal(raw.Raw, &props); err != nil {
+		return nil, nil, err
+	}
+
+	internal := &apiextensions.JSONSchemaProps{}
+	if err := apiextensionsv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(&props, internal, nil); err != nil {
+		return nil, nil, err
+	}
+	if err := apiservervalidation.ConvertJSONSchemaPropsWithPostProcess(internal, &spec.Schema{}, checkSchema); err != nil {
+		return nil, nil, err
+	}
+
+	validator, schema, err := apiservervalidation.NewSchemaValidator(&apiextensions.CustomResourceValidation{OpenAPIV3Schema: internal})
+	if err != nil {
+		return nil, nil, err
+	}
+	schemaValidators.Store(string(raw.Raw), compiledSchema{validator: validator, schema: schema})
+	return validator, schema, nil
+}
+
+// schemaKeywords are the keywords of a JSON Schema fragment
+var schemaKeywords = jsonFieldNames(reflect.TypeOf(apiextensionsv1.JSONSchemaProps{}))
+
+func jsonFieldNames(t reflect.Type) sets.String {
+	names := sets.NewString()
+	for i := 0; i < t.NumField(); i++ {
+		names.Insert(strings.Split(t.Field(i).Tag.Get("json"), ",")[0])
+	}
+	return names
+}
+
+// checkKeywords checks that the decoded JSON Schema fragment and its subschemas only use known keywords,
+// as they are matched case-insensitively and otherwise ignored when the fragment is decoded
+func checkKeywords(schema interface{}) error {
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	for _, k := range sets.StringKeySet(s).List() {
+		if !schemaKeywords.Has(k) {
+			return fmt.Errorf("unknown keyword %s", k)
+		}
+
+		var subschemas []interface{}
+		switch v := s[k]; k {
+		case "items", "additionalProperties", "additionalItems", "not":
+			if items, ok := v.([]interface{}); ok {
+				subschemas = items
+			} else {
+				subschemas = []interface{}{v}
+			}
+		case "allOf", "anyOf", "oneOf":
+			subschemas, _ = v.([]interface{})
+		case "properties", "patternProperties", "definitions", "dependencies":
+			m, _ := v.(map[string]interface{})
+			for _, name := range sets.StringKeySet(m).List() {
+				subschemas = append(subschemas, m[name])
+			}
+		}
+		for _, sub := range subschemas {
+			if err := checkKeywords(sub); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkSchema checks a single (sub)schema of a JSON Schema fragment
+func checkSchema(s *spec.Schema) error {
+	if s.Ref.String() != "" {
+		return fmt.Errorf("$ref is not supported")
+	}
+
+	if s.Format != "" {
+		stripped := *s
+		if err := apiservervalidation.StripUnsupportedFormatsPostProcess(&stripped); err != nil {
+			return err
+		}
+		if stripped.Format == "" {
+			return fmt.Errorf("format %s is not supported", s.Format)
+		}
+	}
+
+	if s.Pattern != "" {
+		if _, err := regexp.Compile(s.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+	for p := range s.PatternProperties {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("invalid patternProperties pattern: %w", err)
+		}
+	}
+
+	if s.Minimum != nil && s.Maximum != nil && *s.Minimum > *s.Maximum {
+		return fmt.Errorf("minimum %v is greater than maximum %v", *s.Minimum, *s.Maximum)
+	}
+	if s.MinLength != nil && s.MaxLength != nil && *s.MinLength > *s.MaxLength {
+		return fmt.Errorf("minLength %d is greater than maxLength %d", *s.MinLength, *s.MaxLength)
+	}
+	if s.MinItems != nil && s.MaxItems != nil && *s.MinItems > *s.MaxItems {
+		return fmt.Errorf("minItems %d is greater than maxItems %d", *s.MinItems, *s.MaxItems)
+	}
+	if s.MinProperties != nil && s.MaxProperties != nil && *s.MinProperties > *s.MaxProperties {
+		return fmt.Errorf("minProperties %d is greater than maxProperties %d", *s.MinProperties, *s.MaxProperties)
+	}
+	if s.MultipleOf != nil && *s.MultipleOf <= 0 {
+		return fmt.Errorf("multipleOf must be positive")
+	}
+
+	for _, e := range s.Enum {
+		if !hasSchemaType(e, s) {
+			return fmt.Errorf("enum value %v must be of type %s", e, s.Type)
+		}
+	}
+	return nil
+}
+
+// hasSchemaType returns whether the decoded JSON data has one of the types of the schema
+func hasSchemaType(data interface{}, s *spec.Schema) bool {
+	if len(s.Type) == 0 || (data == nil && s.Nullable) {
+		return true
+	}
+	for _, t := range s.Type {
+		if hasJSONType(data, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasJSONType returns whether the decoded JSON data has the JSON Schema type
+func hasJSONType(data interface{}, t string) bool {
+	switch v := data.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case int64:
+		return t == "number" || t == "integer"
+	case float64:
+		return t == "number" || (t == "integer" && v == math.Trunc(v))
+	case string:
+		return t == "string"
+	case []interface{}:
+		return t == "array"
+	case map[string]interface{}:
+		return t == "object"
+	}
+	return false
+}
@@ -1,8 +1,13 @@
 package validation
 
 import (
+	"fmt"
+	"net/url"
 	"regexp"
+	"sort"
+	"strconv"
 
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	"github.com/dataworkz/kubeetl/api/v1alpha1"
@@ -40,77 +45,244 @@ func ValidateValue(value string, path *field.Path, validation v1alpha1.Validatio
 		}
 	}
 
+	if len(validation.Enum) > 0 {
+		errList = append(errList, validateEnum(value, path, validation.Enum)...)
+	}
+
+	if validation.Format != "" {
+		errList = append(errList, validateFormat(value, path, validation.Format, validation.Schemes)...)
+	}
+
+	if validation.Minimum != nil || validation.Maximum != nil {
+		errList = append(errList, validateRange(value, path, validation.Minimum, validation.Maximum)...)
+	}
+
+	if validation.JSONSchema != nil {
+		errList = append(errList, validateJSONSchema(value, path, validation.JSONSchema)...)
+	}
+
 	return errList
 }
 
-// ValidateConnection validates whether a v1alpha1.Connection adheres to the definition
-// of its type defined by a v1alpha1.ConnectionType
-func ValidateConnection(con v1alpha1.Connection, conType v1alpha1.ConnectionType) field.ErrorList {
-	// Transform to fieldmap for quick lookup
-	fieldMap := make(map[string]*v1alpha1.CredentialFieldSpec)
-	for _, credField := range conType.Spec.Fields {
-		c := credField
-		fieldMap[credField.Name] = &c
+// ValidateValidationSpec validates that the Validation of a field is consistent, so every value can be validated against it.
+// The regex and JSON Schema must be valid, the bounds must not exclude every value and the enum values must pass the other checks.
+func ValidateValidationSpec(validation v1alpha1.Validation, path *field.Path) field.ErrorList {
+	var errList field.ErrorList
+	if validation.MinLength != nil && validation.MaxLength != nil && *validation.MinLength > *validation.MaxLength {
+		errList = append(errList, field.Invalid(path.Child("minLength"), *validation.MinLength, "must not be greater than maxLength"))
 	}
 
-	// Perform field validation
+	if validation.Regex != nil {
+		if _, err := regexp.Compile(string(*validation.Regex)); err != nil {
+			errList = append(errList, field.Invalid(path.Child("regex"), *validation.Regex, err.Error()))
+		}
+	}
+
+	min, minErrs := parseValidationNumber(validation.Minimum, path.Child("minimum"))
+	max, maxErrs := parseValidationNumber(validation.Maximum, path.Child("maximum"))
+	errList = append(append(errList, minErrs...), maxErrs...)
+	if min != nil && max != nil && *min > *max {
+		errList = append(errList, field.Invalid(path.Child("minimum"), *validation.Minimum, "must not be greater than maximum"))
+	}
+
+	if validation.JSONSchema != nil {
+		errList = append(errList, ValidateJSONSchema(validation.JSONSchema, path.Child("jsonSchema"))...)
+	}
+
+	if len(errList) > 0 || len(validation.Enum) == 0 {
+		return errList
+	}
+
+	// every enum value must be a valid value for the other checks
+	rest := validation
+	rest.Enum = nil
+	for i, e := range validation.Enum {
+		errList = append(errList, ValidateValue(e, path.Child("enum").Index(i), rest)...)
+	}
+	return errList
+}
+
+func parseValidationNumber(n *v1alpha1.ValidationNumber, path *field.Path) (*float64, field.ErrorList) {
+	if n == nil {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(string(*n), 64)
+	if err != nil {
+		return nil, field.ErrorList{field.Invalid(path, *n, "must be a number")}
+	}
+	return &f, nil
+}
+
+// ValidateFieldSpecs validates the Validation of the fields of a ConnectionType or DataSetType
+func ValidateFieldSpecs(fields []v1alpha1.CredentialFieldSpec, path *field.Path) field.ErrorList {
 	var errList field.ErrorList
-	for k, v := range con.Spec.Credentials {
-		credField := fieldMap[k]
-		path := field.NewPath("spec").Child("credentials").Child(k)
-		if credField == nil {
-			if !conType.Spec.AllowExtraFields {
-				err := field.Invalid(path, v, "ConnectionType does not allow extra fields")
-				errList = append(errList, err)
-			}
+	for i, f := range fields {
+		if f.Validation != nil {
+			errList = append(errList, ValidateValidationSpec(*f.Validation, path.Index(i).Child("validation"))...)
+		}
+	}
+	return errList
+}
+
+// ValidateConnectionTypeSpec validates the spec of a ConnectionType or ClusterConnectionType
+func ValidateConnectionTypeSpec(spec v1alpha1.ConnectionTypeSpec) field.ErrorList {
+	return ValidateFieldSpecs(spec.Fields, field.NewPath("spec").Child("fields"))
+}
+
+// ValidateDataSetTypeSpec validates the spec of a DataSetType or ClusterDataSetType
+func ValidateDataSetTypeSpec(spec v1alpha1.DataSetTypeSpec) field.ErrorList {
+	return ValidateFieldSpecs(spec.MetadataFields.Fields, field.NewPath("spec").Child("metadata", "fields"))
+}
+
+// redactedValue replaces the values in errors about resolved values, which may be secret
+const redactedValue = "<redacted>"
+
+// ValidateResolvedValues validates values resolved from a Secret or ConfigMap against the Validation of their field.
+// The values are keyed by field name. The returned errors never contain the values themselves.
+func ValidateResolvedValues(values map[string]string, fields []v1alpha1.CredentialFieldSpec, path *field.Path) field.ErrorList {
+	var errList field.ErrorList
+	for _, f := range fields {
+		value, ok := values[f.Name]
+		if !ok || f.Validation == nil {
 			continue
 		}
 
-		if credField.Sensitive {
-			if v.Value != "" || v.ValueFrom.ConfigMapKeyRef != nil {
-				err := field.Invalid(path, v, "Field is sensitive, only SecretKeyRef is allowed")
-				errList = append(errList, err)
+		fieldPath := path.Child(f.Name)
+		validation := *f.Validation
+		validation.JSONSchema = nil
+		for _, err := range ValidateValue(value, fieldPath, validation) {
+			err.BadValue = redactedValue
+			errList = append(errList, err)
+		}
 
-				continue
-			}
+		// the JSON Schema errors refer to the keys and values of the decoded value, so only the rule is reported
+		if f.Validation.JSONSchema != nil {
+			errList = append(errList, redactJSONSchemaErrors(validateJSONSchema(value, fieldPath, f.Validation.JSONSchema), fieldPath)...)
+		}
+	}
+	return errList
+}
+
+// redactJSONSchemaErrors replaces the errors of validating a value against its JSON Schema by a single error of the field
+func redactJSONSchemaErrors(errs field.ErrorList, path *field.Path) field.ErrorList {
+	if len(errs) == 0 {
+		return nil
+	}
+	for _, err := range errs {
+		// the schema itself is invalid, which does not depend on the value
+		if err.Type == field.ErrorTypeInternal {
+			return field.ErrorList{err}
 		}
+	}
+	return field.ErrorList{field.Invalid(path, redactedValue, "Value does not match the JSON Schema")}
+}
 
-		// Perform validation, not possible with reference valueFrom
-		if v.Value != "" {
-			errs := ValidateValue(v.Value, path, *credField.Validation)
-			errList = append(errList, errs...)
+// ValidateConnection validates whether a v1alpha1.Connection adheres to the definition
+// of its type defined by a v1alpha1.ConnectionType
+func ValidateConnection(con v1alpha1.Connection, conType v1alpha1.ConnectionType) field.ErrorList {
+	path := field.NewPath("spec").Child("credentials")
+	if con.Spec.CredentialIssuer == nil {
+		return validateCredentials(con.Spec.Credentials, conType.Spec.Fields, conType.Spec.AllowExtraFields, path, "ConnectionType", nil)
+	}
+
+	issued := sets.NewString(con.Spec.CredentialIssuer.Fields...)
+	errList := validateCredentialIssuer(*con.Spec.CredentialIssuer, field.NewPath("spec").Child("credentialIssuer"))
+	for _, name := range sets.StringKeySet(con.Spec.Credentials).Intersection(issued).List() {
+		errList = append(errList, field.Invalid(path.Child(name), con.Spec.Credentials[name], "Field is issued by the credentialIssuer and cannot be set"))
+	}
+	return append(errList, validateCredentials(con.Spec.Credentials, conType.Spec.Fields, conType.Spec.AllowExtraFields, path, "ConnectionType", issued)...)
+}
+
+// validateCredentialIssuer validates that the CredentialIssuer provides fields and configures exactly one issuer
+func validateCredentialIssuer(ci v1alpha1.CredentialIssuer, path *field.Path) field.ErrorList {
+	var errList field.ErrorList
+	if len(ci.Fields) == 0 {
+		errList = append(errList, field.Required(path.Child("fields"), "the fields the issuer provides must be listed"))
+	}
+
+	switch {
+	case (ci.Vault == nil) == (ci.Webhook == nil):
+		errList = append(errList, field.Invalid(path, ci, "Exactly one of vault or webhook must be set"))
+	case ci.Vault != nil && ci.Vault.Role == "":
+		errList = append(errList, field.Required(path.Child("vault", "role"), ""))
+	case ci.Webhook != nil:
+		u, err := url.Parse(ci.Webhook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errList = append(errList, field.Invalid(path.Child("webhook", "url"), ci.Webhook.URL, "must be an http or https URL"))
 		}
 	}
 
+	if ci.DefaultTTL != nil && ci.DefaultTTL.Duration <= 0 {
+		errList = append(errList, field.Invalid(path.Child("defaultTTL"), ci.DefaultTTL.Duration.String(), "must be positive"))
+	}
 	return errList
 }
 
 // ValidateDataSet validates whether a v1alpha1.DataSet adheres to the definition
 // of its type defined by a v1alpha1.DataSetType
 func ValidateDataSet(ds v1alpha1.DataSet, dtype v1alpha1.DataSetType) field.ErrorList {
+	path := field.NewPath("spec").Child("metadata")
+	return validateCredentials(ds.Spec.Metadata, dtype.Spec.MetadataFields.Fields, dtype.Spec.MetadataFields.AllowExtraFields, path, "DataSetType", nil)
+}
+
+// validateCredentials validates the Credentials against the fields of their type, whose kind is used in the error messages.
+// Required fields must be set, unless they have a default, are derived or are issued, and every value must be valid for its field.
+// Derived fields can only have the plain text value the mutating web hook computes from the other values.
+func validateCredentials(creds v1alpha1.Credentials, fields []v1alpha1.CredentialFieldSpec, allowExtraFields bool, path *field.Path, typeKind string, issued sets.String) field.ErrorList {
 	// Transform to fieldmap for quick lookup
-	fieldMap := make(map[string]*v1alpha1.CredentialFieldSpec)
-	for _, credField := range dtype.Spec.MetadataFields.Fields {
+	fieldMap := make(map[string]*v1alpha1.CredentialFieldSpec, len(fields))
+	for _, credField := range fields {
 		c := credField
 		fieldMap[credField.Name] = &c
 	}
 
-	// Perform field validation
 	var errList field.ErrorList
-	for k, v := range ds.Spec.Metadata {
+	for _, credField := range fields {
+		if _, ok := creds[credField.Name]; !ok && credField.Required && !credField.HasDefault() && credField.Derived == "" && !issued.Has(credField.Name) {
+			errList = append(errList, field.Required(path.Child(credField.Name), "Field is required"))
+		}
+	}
+
+	derived := creds.DeepCopy()
+	derived.ApplyDerived(fields)
+
+	// Sort the keys, so the errors are reported in a stable order
+	keys := make([]string, 0, len(creds))
+	for k := range creds {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// Perform field validation
+	for _, k := range keys {
+		v := creds[k]
 		credField := fieldMap[k]
-		path := field.NewPath("spec").Child("metadata").Child(k)
+		fieldPath := path.Child(k)
 		if credField == nil {
-			if !dtype.Spec.MetadataFields.AllowExtraFields {
-				err := field.Invalid(path, v, "DataSetType does not allow extra fields")
+			if !allowExtraFields {
+				err := field.Invalid(fieldPath, v, fmt.Sprintf("%s does not allow extra fields", typeKind))
+				errList = append(errList, err)
+			}
+			continue
+		}
+
+		if credField.Derived != "" {
+			// only the value the mutating web hook computes is accepted
+			if computed, ok := derived[k]; !ok || v != computed {
+				err := field.Invalid(fieldPath, v, "Field is derived and cannot be set")
 				errList = append(errList, err)
 			}
 			continue
 		}
 
+		if errs := validateValueSource(v, fieldPath); errs != nil {
+			errList = append(errList, errs...)
+			continue
+		}
+
 		if credField.Sensitive {
 			if v.Value != "" || v.ValueFrom.ConfigMapKeyRef != nil {
-				err := field.Invalid(path, v, "Field is sensitive, only SecretKeyRef is allowed")
+				err := field.Invalid(fieldPath, v, "Field is sensitive, only SecretKeyRef or ExternalRef is allowed")
 				errList = append(errList, err)
 
 				continue
@@ -118,11 +290,66 @@ func ValidateDataSet(ds v1alpha1.DataSet, dtype v1alpha1.DataSetType) field.Erro
 		}
 
 		// Perform validation, not possible with reference valueFrom
-		if v.Value != "" {
-			errs := ValidateValue(v.Value, path, *credField.Validation)
+		if v.Value != "" && credField.Validation != nil {
+			errs := ValidateValue(v.Value, fieldPath, *credField.Validation)
 			errList = append(errList, errs...)
 		}
 	}
 
 	return errList
 }
+
+// validateValueSource validates that the Value is either set or refers to exactly one ConfigMap, Secret or external key
+func validateValueSource(v v1alpha1.Value, path *field.Path) field.ErrorList {
+	switch {
+	case v.ValueFrom == nil && v.Value == "":
+		return field.ErrorList{field.Required(path, "Either value or valueFrom must be set")}
+	case v.ValueFrom == nil:
+		return nil
+	case v.Value != "":
+		return field.ErrorList{field.Invalid(path, v, "Value and valueFrom are mutually exclusive")}
+	case countSources(v.ValueFrom) != 1:
+		return field.ErrorList{field.Invalid(path.Child("valueFrom"), v.ValueFrom, "Exactly one of configMapKeyRef, secretKeyRef or externalRef must be set")}
+	case v.ValueFrom.ExternalRef != nil:
+		return validateExternalRef(v.ValueFrom.ExternalRef, path.Child("valueFrom", "externalRef"))
+	}
+	return nil
+}
+
+func countSources(vs *v1alpha1.ValueSource) int {
+	count := 0
+	if vs.ConfigMapKeyRef != nil {
+		count++
+	}
+	if vs.SecretKeyRef != nil {
+		count++
+	}
+	if vs.ExternalRef != nil {
+		count++
+	}
+	return count
+}
+
+func validateExternalRef(ref *v1alpha1.ExternalSecretKeySelector, path *field.Path) field.ErrorList {
+	var errList field.ErrorList
+	switch ref.Backend {
+	case v1alpha1.SecretBackendVault, v1alpha1.SecretBackendFile:
+	case "":
+		errList = append(errList, field.Required(path.Child("backend"), ""))
+	default:
+		errList = append(errList, field.NotSupported(path.Child("backend"), ref.Backend, []string{string(v1alpha1.SecretBackendVault), string(v1alpha1.SecretBackendFile)}))
+	}
+	if ref.Path == "" {
+		errList = append(errList, field.Required(path.Child("path"), ""))
+	} else if err := v1alpha1.ValidateExternalPath(ref.Path); err != nil {
+		errList = append(errList, field.Invalid(path.Child("path"), ref.Path, err.Error()))
+	}
+	if ref.Key == "" {
+		errList = append(errList, field.Required(path.Child("key"), ""))
+	} else if ref.Backend == v1alpha1.SecretBackendFile {
+		if err := v1alpha1.ValidateExternalFileKey(ref.Key); err != nil {
+			errList = append(errList, field.Invalid(path.Child("key"), ref.Key, err.Error()))
+		}
+	}
+	return errList
+}
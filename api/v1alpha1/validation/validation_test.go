@@ -1,10 +1,15 @@
 package validation
 
 import (
+	"strings"
+	"time"
+
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 
 	v1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/utils/pointer"
@@ -184,7 +189,7 @@ var _ = Describe("ValidateConnection", func() {
 		It("should return one error indicating the field is sensitive", func() {
 			errs := ValidateConnection(con, conType)
 			Expect(errs).To(Not(BeNil()))
-			Expect(errs.ToAggregate().Error()).To(Equal("spec.credentials.password: Invalid value: v1alpha1.Value{Value:\"secret\", ValueFrom:(*v1alpha1.ValueSource)(nil)}: Field is sensitive, only SecretKeyRef is allowed"))
+			Expect(errs.ToAggregate().Error()).To(Equal("spec.credentials.password: Invalid value: v1alpha1.Value{Value:\"secret\", ValueFrom:(*v1alpha1.ValueSource)(nil)}: Field is sensitive, only SecretKeyRef or ExternalRef is allowed"))
 		})
 	})
 })
@@ -341,7 +346,310 @@ var _ = Describe("ValidateDataSet", func() {
 		It("should return one error indicating the field is sensitive", func() {
 			errs := ValidateDataSet(ds, dsType)
 			Expect(errs).To(Not(BeNil()))
-			Expect(errs.ToAggregate().Error()).To(Equal("spec.metadata.metadata_db_url: Invalid value: v1alpha1.Value{Value:\"secret\", ValueFrom:(*v1alpha1.ValueSource)(nil)}: Field is sensitive, only SecretKeyRef is allowed"))
+			Expect(errs.ToAggregate().Error()).To(Equal("spec.metadata.metadata_db_url: Invalid value: v1alpha1.Value{Value:\"secret\", ValueFrom:(*v1alpha1.ValueSource)(nil)}: Field is sensitive, only SecretKeyRef or ExternalRef is allowed"))
 		})
 	})
 })
+
+var _ = Describe("Validating derived fields", func() {
+	It("should reject a Connection that sets a derived field", func() {
+		conType := v1alpha1.ConnectionType{
+			Spec: v1alpha1.ConnectionTypeSpec{
+				Fields: []v1alpha1.CredentialFieldSpec{
+					{Name: "host", Validation: &v1alpha1.Validation{}},
+					{Name: "url", Derived: "mysql://{{.host}}"},
+				},
+			},
+		}
+		con := v1alpha1.Connection{
+			Spec: v1alpha1.ConnectionSpec{
+				Credentials: v1alpha1.Credentials{
+					"host": v1alpha1.Value{Value: "localhost"},
+					"url":  v1alpha1.Value{Value: "mysql://elsewhere"},
+				},
+			},
+		}
+		errs := ValidateConnection(con, conType)
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Field).To(Equal("spec.credentials.url"))
+		Expect(errs[0].Detail).To(Equal("Field is derived and cannot be set"))
+	})
+
+	It("should accept the derived value computed by the mutating web hook", func() {
+		conType := v1alpha1.ConnectionType{
+			Spec: v1alpha1.ConnectionTypeSpec{
+				Fields: []v1alpha1.CredentialFieldSpec{
+					{Name: "host"},
+					{Name: "url", Derived: "mysql://{{.host}}"},
+				},
+			},
+		}
+		con := v1alpha1.Connection{
+			Spec: v1alpha1.ConnectionSpec{
+				Credentials: v1alpha1.Credentials{"host": v1alpha1.Value{Value: "localhost"}},
+			},
+		}
+		Expect(con.Spec.Credentials.ApplyDerived(conType.Spec.Fields)).To(BeTrue())
+		Expect(ValidateConnection(con, conType)).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Validating Credentials against their fields", func() {
+	secretRef := &v1alpha1.ValueSource{
+		SecretKeyRef: &v1.SecretKeySelector{Key: "password"},
+	}
+	fields := []v1alpha1.CredentialFieldSpec{
+		{Name: "host", Required: true},
+		{Name: "port", Required: true, Default: "3306"},
+		{Name: "username", Validation: &v1alpha1.Validation{MinLength: pointer.Int32Ptr(3)}},
+		{Name: "password", Required: true, Sensitive: true},
+		{Name: "url", Required: true, Derived: "mysql://{{.host}}"},
+	}
+	conType := v1alpha1.ConnectionType{Spec: v1alpha1.ConnectionTypeSpec{Fields: fields}}
+	dsType := v1alpha1.DataSetType{Spec: v1alpha1.DataSetTypeSpec{MetadataFields: v1alpha1.MetadataValidation{Fields: fields}}}
+
+	// fieldErrors returns the field and detail of each error relative to the prefix
+	fieldErrors := func(errs field.ErrorList, prefix string) []string {
+		var res []string
+		for _, err := range errs {
+			res = append(res, strings.TrimPrefix(err.Field, prefix)+": "+err.Detail)
+		}
+		return res
+	}
+
+	DescribeTable("ValidateConnection and ValidateDataSet",
+		func(creds v1alpha1.Credentials, expected []string) {
+			con := v1alpha1.Connection{Spec: v1alpha1.ConnectionSpec{Credentials: creds}}
+			Expect(fieldErrors(ValidateConnection(con, conType), "spec.credentials.")).To(Equal(expected))
+
+			ds := v1alpha1.DataSet{Spec: v1alpha1.DataSetSpec{Metadata: creds}}
+			Expect(fieldErrors(ValidateDataSet(ds, dsType), "spec.metadata.")).To(Equal(expected))
+		},
+		Entry("valid credentials",
+			v1alpha1.Credentials{
+				"host":     {Value: "localhost"},
+				"password": {ValueFrom: secretRef},
+			},
+			nil,
+		),
+		Entry("missing required fields",
+			v1alpha1.Credentials{},
+			[]string{
+				"host: Field is required",
+				"password: Field is required",
+			},
+		),
+		Entry("an empty value",
+			v1alpha1.Credentials{
+				"host":     {},
+				"password": {ValueFrom: secretRef},
+			},
+			[]string{"host: Either value or valueFrom must be set"},
+		),
+		Entry("both a value and valueFrom",
+			v1alpha1.Credentials{
+				"host":     {Value: "localhost", ValueFrom: secretRef},
+				"password": {ValueFrom: secretRef},
+			},
+			[]string{"host: Value and valueFrom are mutually exclusive"},
+		),
+		Entry("a valueFrom without a reference",
+			v1alpha1.Credentials{
+				"host":     {Value: "localhost"},
+				"password": {ValueFrom: &v1alpha1.ValueSource{}},
+			},
+			[]string{"password.valueFrom: Exactly one of configMapKeyRef, secretKeyRef or externalRef must be set"},
+		),
+		Entry("a valueFrom with both references",
+			v1alpha1.Credentials{
+				"host": {Value: "localhost"},
+				"password": {ValueFrom: &v1alpha1.ValueSource{
+					SecretKeyRef:    secretRef.SecretKeyRef,
+					ConfigMapKeyRef: &v1.ConfigMapKeySelector{Key: "password"},
+				}},
+			},
+			[]string{"password.valueFrom: Exactly one of configMapKeyRef, secretKeyRef or externalRef must be set"},
+		),
+		Entry("a sensitive field in an external secret backend",
+			v1alpha1.Credentials{
+				"host": {Value: "localhost"},
+				"password": {ValueFrom: &v1alpha1.ValueSource{
+					ExternalRef: &v1alpha1.ExternalSecretKeySelector{Backend: v1alpha1.SecretBackendVault, Path: "mysql", Key: "password"},
+				}},
+			},
+			nil,
+		),
+		Entry("an incomplete externalRef",
+			v1alpha1.Credentials{
+				"host": {Value: "localhost"},
+				"password": {ValueFrom: &v1alpha1.ValueSource{
+					ExternalRef: &v1alpha1.ExternalSecretKeySelector{Backend: "Keychain"},
+				}},
+			},
+			[]string{
+				`password.valueFrom.externalRef.backend: supported values: "Vault", "File"`,
+				"password.valueFrom.externalRef.path: ",
+				"password.valueFrom.externalRef.key: ",
+			},
+		),
+		Entry("an externalRef outside of the directory of the namespace",
+			v1alpha1.Credentials{
+				"host": {Value: "localhost"},
+				"password": {ValueFrom: &v1alpha1.ValueSource{
+					ExternalRef: &v1alpha1.ExternalSecretKeySelector{Backend: v1alpha1.SecretBackendFile, Path: "mysql/../../other", Key: "../password"},
+				}},
+				"username": {ValueFrom: &v1alpha1.ValueSource{
+					ExternalRef: &v1alpha1.ExternalSecretKeySelector{Backend: v1alpha1.SecretBackendVault, Path: "/other/mysql", Key: "username"},
+				}},
+			},
+			[]string{
+				"password.valueFrom.externalRef.path: path must not refer to a parent directory",
+				"password.valueFrom.externalRef.key: key must be a file name",
+				"username.valueFrom.externalRef.path: path must be relative",
+			},
+		),
+		Entry("a value that does not pass its validation",
+			v1alpha1.Credentials{
+				"host":     {Value: "localhost"},
+				"username": {Value: "me"},
+				"password": {ValueFrom: secretRef},
+			},
+			[]string{"username: Value below MinLength"},
+		),
+		Entry("a sensitive field in plain text",
+			v1alpha1.Credentials{
+				"host":     {Value: "localhost"},
+				"password": {Value: "secret"},
+			},
+			[]string{"password: Field is sensitive, only SecretKeyRef or ExternalRef is allowed"},
+		),
+		Entry("a derived field",
+			v1alpha1.Credentials{
+				"host":     {Value: "localhost"},
+				"password": {ValueFrom: secretRef},
+				"url":      {Value: "mysql://elsewhere"},
+			},
+			[]string{"url: Field is derived and cannot be set"},
+		),
+		Entry("multiple errors in key order",
+			v1alpha1.Credentials{
+				"username": {Value: "me"},
+				"host":     {},
+			},
+			[]string{
+				"password: Field is required",
+				"host: Either value or valueFrom must be set",
+				"username: Value below MinLength",
+			},
+		),
+	)
+})
+
+var _ = Describe("Validating a CredentialIssuer", func() {
+	conType := v1alpha1.ConnectionType{
+		Spec: v1alpha1.ConnectionTypeSpec{
+			Fields: []v1alpha1.CredentialFieldSpec{
+				{Name: "host", Required: true},
+				{Name: "username", Required: true},
+				{Name: "password", Required: true, Sensitive: true},
+			},
+		},
+	}
+	vault := &v1alpha1.VaultCredentialIssuer{Role: "etl"}
+	webhook := &v1alpha1.WebhookCredentialIssuer{URL: "https://issuer.example.com"}
+
+	DescribeTable("ValidateConnection",
+		func(creds v1alpha1.Credentials, ci v1alpha1.CredentialIssuer, expected []string) {
+			con := v1alpha1.Connection{Spec: v1alpha1.ConnectionSpec{Credentials: creds, CredentialIssuer: &ci}}
+			var res []string
+			for _, err := range ValidateConnection(con, conType) {
+				res = append(res, err.Field+": "+err.Detail)
+			}
+			Expect(res).To(Equal(expected))
+		},
+		Entry("issued fields that are not set",
+			v1alpha1.Credentials{"host": {Value: "localhost"}},
+			v1alpha1.CredentialIssuer{Fields: []string{"username", "password"}, Vault: vault},
+			nil,
+		),
+		Entry("an issued field that is also set",
+			v1alpha1.Credentials{"host": {Value: "localhost"}, "username": {Value: "etl"}},
+			v1alpha1.CredentialIssuer{Fields: []string{"username", "password"}, Webhook: webhook},
+			[]string{"spec.credentials.username: Field is issued by the credentialIssuer and cannot be set"},
+		),
+		Entry("a required field that is not issued",
+			v1alpha1.Credentials{"host": {Value: "localhost"}},
+			v1alpha1.CredentialIssuer{Fields: []string{"password"}, Vault: vault},
+			[]string{"spec.credentials.username: Field is required"},
+		),
+		Entry("an issuer without fields",
+			v1alpha1.Credentials{"host": {Value: "localhost"}, "username": {Value: "etl"}, "password": {Value: "secret"}},
+			v1alpha1.CredentialIssuer{Vault: vault},
+			[]string{
+				"spec.credentialIssuer.fields: the fields the issuer provides must be listed",
+				"spec.credentials.password: Field is sensitive, only SecretKeyRef or ExternalRef is allowed",
+			},
+		),
+		Entry("both vault and webhook",
+			v1alpha1.Credentials{"host": {Value: "localhost"}},
+			v1alpha1.CredentialIssuer{Fields: []string{"username", "password"}, Vault: vault, Webhook: webhook},
+			[]string{"spec.credentialIssuer: Exactly one of vault or webhook must be set"},
+		),
+		Entry("neither vault nor webhook",
+			v1alpha1.Credentials{"host": {Value: "localhost"}},
+			v1alpha1.CredentialIssuer{Fields: []string{"username", "password"}},
+			[]string{"spec.credentialIssuer: Exactly one of vault or webhook must be set"},
+		),
+		Entry("a vault issuer without a role",
+			v1alpha1.Credentials{"host": {Value: "localhost"}},
+			v1alpha1.CredentialIssuer{Fields: []string{"username", "password"}, Vault: &v1alpha1.VaultCredentialIssuer{}},
+			[]string{"spec.credentialIssuer.vault.role: "},
+		),
+		Entry("a webhook that is not an http URL",
+			v1alpha1.Credentials{"host": {Value: "localhost"}},
+			v1alpha1.CredentialIssuer{Fields: []string{"username", "password"}, Webhook: &v1alpha1.WebhookCredentialIssuer{URL: "ftp://issuer.example.com"}},
+			[]string{"spec.credentialIssuer.webhook.url: must be an http or https URL"},
+		),
+		Entry("a negative default TTL",
+			v1alpha1.Credentials{"host": {Value: "localhost"}},
+			v1alpha1.CredentialIssuer{Fields: []string{"username", "password"}, Vault: vault, DefaultTTL: &metav1.Duration{Duration: -time.Minute}},
+			[]string{"spec.credentialIssuer.defaultTTL: must be positive"},
+		),
+	)
+})
+
+var _ = Describe("ValidateResolvedValues", func() {
+	fields := []v1alpha1.CredentialFieldSpec{
+		{Name: "password", Validation: &v1alpha1.Validation{MinLength: pointer.Int32Ptr(12)}},
+		{Name: "username"},
+	}
+	path := field.NewPath("spec").Child("credentials")
+
+	It("Should accept valid values", func() {
+		values := map[string]string{"password": "long-enough-secret", "username": "x"}
+		Expect(ValidateResolvedValues(values, fields, path)).To(BeEmpty())
+	})
+
+	It("Should not reveal invalid values", func() {
+		values := map[string]string{"password": "short"}
+		errs := ValidateResolvedValues(values, fields, path)
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Field).To(Equal("spec.credentials.password"))
+		Expect(errs.ToAggregate().Error()).ToNot(ContainSubstring("short"))
+	})
+
+	It("Should not reveal the keys or values of a value validated against a JSON Schema", func() {
+		schemaFields := []v1alpha1.CredentialFieldSpec{{
+			Name: "tokens",
+			Validation: &v1alpha1.Validation{JSONSchema: &apiextensionsv1.JSON{
+				Raw: []byte(`{"type": "object", "additionalProperties": {"type": "integer"}}`),
+			}},
+		}}
+		values := map[string]string{"tokens": `{"admin-token": "s3cr3t"}`}
+		errs := ValidateResolvedValues(values, schemaFields, path)
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Field).To(Equal("spec.credentials.tokens"))
+		Expect(errs.ToAggregate().Error()).ToNot(ContainSubstring("admin-token"))
+		Expect(errs.ToAggregate().Error()).ToNot(ContainSubstring("s3cr3t"))
+	})
+})
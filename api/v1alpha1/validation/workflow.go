@@ -0,0 +1,184 @@
+package validation
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"sort"
+
+	utilvalidation "k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+// ValidateWorkflowSpec validates the InjectableValues and InjectInto entries of a v1alpha1.WorkflowSpec.
+// It returns a field.ErrorList based on the path of the WorkflowSpec.
+func ValidateWorkflowSpec(wfs v1alpha1.WorkflowSpec, path *field.Path) field.ErrorList {
+	var errList field.ErrorList
+
+	// the rendered files share the connection secret with the Content of the other InjectableValues
+	secretKeys := make(map[string]bool, len(wfs.InjectableValues))
+	for _, iv := range wfs.InjectableValues {
+		if len(iv.Files) == 0 {
+			secretKeys[iv.Name] = true
+		}
+	}
+	for i, iv := range wfs.InjectableValues {
+		for _, filename := range sortedFilenames(iv) {
+			key := iv.FileKey(filename)
+			if secretKeys[key] {
+				errList = append(errList, field.Duplicate(path.Child("injectable").Index(i).Child("files").Key(filename), key))
+			}
+			secretKeys[key] = true
+		}
+	}
+
+	ivNames := make(map[string]bool, len(wfs.InjectableValues))
+	for i, iv := range wfs.InjectableValues {
+		ivPath := path.Child("injectable").Index(i)
+		if iv.Name == "" {
+			errList = append(errList, field.Required(ivPath.Child("name"), "InjectableValue must have a name"))
+		} else if ivNames[iv.Name] {
+			errList = append(errList, field.Duplicate(ivPath.Child("name"), iv.Name))
+		}
+		ivNames[iv.Name] = true
+
+		errList = append(errList, ValidateInjectableValue(iv, ivPath)...)
+	}
+
+	templates := make(map[string]bool, len(wfs.ArgoWorkflowSpec.Templates))
+	for _, t := range wfs.ArgoWorkflowSpec.Templates {
+		templates[t.Name] = true
+	}
+
+	for i, ii := range wfs.InjectInto {
+		iiPath := path.Child("injectInto").Index(i)
+		if !templates[ii.Name] {
+			errList = append(errList, field.NotFound(iiPath.Child("name"), ii.Name))
+		}
+		for j, name := range ii.InjectedValues {
+			if !ivNames[name] {
+				errList = append(errList, field.NotFound(iiPath.Child("inject").Index(j), name))
+			}
+		}
+	}
+
+	return errList
+}
+
+// ValidateInjectableValue validates that the InjectableValue refers to exactly one Connection or DataSet,
+// is injected in exactly one way and has either Content or Files templates that parse.
+// An InjectableValue that expands the fields of a Connection has no other means of injection.
+func ValidateInjectableValue(iv v1alpha1.InjectableValue, path *field.Path) field.ErrorList {
+	var errList field.ErrorList
+
+	hasConn := iv.ConnectionRef.Name != ""
+	hasDataSet := iv.DataSetRef.Name != ""
+	if hasConn == hasDataSet {
+		errList = append(errList, field.Invalid(path, iv.Name, "exactly one of connectionRef or dataSetRef must be set"))
+	}
+
+	hasEnv := iv.EnvName != ""
+	hasMount := iv.MountPath != ""
+	if iv.ExpandFields {
+		if hasDataSet {
+			errList = append(errList, field.Invalid(path.Child("expandFields"), iv.ExpandFields, "only the fields of a connectionRef can be expanded"))
+		}
+		if iv.ConnectionRef.Namespace != "" {
+			errList = append(errList, field.Invalid(path.Child("connectionRef").Child("namespace"), iv.ConnectionRef.Namespace, "the fields of a Connection in another namespace cannot be expanded"))
+		}
+		if hasEnv || hasMount || iv.Content != "" || len(iv.Files) > 0 {
+			errList = append(errList, field.Invalid(path.Child("expandFields"), iv.ExpandFields, "envName, mountPath, content and files cannot be combined with expandFields"))
+		}
+		return errList
+	}
+	if hasEnv == hasMount {
+		errList = append(errList, field.Invalid(path, iv.Name, "exactly one of envName or mountPath must be set"))
+	}
+
+	switch {
+	case iv.Content == "" && len(iv.Files) == 0:
+		errList = append(errList, field.Required(path.Child("content"), "one of content or files must be set"))
+	case iv.Content != "" && len(iv.Files) > 0:
+		errList = append(errList, field.Invalid(path, iv.Name, "content and files are mutually exclusive"))
+	}
+	if len(iv.Files) > 0 {
+		for _, filename := range sortedFilenames(iv) {
+			for _, msg := range utilvalidation.IsConfigMapKey(filename) {
+				errList = append(errList, field.Invalid(path.Child("files").Key(filename), filename, msg))
+			}
+		}
+	}
+
+	if iv.FileMode != nil && (*iv.FileMode < 0 || *iv.FileMode > 0777) {
+		errList = append(errList, field.Invalid(path.Child("fileMode"), *iv.FileMode, "must be a number between 0 and 0777 (octal)"))
+	}
+
+	for _, t := range injectableTemplates(iv, path) {
+		if _, err := t.content.Parse(); err != nil {
+			errList = append(errList, field.Invalid(t.path, string(t.content), err.Error()))
+		}
+	}
+
+	return errList
+}
+
+// injectableTemplate is a template of an InjectableValue together with its path
+type injectableTemplate struct {
+	content v1alpha1.ContentTemplate
+	path    *field.Path
+}
+
+// injectableTemplates returns the Content or the Files templates of the InjectableValue
+func injectableTemplates(iv v1alpha1.InjectableValue, path *field.Path) []injectableTemplate {
+	if len(iv.Files) == 0 {
+		return []injectableTemplate{{content: iv.Content, path: path.Child("content")}}
+	}
+
+	templates := make([]injectableTemplate, 0, len(iv.Files))
+	for _, filename := range sortedFilenames(iv) {
+		templates = append(templates, injectableTemplate{content: iv.Files[filename], path: path.Child("files").Key(filename)})
+	}
+	return templates
+}
+
+func sortedFilenames(iv v1alpha1.InjectableValue) []string {
+	filenames := make([]string, 0, len(iv.Files))
+	for filename := range iv.Files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	return filenames
+}
+
+// PlaceholderValues returns a placeholder value for every field name, e.g. of EffectiveFieldNames,
+// which allows Content templates to be rendered without reading the actual (possibly secret) values.
+// The placeholders are the base64 encoded key names, so they are accepted by every template function (including b64dec).
+func PlaceholderValues(names []string) map[string]string {
+	values := make(map[string]string, len(names))
+	for _, name := range names {
+		values[name] = placeholder(name)
+	}
+	return values
+}
+
+func placeholder(key string) string {
+	return base64.StdEncoding.EncodeToString([]byte(key))
+}
+
+// ValidateContent renders the Content or Files of the InjectableValue with the given data and reports
+// references to keys that are missing from the data. Templates that do not parse are reported by ValidateInjectableValue.
+func ValidateContent(iv v1alpha1.InjectableValue, data interface{}, path *field.Path) field.ErrorList {
+	var errList field.ErrorList
+	for _, t := range injectableTemplates(iv, path) {
+		tmpl, err := t.content.Parse()
+		if err != nil {
+			continue
+		}
+
+		if err := tmpl.Execute(ioutil.Discard, data); err != nil {
+			errList = append(errList, field.Invalid(t.path, string(t.content), err.Error()))
+		}
+	}
+	return errList
+}
@@ -0,0 +1,312 @@
+package validation
+
+import (
+	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+var _ = Describe("ValidateWorkflowSpec", func() {
+	var wfs v1alpha1.WorkflowSpec
+	path := field.NewPath("spec")
+
+	BeforeEach(func() {
+		wfs = v1alpha1.WorkflowSpec{
+			InjectableValues: v1alpha1.InjectableValues{
+				{
+					Name:          "password",
+					ConnectionRef: v1alpha1.ConnectionReference{Name: "mysql"},
+					EnvName:       "PASSWORD",
+					Content:       "{{.password}}",
+				},
+			},
+			InjectInto: []v1alpha1.TemplateRef{
+				{
+					Name:           "main",
+					InjectedValues: []string{"password"},
+				},
+			},
+			ArgoWorkflowSpec: wfv1.WorkflowSpec{
+				Templates: []wfv1.Template{{Name: "main"}},
+			},
+		}
+	})
+
+	Context("Validating a correct v1alpha1.WorkflowSpec", func() {
+		It("should return no errors", func() {
+			Expect(ValidateWorkflowSpec(wfs, path)).To(BeEmpty())
+		})
+	})
+
+	Context("Validating a v1alpha1.WorkflowSpec with duplicate InjectableValue names", func() {
+		It("should return a duplicate error", func() {
+			wfs.InjectableValues = append(wfs.InjectableValues, wfs.InjectableValues[0])
+			errs := ValidateWorkflowSpec(wfs, path)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Type).To(Equal(field.ErrorTypeDuplicate))
+			Expect(errs[0].Field).To(Equal("spec.injectable[1].name"))
+		})
+	})
+
+	Context("Validating a v1alpha1.WorkflowSpec injecting into an unknown template", func() {
+		It("should return a not found error", func() {
+			wfs.InjectInto[0].Name = "unknown"
+			errs := ValidateWorkflowSpec(wfs, path)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Type).To(Equal(field.ErrorTypeNotFound))
+			Expect(errs[0].Field).To(Equal("spec.injectInto[0].name"))
+		})
+	})
+
+	Context("Validating a v1alpha1.WorkflowSpec injecting an unknown InjectableValue", func() {
+		It("should return a not found error", func() {
+			wfs.InjectInto[0].InjectedValues = []string{"password", "username"}
+			errs := ValidateWorkflowSpec(wfs, path)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Type).To(Equal(field.ErrorTypeNotFound))
+			Expect(errs[0].Field).To(Equal("spec.injectInto[0].inject[1]"))
+		})
+	})
+})
+
+var _ = Describe("ValidateWorkflowSpec with Files", func() {
+	path := field.NewPath("spec")
+
+	It("should report files that collide with the key of another InjectableValue", func() {
+		wfs := v1alpha1.WorkflowSpec{
+			InjectableValues: v1alpha1.InjectableValues{
+				{
+					Name:          "dbt.profiles.yml",
+					ConnectionRef: v1alpha1.ConnectionReference{Name: "mysql"},
+					EnvName:       "PROFILES",
+					Content:       "{{.host}}",
+				},
+				{
+					Name:          "dbt",
+					ConnectionRef: v1alpha1.ConnectionReference{Name: "mysql"},
+					MountPath:     "/home/dbt/.dbt",
+					Files:         map[string]v1alpha1.ContentTemplate{"profiles.yml": "host: {{.host}}"},
+				},
+			},
+		}
+		errs := ValidateWorkflowSpec(wfs, path)
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Type).To(Equal(field.ErrorTypeDuplicate))
+		Expect(errs[0].Field).To(Equal("spec.injectable[1].files[profiles.yml]"))
+	})
+})
+
+var _ = Describe("ValidateInjectableValue", func() {
+	path := field.NewPath("spec").Child("injectable").Index(0)
+
+	Context("Validating an InjectableValue referring to a Connection and a DataSet", func() {
+		It("should return an error", func() {
+			iv := v1alpha1.InjectableValue{
+				Name:          "password",
+				ConnectionRef: v1alpha1.ConnectionReference{Name: "mysql"},
+				DataSetRef:    v1.LocalObjectReference{Name: "users"},
+				EnvName:       "PASSWORD",
+				Content:       "{{.password}}",
+			}
+			errs := ValidateInjectableValue(iv, path)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Detail).To(Equal("exactly one of connectionRef or dataSetRef must be set"))
+		})
+	})
+
+	Context("Validating an InjectableValue without an envName or mountPath", func() {
+		It("should return an error", func() {
+			iv := v1alpha1.InjectableValue{
+				Name:       "users",
+				DataSetRef: v1.LocalObjectReference{Name: "users"},
+				Content:    "{{.table}}",
+			}
+			errs := ValidateInjectableValue(iv, path)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Detail).To(Equal("exactly one of envName or mountPath must be set"))
+		})
+	})
+
+	Context("Validating an InjectableValue without content or files", func() {
+		It("should return an error", func() {
+			iv := v1alpha1.InjectableValue{
+				Name:          "password",
+				ConnectionRef: v1alpha1.ConnectionReference{Name: "mysql"},
+				EnvName:       "PASSWORD",
+			}
+			errs := ValidateInjectableValue(iv, path)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Type).To(Equal(field.ErrorTypeRequired))
+			Expect(errs[0].Field).To(Equal("spec.injectable[0].content"))
+		})
+	})
+
+	Context("Validating an InjectableValue with a Content template that does not parse", func() {
+		It("should return an error", func() {
+			iv := v1alpha1.InjectableValue{
+				Name:          "password",
+				ConnectionRef: v1alpha1.ConnectionReference{Name: "mysql"},
+				MountPath:     "/etc/mysql",
+				Content:       "{{.password",
+			}
+			errs := ValidateInjectableValue(iv, path)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Field).To(Equal("spec.injectable[0].content"))
+		})
+	})
+})
+
+var _ = Describe("ValidateContent", func() {
+	path := field.NewPath("spec").Child("injectable").Index(0)
+	creds := v1alpha1.Credentials{
+		"username": v1alpha1.Value{Value: "admin"},
+		"password": v1alpha1.Value{
+			ValueFrom: &v1alpha1.ValueSource{
+				SecretKeyRef: &v1.SecretKeySelector{Key: "password"},
+			},
+		},
+	}
+
+	Context("Rendering Content that refers to existing keys", func() {
+		It("should return no errors", func() {
+			iv := v1alpha1.InjectableValue{Content: "{{.username}}:{{.password}}"}
+			Expect(ValidateContent(iv, PlaceholderValues(v1alpha1.EffectiveFieldNames(creds, nil, nil)), path)).To(BeEmpty())
+		})
+	})
+
+	Context("Rendering Content that refers to a missing key", func() {
+		It("should return an error", func() {
+			iv := v1alpha1.InjectableValue{Content: "{{.user}}:{{.password}}"}
+			errs := ValidateContent(iv, PlaceholderValues(v1alpha1.EffectiveFieldNames(creds, nil, nil)), path)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Field).To(Equal("spec.injectable[0].content"))
+			Expect(errs[0].Detail).To(ContainSubstring(`map has no entry for key "user"`))
+		})
+	})
+
+	Context("Rendering DataSet Content", func() {
+		metadata := v1alpha1.Credentials{"host": v1alpha1.Value{Value: "mysql"}}
+
+		It("should accept references to the metadata and Connection", func() {
+			iv := v1alpha1.InjectableValue{Content: "{{.connection.username}}@{{.metadata.host}}"}
+			data := v1alpha1.DataSetTemplateData(PlaceholderValues(v1alpha1.EffectiveFieldNames(metadata, nil, nil)), PlaceholderValues(v1alpha1.EffectiveFieldNames(creds, nil, nil)))
+			Expect(ValidateContent(iv, data, path)).To(BeEmpty())
+		})
+
+		It("should return an error when the DataSet has no Connection", func() {
+			iv := v1alpha1.InjectableValue{Content: "{{.connection.username}}@{{.metadata.host}}"}
+			data := v1alpha1.DataSetTemplateData(PlaceholderValues(v1alpha1.EffectiveFieldNames(metadata, nil, nil)), nil)
+			Expect(ValidateContent(iv, data, path)).To(HaveLen(1))
+		})
+	})
+
+	Context("Rendering Content that refers to a derived field", func() {
+		It("should return no errors", func() {
+			iv := v1alpha1.InjectableValue{Content: "{{.url}}"}
+			fields := []v1alpha1.CredentialFieldSpec{{Name: "url", Derived: "mysql://{{.username}}"}}
+			Expect(ValidateContent(iv, PlaceholderValues(v1alpha1.EffectiveFieldNames(creds, nil, fields)), path)).To(BeEmpty())
+		})
+	})
+
+	Context("Rendering Content that does not parse", func() {
+		It("should leave the error to ValidateInjectableValue", func() {
+			iv := v1alpha1.InjectableValue{Content: "{{.username"}
+			Expect(ValidateContent(iv, PlaceholderValues(v1alpha1.EffectiveFieldNames(creds, nil, nil)), path)).To(BeEmpty())
+		})
+	})
+
+	Context("Validating an InjectableValue with Files", func() {
+		var iv v1alpha1.InjectableValue
+
+		BeforeEach(func() {
+			iv = v1alpha1.InjectableValue{
+				Name:          "dbt",
+				ConnectionRef: v1alpha1.ConnectionReference{Name: "mysql"},
+				MountPath:     "/home/dbt/.dbt",
+				Files:         map[string]v1alpha1.ContentTemplate{"profiles.yml": "host: {{.host}}"},
+			}
+		})
+
+		It("should return no errors", func() {
+			Expect(ValidateInjectableValue(iv, path)).To(BeEmpty())
+		})
+
+		It("should reject Content next to Files", func() {
+			iv.Content = "{{.host}}"
+			errs := ValidateInjectableValue(iv, path)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Detail).To(Equal("content and files are mutually exclusive"))
+		})
+
+		It("should reject file names that are not valid secret keys", func() {
+			iv.Files["config/profiles.yml"] = ""
+			errs := ValidateInjectableValue(iv, path)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Field).To(Equal("spec.injectable[0].files[config/profiles.yml]"))
+		})
+
+		It("should reject file templates that do not parse", func() {
+			iv.Files["config.json"] = "{{.host"
+			errs := ValidateInjectableValue(iv, path)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Field).To(Equal("spec.injectable[0].files[config.json]"))
+		})
+
+		It("should reject an invalid file mode", func() {
+			mode := int32(01000)
+			iv.FileMode = &mode
+			errs := ValidateInjectableValue(iv, path)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Field).To(Equal("spec.injectable[0].fileMode"))
+		})
+	})
+
+	Context("Validating an InjectableValue that expands the fields of a Connection", func() {
+		It("should return no errors", func() {
+			iv := v1alpha1.InjectableValue{
+				Name:          "mysql",
+				ConnectionRef: v1alpha1.ConnectionReference{Name: "mysql"},
+				ExpandFields:  true,
+			}
+			Expect(ValidateInjectableValue(iv, path)).To(BeEmpty())
+		})
+
+		It("should reject other means of injection", func() {
+			iv := v1alpha1.InjectableValue{
+				Name:          "mysql",
+				ConnectionRef: v1alpha1.ConnectionReference{Name: "mysql"},
+				ExpandFields:  true,
+				EnvName:       "MYSQL",
+			}
+			errs := ValidateInjectableValue(iv, path)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Field).To(Equal("spec.injectable[0].expandFields"))
+		})
+
+		It("should reject a DataSet", func() {
+			iv := v1alpha1.InjectableValue{
+				Name:         "users",
+				DataSetRef:   v1.LocalObjectReference{Name: "users"},
+				ExpandFields: true,
+			}
+			errs := ValidateInjectableValue(iv, path)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Detail).To(Equal("only the fields of a connectionRef can be expanded"))
+		})
+
+		It("should reject a Connection in another namespace", func() {
+			iv := v1alpha1.InjectableValue{
+				Name:          "mysql",
+				ConnectionRef: v1alpha1.ConnectionReference{Name: "mysql", Namespace: "platform"},
+				ExpandFields:  true,
+			}
+			errs := ValidateInjectableValue(iv, path)
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Field).To(Equal("spec.injectable[0].connectionRef.namespace"))
+		})
+	})
+})
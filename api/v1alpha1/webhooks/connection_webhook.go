@@ -18,9 +18,13 @@ package webhooks
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -65,15 +69,23 @@ func (hook *connectionValidatorHook) Handle(ctx context.Context, req admission.R
 	if err := hook.decoder.Decode(req, &con); err != nil {
 		return admission.Errored(http.StatusBadRequest, fmt.Errorf("unable to decode admission request: %w", err))
 	}
-
-	conType, err := hook.connectionTypeLister.Find(ctx, req.Namespace, con.Spec.Type)
-	if err != nil {
-		return admission.Errored(http.StatusBadRequest, fmt.Errorf("unable to list ConnectionType: %w", err))
+	if req.Operation == admissionv1.Update {
+		old := v1alpha1.Connection{}
+		if err := hook.decoder.DecodeRaw(req.OldObject, &old); err != nil {
+			return admission.Errored(http.StatusBadRequest, fmt.Errorf("unable to decode old object: %w", err))
+		}
+		if skipValidation(&con, old.Spec, con.Spec) {
+			return admission.Allowed("Connection is being deleted or its spec is unchanged")
+		}
 	}
 
-	if conType == nil {
+	conType, err := hook.connectionTypeLister.Find(ctx, req.Namespace, con.Spec.Type)
+	if errors.IsNotFound(err) {
 		return admission.Errored(http.StatusBadRequest, fmt.Errorf("Unknown ConnectionType: %v", con.Spec.Type))
 	}
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to get ConnectionType: %w", err))
+	}
 
 	errs := validation.ValidateConnection(con, *conType)
 	if errs != nil {
@@ -82,3 +94,73 @@ func (hook *connectionValidatorHook) Handle(ctx context.Context, req admission.R
 
 	return admission.Allowed("valid Connection resource passed to the API")
 }
+
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-v1alpha1-connection,mutating=true,failurePolicy=fail,groups=etl.dataworkz.nl,resources=connections,versions=v1alpha1,sideEffects=None,name=mconnection.dataworkz.nl,admissionReviewVersions=v1beta1
+
+// SetupMutatingConnectionWebhookWithManager registers the mutating web hook that applies the defaults
+// and computes the derived fields of the ConnectionType of Connections with the manager
+func SetupMutatingConnectionWebhookWithManager(mgr ctrl.Manager) error {
+	client := mgr.GetClient()
+	decoder, err := admission.NewDecoder(mgr.GetScheme())
+	if err != nil {
+		return fmt.Errorf("unable to create decoder: %w", err)
+	}
+	hook := &connectionDefaulterHook{
+		decoder:              decoder,
+		connectionTypeLister: listers.NewConnectionTypeLister(client),
+	}
+
+	hookserver := mgr.GetWebhookServer()
+	hookserver.Register("/mutate-v1alpha1-connection", &admission.Webhook{Handler: hook})
+	return nil
+}
+
+type connectionDefaulterHook struct {
+	decoder              *admission.Decoder
+	connectionTypeLister listers.ConnectionTypeLister
+}
+
+func (hook *connectionDefaulterHook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	log := logf.Log.WithName("webhooks").WithName("mutate-connection")
+	log.Info("Admission webhook request")
+	con := v1alpha1.Connection{}
+	if err := hook.decoder.Decode(req, &con); err != nil {
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("unable to decode admission request: %w", err))
+	}
+	if req.Operation == admissionv1.Update {
+		old := v1alpha1.Connection{}
+		if err := hook.decoder.DecodeRaw(req.OldObject, &old); err != nil {
+			return admission.Errored(http.StatusBadRequest, fmt.Errorf("unable to decode old object: %w", err))
+		}
+		if skipValidation(&con, old.Spec, con.Spec) {
+			return admission.Allowed("Connection is being deleted or its spec is unchanged")
+		}
+	}
+
+	conType, err := hook.connectionTypeLister.Find(ctx, req.Namespace, con.Spec.Type)
+	if err != nil && !errors.IsNotFound(err) {
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to get ConnectionType: %w", err))
+	}
+
+	// unknown types are rejected by the validating web hook
+	if conType == nil {
+		return admission.Allowed("no defaults to apply")
+	}
+	defaulted := con.Spec.Credentials.ApplyDefaults(conType.Spec.Fields)
+	if derived := con.Spec.Credentials.ApplyDerived(conType.Spec.Fields); !defaulted && !derived {
+		return admission.Allowed("no defaults to apply")
+	}
+
+	marshaled, err := json.Marshal(con)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// skipValidation returns true for updates of an object that is being deleted or whose spec is unchanged.
+// Their spec is neither defaulted nor validated again, so the finalizers of an object that no longer validates,
+// e.g. because its type gained a required field, can still be removed.
+func skipValidation(obj client.Object, oldSpec, spec interface{}) bool {
+	return !obj.GetDeletionTimestamp().IsZero() || equality.Semantic.DeepEqual(oldSpec, spec)
+}
@@ -2,13 +2,21 @@ package webhooks
 
 import (
 	"context"
+	"encoding/json"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/listers"
 )
 
 var _ = Describe("Connection validation webhook", func() {
@@ -107,3 +115,177 @@ var _ = Describe("Connection validation webhook", func() {
 	})
 
 })
+
+var _ = Describe("Connection mutating webhook", func() {
+
+	var conType *v1alpha1.ConnectionType
+	BeforeEach(func() {
+		conType = &v1alpha1.ConnectionType{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "mysql-defaults",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.ConnectionTypeSpec{
+				Fields: []v1alpha1.CredentialFieldSpec{
+					{
+						Name:       "host",
+						Required:   true,
+						Validation: &v1alpha1.Validation{},
+					},
+					{
+						Name:       "port",
+						Default:    "3306",
+						Validation: &v1alpha1.Validation{},
+					},
+					{
+						Name:    "url",
+						Derived: "mysql://{{.host}}:{{.port}}",
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), conType)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.Background(), conType)).Should(Succeed())
+	})
+
+	It("Should apply the defaults of the ConnectionType", func() {
+		con := &v1alpha1.Connection{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "defaulted-connection",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.ConnectionSpec{
+				Type: conType.Name,
+				Credentials: v1alpha1.Credentials{
+					"host": v1alpha1.Value{Value: "localhost"},
+				},
+			},
+		}
+
+		Expect(k8sClient.Create(context.Background(), con)).Should(Succeed())
+		Expect(con.Spec.Credentials).To(Equal(v1alpha1.Credentials{
+			"host": v1alpha1.Value{Value: "localhost"},
+			"port": v1alpha1.Value{Value: "3306"},
+			"url":  v1alpha1.Value{Value: "mysql://localhost:3306"},
+		}))
+		Expect(k8sClient.Delete(context.Background(), con)).Should(Succeed())
+	})
+
+	It("Should replace a derived field that is set with its computed value", func() {
+		con := &v1alpha1.Connection{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "derived-connection",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.ConnectionSpec{
+				Type: conType.Name,
+				Credentials: v1alpha1.Credentials{
+					"host": v1alpha1.Value{Value: "localhost"},
+					"url":  v1alpha1.Value{Value: "mysql://localhost"},
+				},
+			},
+		}
+
+		Expect(k8sClient.Create(context.Background(), con)).Should(Succeed())
+		Expect(con.Spec.Credentials["url"]).To(Equal(v1alpha1.Value{Value: "mysql://localhost:3306"}))
+		Expect(k8sClient.Delete(context.Background(), con)).Should(Succeed())
+	})
+
+	It("Should not apply the default of a sensitive field", func() {
+		Expect(k8sClient.Get(context.Background(), types.NamespacedName{Name: conType.Name, Namespace: conType.Namespace}, conType)).Should(Succeed())
+		conType.Spec.Fields = append(conType.Spec.Fields, v1alpha1.CredentialFieldSpec{Name: "password", Sensitive: true, Default: "changeme"})
+		Expect(k8sClient.Update(context.Background(), conType)).Should(Succeed())
+
+		con := &v1alpha1.Connection{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "sensitive-default-connection",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.ConnectionSpec{
+				Type: conType.Name,
+				Credentials: v1alpha1.Credentials{
+					"host": v1alpha1.Value{Value: "localhost"},
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), con)).Should(Succeed())
+		Expect(con.Spec.Credentials).ToNot(HaveKey("password"))
+		Expect(k8sClient.Delete(context.Background(), con)).Should(Succeed())
+	})
+})
+
+var _ = Describe("Connection validation webhook on updates", func() {
+	var hook *connectionValidatorHook
+	var con *v1alpha1.Connection
+
+	BeforeEach(func() {
+		s := runtime.NewScheme()
+		Expect(v1alpha1.AddToScheme(s)).To(Succeed())
+		decoder, err := admission.NewDecoder(s)
+		Expect(err).ToNot(HaveOccurred())
+
+		// the ConnectionType gained a required field the Connection does not set
+		conType := &v1alpha1.ConnectionType{
+			ObjectMeta: metav1.ObjectMeta{Name: "mysql-required", Namespace: "default"},
+			Spec: v1alpha1.ConnectionTypeSpec{
+				Fields: []v1alpha1.CredentialFieldSpec{{Name: "host", Required: true}},
+			},
+		}
+		hook = &connectionValidatorHook{
+			decoder:              decoder,
+			connectionTypeLister: listers.NewConnectionTypeLister(fake.NewClientBuilder().WithScheme(s).WithObjects(conType).Build()),
+		}
+
+		con = &v1alpha1.Connection{
+			TypeMeta: metav1.TypeMeta{APIVersion: v1alpha1.GroupVersion.String(), Kind: "Connection"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "outdated-connection",
+				Namespace:  "default",
+				Finalizers: []string{v1alpha1.InUseProtectionFinalizer},
+			},
+			Spec: v1alpha1.ConnectionSpec{
+				Type:        conType.Name,
+				Credentials: v1alpha1.Credentials{"port": v1alpha1.Value{Value: "3306"}},
+			},
+		}
+	})
+
+	It("Should allow the removal of a finalizer from a Connection that no longer validates", func() {
+		updated := con.DeepCopy()
+		updated.Finalizers = nil
+		Expect(hook.Handle(context.Background(), updateRequest(updated, con)).Allowed).To(BeTrue())
+	})
+
+	It("Should allow updates of a Connection that is being deleted", func() {
+		updated := con.DeepCopy()
+		now := metav1.Now()
+		updated.DeletionTimestamp = &now
+		updated.Spec.Credentials["port"] = v1alpha1.Value{Value: "3307"}
+		Expect(hook.Handle(context.Background(), updateRequest(updated, con)).Allowed).To(BeTrue())
+	})
+
+	It("Should validate an updated spec", func() {
+		updated := con.DeepCopy()
+		updated.Spec.Credentials["port"] = v1alpha1.Value{Value: "3307"}
+		Expect(hook.Handle(context.Background(), updateRequest(updated, con)).Allowed).To(BeFalse())
+	})
+})
+
+// updateRequest returns an admission request that updates the old object to the new object
+func updateRequest(obj, old client.Object) admission.Request {
+	raw, err := json.Marshal(obj)
+	Expect(err).ToNot(HaveOccurred())
+	oldRaw, err := json.Marshal(old)
+	Expect(err).ToNot(HaveOccurred())
+
+	return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Update,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Object:    runtime.RawExtension{Raw: raw},
+		OldObject: runtime.RawExtension{Raw: oldRaw},
+	}}
+}
@@ -2,9 +2,12 @@ package webhooks
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -49,16 +52,24 @@ func (hook *datasetValidatorHook) Handle(ctx context.Context, req admission.Requ
 	if err := hook.decoder.Decode(req, &ds); err != nil {
 		return admission.Errored(http.StatusBadRequest, fmt.Errorf("unable to decode admission request: %w", err))
 	}
+	if req.Operation == admissionv1.Update {
+		old := v1alpha1.DataSet{}
+		if err := hook.decoder.DecodeRaw(req.OldObject, &old); err != nil {
+			return admission.Errored(http.StatusBadRequest, fmt.Errorf("unable to decode old object: %w", err))
+		}
+		if skipValidation(&ds, old.Spec, ds.Spec) {
+			return admission.Allowed("DataSet is being deleted or its spec is unchanged")
+		}
+	}
 
 	dtype := ds.Spec.Type
 	dsType, err := hook.dataSetTypeLister.Find(ctx, req.Namespace, dtype)
-	if err != nil {
-		return admission.Errored(http.StatusBadRequest, fmt.Errorf("unable to list DataSetType: %w", err))
-	}
-
-	if dsType == nil {
+	if errors.IsNotFound(err) {
 		return admission.Errored(http.StatusBadRequest, fmt.Errorf("Unknown DataSetType: %v", dtype))
 	}
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to get DataSetType: %w", err))
+	}
 
 	errs := validation.ValidateDataSet(ds, *dsType)
 	if errs != nil {
@@ -67,3 +78,66 @@ func (hook *datasetValidatorHook) Handle(ctx context.Context, req admission.Requ
 
 	return admission.Allowed("valid DataSet resource passed to the API")
 }
+
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-v1alpha1-dataset,mutating=true,failurePolicy=fail,groups=etl.dataworkz.nl,resources=datasets,versions=v1alpha1,sideEffects=None,name=mdataset.dataworkz.nl,admissionReviewVersions=v1beta1
+
+// SetupMutatingDataSetWebhookWithManager registers the mutating web hook that applies the defaults
+// and computes the derived fields of the DataSetType of DataSets with the manager
+func SetupMutatingDataSetWebhookWithManager(mgr ctrl.Manager) error {
+	client := mgr.GetClient()
+	decoder, err := admission.NewDecoder(mgr.GetScheme())
+	if err != nil {
+		return fmt.Errorf("unable to create decoder: %w", err)
+	}
+	hook := &dataSetDefaulterHook{
+		decoder:           decoder,
+		dataSetTypeLister: listers.NewDataSetTypeLister(client),
+	}
+
+	hookserver := mgr.GetWebhookServer()
+	hookserver.Register("/mutate-v1alpha1-dataset", &admission.Webhook{Handler: hook})
+	return nil
+}
+
+type dataSetDefaulterHook struct {
+	decoder           *admission.Decoder
+	dataSetTypeLister listers.DataSetTypeLister
+}
+
+func (hook *dataSetDefaulterHook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	log := logf.Log.WithName("webhooks").WithName("mutate-dataset")
+	log.Info("Admission webhook request")
+	ds := v1alpha1.DataSet{}
+	if err := hook.decoder.Decode(req, &ds); err != nil {
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("unable to decode admission request: %w", err))
+	}
+	if req.Operation == admissionv1.Update {
+		old := v1alpha1.DataSet{}
+		if err := hook.decoder.DecodeRaw(req.OldObject, &old); err != nil {
+			return admission.Errored(http.StatusBadRequest, fmt.Errorf("unable to decode old object: %w", err))
+		}
+		if skipValidation(&ds, old.Spec, ds.Spec) {
+			return admission.Allowed("DataSet is being deleted or its spec is unchanged")
+		}
+	}
+
+	dsType, err := hook.dataSetTypeLister.Find(ctx, req.Namespace, ds.Spec.Type)
+	if err != nil && !errors.IsNotFound(err) {
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to get DataSetType: %w", err))
+	}
+
+	// unknown types are rejected by the validating web hook
+	if dsType == nil {
+		return admission.Allowed("no defaults to apply")
+	}
+	defaulted := ds.Spec.Metadata.ApplyDefaults(dsType.Spec.MetadataFields.Fields)
+	if derived := ds.Spec.Metadata.ApplyDerived(dsType.Spec.MetadataFields.Fields); !defaulted && !derived {
+		return admission.Allowed("no defaults to apply")
+	}
+
+	marshaled, err := json.Marshal(ds)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
@@ -6,9 +6,13 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/listers"
 )
 
 var _ = Describe("DataSet validation webhook", func() {
@@ -111,3 +115,46 @@ var _ = Describe("DataSet validation webhook", func() {
 	})
 
 })
+
+var _ = Describe("DataSet validation webhook on updates", func() {
+	It("Should allow the removal of a finalizer from a DataSet that no longer validates", func() {
+		s := runtime.NewScheme()
+		Expect(v1alpha1.AddToScheme(s)).To(Succeed())
+		decoder, err := admission.NewDecoder(s)
+		Expect(err).ToNot(HaveOccurred())
+
+		dsType := &v1alpha1.DataSetType{
+			ObjectMeta: metav1.ObjectMeta{Name: "mysql-required", Namespace: "default"},
+			Spec: v1alpha1.DataSetTypeSpec{
+				MetadataFields: v1alpha1.MetadataValidation{
+					Fields: []v1alpha1.CredentialFieldSpec{{Name: "table", Required: true}},
+				},
+			},
+		}
+		hook := &datasetValidatorHook{
+			decoder:           decoder,
+			dataSetTypeLister: listers.NewDataSetTypeLister(fake.NewClientBuilder().WithScheme(s).WithObjects(dsType).Build()),
+		}
+
+		ds := &v1alpha1.DataSet{
+			TypeMeta: metav1.TypeMeta{APIVersion: v1alpha1.GroupVersion.String(), Kind: "DataSet"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "outdated-dataset",
+				Namespace:  "default",
+				Finalizers: []string{v1alpha1.InUseProtectionFinalizer},
+			},
+			Spec: v1alpha1.DataSetSpec{
+				Type:        dsType.Name,
+				StorageType: v1alpha1.PersistentType,
+				Metadata:    v1alpha1.Credentials{"schema": v1alpha1.Value{Value: "finance"}},
+			},
+		}
+		updated := ds.DeepCopy()
+		updated.Finalizers = nil
+		Expect(hook.Handle(context.Background(), updateRequest(updated, ds)).Allowed).To(BeTrue())
+
+		By("Validating an updated spec")
+		updated.Spec.Metadata["schema"] = v1alpha1.Value{Value: "sales"}
+		Expect(hook.Handle(context.Background(), updateRequest(updated, ds)).Allowed).To(BeFalse())
+	})
+})
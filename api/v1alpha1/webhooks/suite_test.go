@@ -15,6 +15,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 
 	"github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/listers"
 )
 
 var k8sClient client.Client
@@ -34,10 +35,74 @@ var _ = BeforeSuite(func(done Done) {
 	failPolicy := admissionregistrationv1beta1.Fail
 	conWebhookPath := "/validate-v1alpha1-connection"
 	dsWebhookPath := "/validate-v1alpha1-dataset"
+	wfWebhookPath := "/validate-v1alpha1-workflow"
+	mconWebhookPath := "/mutate-v1alpha1-connection"
+	mdsWebhookPath := "/mutate-v1alpha1-dataset"
 
 	testEnv = &envtest.Environment{
 		CRDDirectoryPaths: []string{filepath.Join("..", "..", "..", "config", "crd", "bases")},
 		WebhookInstallOptions: envtest.WebhookInstallOptions{
+			MutatingWebhooks: []client.Object{
+				&admissionregistrationv1beta1.MutatingWebhookConfiguration{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "deployment-mutating-webhook-config",
+					},
+					TypeMeta: metav1.TypeMeta{
+						Kind:       "MutatingWebhookConfiguration",
+						APIVersion: "admissionregistration.k8s.io/v1beta1",
+					},
+					Webhooks: []admissionregistrationv1beta1.MutatingWebhook{
+						{
+							Name:          "mconnection.dataworkz.nl",
+							FailurePolicy: &failPolicy,
+							ClientConfig: admissionregistrationv1beta1.WebhookClientConfig{
+								Service: &admissionregistrationv1beta1.ServiceReference{
+									Name:      "deployment-validation-service",
+									Namespace: "default",
+									Path:      &mconWebhookPath,
+								},
+							},
+							Rules: []admissionregistrationv1beta1.RuleWithOperations{
+								{
+									Operations: []admissionregistrationv1beta1.OperationType{
+										admissionregistrationv1beta1.Create,
+										admissionregistrationv1beta1.Update,
+									},
+									Rule: admissionregistrationv1beta1.Rule{
+										APIGroups:   []string{"etl.dataworkz.nl"},
+										APIVersions: []string{"v1alpha1"},
+										Resources:   []string{"connections"},
+									},
+								},
+							},
+						},
+						{
+							Name:          "mdataset.dataworkz.nl",
+							FailurePolicy: &failPolicy,
+							ClientConfig: admissionregistrationv1beta1.WebhookClientConfig{
+								Service: &admissionregistrationv1beta1.ServiceReference{
+									Name:      "deployment-validation-service",
+									Namespace: "default",
+									Path:      &mdsWebhookPath,
+								},
+							},
+							Rules: []admissionregistrationv1beta1.RuleWithOperations{
+								{
+									Operations: []admissionregistrationv1beta1.OperationType{
+										admissionregistrationv1beta1.Create,
+										admissionregistrationv1beta1.Update,
+									},
+									Rule: admissionregistrationv1beta1.Rule{
+										APIGroups:   []string{"etl.dataworkz.nl"},
+										APIVersions: []string{"v1alpha1"},
+										Resources:   []string{"datasets"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
 			ValidatingWebhooks: []client.Object{
 				&admissionregistrationv1beta1.ValidatingWebhookConfiguration{
 					ObjectMeta: metav1.ObjectMeta{
@@ -96,6 +161,30 @@ var _ = BeforeSuite(func(done Done) {
 								},
 							},
 						},
+						{
+							Name:          "workflow.dataworkz.nl",
+							FailurePolicy: &failPolicy,
+							ClientConfig: admissionregistrationv1beta1.WebhookClientConfig{
+								Service: &admissionregistrationv1beta1.ServiceReference{
+									Name:      "deployment-validation-service",
+									Namespace: "default",
+									Path:      &wfWebhookPath,
+								},
+							},
+							Rules: []admissionregistrationv1beta1.RuleWithOperations{
+								{
+									Operations: []admissionregistrationv1beta1.OperationType{
+										admissionregistrationv1beta1.Create,
+										admissionregistrationv1beta1.Update,
+									},
+									Rule: admissionregistrationv1beta1.Rule{
+										APIGroups:   []string{"etl.dataworkz.nl"},
+										APIVersions: []string{"v1alpha1"},
+										Resources:   []string{"workflows", "cronworkflows", "workflowtemplates"},
+									},
+								},
+							},
+						},
 					},
 				},
 			},
@@ -123,6 +212,9 @@ var _ = BeforeSuite(func(done Done) {
 	})
 	Expect(err).NotTo(HaveOccurred())
 
+	err = listers.SetupIndexes(k8sManager)
+	Expect(err).NotTo(HaveOccurred())
+
 	By("running webhook server")
 	err = SetupValidatingConnectionWebhookWithManager(k8sManager)
 	Expect(err).NotTo(HaveOccurred())
@@ -130,6 +222,15 @@ var _ = BeforeSuite(func(done Done) {
 	err = SetupValidatingDataSetWebhookWithManager(k8sManager)
 	Expect(err).NotTo(HaveOccurred())
 
+	err = SetupValidatingWorkflowWebhookWithManager(k8sManager)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = SetupMutatingConnectionWebhookWithManager(k8sManager)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = SetupMutatingDataSetWebhookWithManager(k8sManager)
+	Expect(err).NotTo(HaveOccurred())
+
 	go func() {
 		defer GinkgoRecover()
 		err = k8sManager.Start(ctrl.SetupSignalHandler())
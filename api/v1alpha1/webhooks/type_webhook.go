@@ -0,0 +1,123 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/api/v1alpha1/validation"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-v1alpha1-connectiontype,mutating=false,failurePolicy=fail,groups=etl.dataworkz.nl,resources=connectiontypes;clusterconnectiontypes,versions=v1alpha1,sideEffects=None,name=connectiontype.dataworkz.nl,admissionReviewVersions=v1beta1
+
+// SetupValidatingConnectionTypeWebhookWithManager registers the validating web hook for ConnectionType
+// and ClusterConnectionType with the manager
+func SetupValidatingConnectionTypeWebhookWithManager(mgr ctrl.Manager) error {
+	decoder, err := admission.NewDecoder(mgr.GetScheme())
+	if err != nil {
+		return fmt.Errorf("unable to create decoder: %w", err)
+	}
+
+	hookserver := mgr.GetWebhookServer()
+	hookserver.Register("/validate-v1alpha1-connectiontype", &admission.Webhook{Handler: &connectionTypeValidatorHook{decoder: decoder}})
+	return nil
+}
+
+type connectionTypeValidatorHook struct {
+	decoder *admission.Decoder
+}
+
+func (hook *connectionTypeValidatorHook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	log := logf.Log.WithName("webhooks").WithName("validate-connectiontype")
+	log.Info("Admission webhook request")
+
+	obj, spec := newConnectionType(req.Kind.Kind)
+	if err := hook.decoder.Decode(req, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("unable to decode admission request: %w", err))
+	}
+	if req.Operation == admissionv1.Update {
+		old, oldSpec := newConnectionType(req.Kind.Kind)
+		if err := hook.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, fmt.Errorf("unable to decode old object: %w", err))
+		}
+		if skipValidation(obj, *oldSpec, *spec) {
+			return admission.Allowed(req.Kind.Kind + " is being deleted or its spec is unchanged")
+		}
+	}
+	return typeResponse(req.Kind.Kind, validation.ValidateConnectionTypeSpec(*spec))
+}
+
+// newConnectionType returns an empty ConnectionType, or ClusterConnectionType for that kind, and its spec
+func newConnectionType(kind string) (client.Object, *v1alpha1.ConnectionTypeSpec) {
+	if kind == "ClusterConnectionType" {
+		cct := &v1alpha1.ClusterConnectionType{}
+		return cct, &cct.Spec
+	}
+	ct := &v1alpha1.ConnectionType{}
+	return ct, &ct.Spec
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-v1alpha1-datasettype,mutating=false,failurePolicy=fail,groups=etl.dataworkz.nl,resources=datasettypes;clusterdatasettypes,versions=v1alpha1,sideEffects=None,name=datasettype.dataworkz.nl,admissionReviewVersions=v1beta1
+
+// SetupValidatingDataSetTypeWebhookWithManager registers the validating web hook for DataSetType
+// and ClusterDataSetType with the manager
+func SetupValidatingDataSetTypeWebhookWithManager(mgr ctrl.Manager) error {
+	decoder, err := admission.NewDecoder(mgr.GetScheme())
+	if err != nil {
+		return fmt.Errorf("unable to create decoder: %w", err)
+	}
+
+	hookserver := mgr.GetWebhookServer()
+	hookserver.Register("/validate-v1alpha1-datasettype", &admission.Webhook{Handler: &dataSetTypeValidatorHook{decoder: decoder}})
+	return nil
+}
+
+type dataSetTypeValidatorHook struct {
+	decoder *admission.Decoder
+}
+
+func (hook *dataSetTypeValidatorHook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	log := logf.Log.WithName("webhooks").WithName("validate-datasettype")
+	log.Info("Admission webhook request")
+
+	obj, spec := newDataSetType(req.Kind.Kind)
+	if err := hook.decoder.Decode(req, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("unable to decode admission request: %w", err))
+	}
+	if req.Operation == admissionv1.Update {
+		old, oldSpec := newDataSetType(req.Kind.Kind)
+		if err := hook.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, fmt.Errorf("unable to decode old object: %w", err))
+		}
+		if skipValidation(obj, *oldSpec, *spec) {
+			return admission.Allowed(req.Kind.Kind + " is being deleted or its spec is unchanged")
+		}
+	}
+	return typeResponse(req.Kind.Kind, validation.ValidateDataSetTypeSpec(*spec))
+}
+
+// newDataSetType returns an empty DataSetType, or ClusterDataSetType for that kind, and its spec
+func newDataSetType(kind string) (client.Object, *v1alpha1.DataSetTypeSpec) {
+	if kind == "ClusterDataSetType" {
+		cdt := &v1alpha1.ClusterDataSetType{}
+		return cdt, &cdt.Spec
+	}
+	dt := &v1alpha1.DataSetType{}
+	return dt, &dt.Spec
+}
+
+// typeResponse returns the admission response for a type of the kind with the validation errors of its spec
+func typeResponse(kind string, errs field.ErrorList) admission.Response {
+	if errs != nil {
+		return admission.Errored(http.StatusBadRequest, errs.ToAggregate())
+	}
+	return admission.Allowed(fmt.Sprintf("valid %s resource passed to the API", kind))
+}
@@ -0,0 +1,128 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+var _ = Describe("Type validation webhooks", func() {
+	var decoder *admission.Decoder
+	var fields []v1alpha1.CredentialFieldSpec
+
+	BeforeEach(func() {
+		s := runtime.NewScheme()
+		Expect(v1alpha1.AddToScheme(s)).To(Succeed())
+		var err error
+		decoder, err = admission.NewDecoder(s)
+		Expect(err).ToNot(HaveOccurred())
+
+		minimum, maximum := v1alpha1.ValidationNumber("1"), v1alpha1.ValidationNumber("65535")
+		fields = []v1alpha1.CredentialFieldSpec{
+			{Name: "port", Validation: &v1alpha1.Validation{Minimum: &minimum, Maximum: &maximum}},
+			{Name: "options", Validation: &v1alpha1.Validation{JSONSchema: &apiextensionsv1.JSON{Raw: []byte(`{"type": "object"}`)}}},
+		}
+	})
+
+	It("Should allow a ConnectionType with consistent validations", func() {
+		hook := &connectionTypeValidatorHook{decoder: decoder}
+		ct := &v1alpha1.ConnectionType{
+			TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.GroupVersion.String(), Kind: "ConnectionType"},
+			ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "default"},
+			Spec:       v1alpha1.ConnectionTypeSpec{Fields: fields},
+		}
+		Expect(hook.Handle(context.Background(), createRequest(ct)).Allowed).To(BeTrue())
+	})
+
+	It("Should reject a ClusterConnectionType with a minimum above its maximum", func() {
+		hook := &connectionTypeValidatorHook{decoder: decoder}
+		minimum := v1alpha1.ValidationNumber("70000")
+		fields[0].Validation.Minimum = &minimum
+		ct := &v1alpha1.ClusterConnectionType{
+			TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.GroupVersion.String(), Kind: "ClusterConnectionType"},
+			ObjectMeta: metav1.ObjectMeta{Name: "mysql"},
+			Spec:       v1alpha1.ConnectionTypeSpec{Fields: fields},
+		}
+		resp := hook.Handle(context.Background(), createRequest(ct))
+		Expect(resp.Allowed).To(BeFalse())
+		Expect(resp.Result.Message).To(Equal(`spec.fields[0].validation.minimum: Invalid value: "70000": must not be greater than maximum`))
+	})
+
+	It("Should reject a DataSetType with an unsupported JSON Schema", func() {
+		hook := &dataSetTypeValidatorHook{decoder: decoder}
+		fields[1].Validation.JSONSchema = &apiextensionsv1.JSON{Raw: []byte(`{"$ref": "#/definitions/options"}`)}
+		dt := &v1alpha1.DataSetType{
+			TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.GroupVersion.String(), Kind: "DataSetType"},
+			ObjectMeta: metav1.ObjectMeta{Name: "table", Namespace: "default"},
+			Spec:       v1alpha1.DataSetTypeSpec{MetadataFields: v1alpha1.MetadataValidation{Fields: fields}},
+		}
+		resp := hook.Handle(context.Background(), createRequest(dt))
+		Expect(resp.Allowed).To(BeFalse())
+		Expect(resp.Result.Message).To(ContainSubstring("spec.metadata.fields[1].validation.jsonSchema"))
+		Expect(resp.Result.Message).To(ContainSubstring("$ref is not supported"))
+	})
+
+	It("Should allow the removal of a finalizer from a ClusterConnectionType that no longer validates", func() {
+		hook := &connectionTypeValidatorHook{decoder: decoder}
+		minimum := v1alpha1.ValidationNumber("70000")
+		fields[0].Validation.Minimum = &minimum
+		ct := &v1alpha1.ClusterConnectionType{
+			TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.GroupVersion.String(), Kind: "ClusterConnectionType"},
+			ObjectMeta: metav1.ObjectMeta{Name: "mysql", Finalizers: []string{v1alpha1.InUseProtectionFinalizer}},
+			Spec:       v1alpha1.ConnectionTypeSpec{Fields: fields},
+		}
+		updated := ct.DeepCopy()
+		updated.Finalizers = nil
+		req := updateRequest(updated, ct)
+		req.Kind = metav1.GroupVersionKind{Kind: "ClusterConnectionType"}
+		Expect(hook.Handle(context.Background(), req).Allowed).To(BeTrue())
+
+		By("Validating an updated spec")
+		updated = ct.DeepCopy()
+		updated.Spec.Fields[1].Sensitive = true
+		req = updateRequest(updated, ct)
+		req.Kind = metav1.GroupVersionKind{Kind: "ClusterConnectionType"}
+		Expect(hook.Handle(context.Background(), req).Allowed).To(BeFalse())
+	})
+
+	It("Should allow updates of a DataSetType that is being deleted", func() {
+		hook := &dataSetTypeValidatorHook{decoder: decoder}
+		fields[1].Validation.JSONSchema = &apiextensionsv1.JSON{Raw: []byte(`{"$ref": "#/definitions/options"}`)}
+		dt := &v1alpha1.DataSetType{
+			TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.GroupVersion.String(), Kind: "DataSetType"},
+			ObjectMeta: metav1.ObjectMeta{Name: "table", Namespace: "default"},
+			Spec:       v1alpha1.DataSetTypeSpec{MetadataFields: v1alpha1.MetadataValidation{Fields: fields}},
+		}
+		updated := dt.DeepCopy()
+		now := metav1.Now()
+		updated.DeletionTimestamp = &now
+		updated.Spec.MetadataFields.Fields[0].Sensitive = true
+		req := updateRequest(updated, dt)
+		req.Kind = metav1.GroupVersionKind{Kind: "DataSetType"}
+		Expect(hook.Handle(context.Background(), req).Allowed).To(BeTrue())
+	})
+})
+
+// createRequest returns an admission request that creates the object
+func createRequest(obj client.Object) admission.Request {
+	raw, err := json.Marshal(obj)
+	Expect(err).ToNot(HaveOccurred())
+
+	return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Create,
+		Kind:      metav1.GroupVersionKind{Kind: obj.GetObjectKind().GroupVersionKind().Kind},
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Object:    runtime.RawExtension{Raw: raw},
+	}}
+}
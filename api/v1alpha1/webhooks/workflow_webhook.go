@@ -0,0 +1,216 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/api/v1alpha1/validation"
+	"github.com/dataworkz/kubeetl/listers"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-v1alpha1-workflow,mutating=false,failurePolicy=fail,groups=etl.dataworkz.nl,resources=workflows;cronworkflows;workflowtemplates,versions=v1alpha1,sideEffects=None,name=workflow.dataworkz.nl,admissionReviewVersions=v1beta1
+
+// SetupValidatingWorkflowWebhookWithManager registers the validating web hook for Workflows, CronWorkflows
+// and WorkflowTemplates with the manager
+func SetupValidatingWorkflowWebhookWithManager(mgr ctrl.Manager) error {
+	client := mgr.GetClient()
+	decoder, err := admission.NewDecoder(mgr.GetScheme())
+	if err != nil {
+		return fmt.Errorf("unable to create decoder: %w", err)
+	}
+	hook := &workflowValidatorHook{
+		client:                client,
+		decoder:               decoder,
+		connectionLister:      listers.NewConnectionLister(client),
+		connectionGrantLister: listers.NewIndexedConnectionGrantLister(client),
+		connectionTypeLister:  listers.NewConnectionTypeLister(client),
+		dataSetLister:         listers.NewDataSetLister(client),
+		dataSetTypeLister:     listers.NewDataSetTypeLister(client),
+	}
+
+	hookserver := mgr.GetWebhookServer()
+	hookserver.Register("/validate-v1alpha1-workflow", &admission.Webhook{Handler: hook})
+	return nil
+}
+
+type workflowValidatorHook struct {
+	client                client.Client
+	decoder               *admission.Decoder
+	connectionLister      listers.ConnectionLister
+	connectionGrantLister listers.ConnectionGrantLister
+	connectionTypeLister  listers.ConnectionTypeLister
+	dataSetLister         listers.DataSetLister
+	dataSetTypeLister     listers.DataSetTypeLister
+}
+
+func (hook *workflowValidatorHook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	log := logf.Log.WithName("webhooks").WithName("validate-workflow")
+	log.Info("Admission webhook request", "kind", req.Kind.Kind)
+
+	wfs, path, err := hook.decodeWorkflowSpec(req)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("unable to decode admission request: %w", err))
+	}
+
+	errs := validation.ValidateWorkflowSpec(*wfs, path)
+
+	refErrs, err := hook.validateReferences(ctx, v1alpha1.WorkflowKind(req.Kind.Kind), req.Namespace, *wfs, path)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	errs = append(errs, refErrs...)
+
+	if errs != nil {
+		return admission.Errored(http.StatusBadRequest, errs.ToAggregate())
+	}
+
+	return admission.Allowed(fmt.Sprintf("valid %s resource passed to the API", req.Kind.Kind))
+}
+
+// decodeWorkflowSpec decodes the resource in the request and returns its WorkflowSpec together with the path of the WorkflowSpec
+func (hook *workflowValidatorHook) decodeWorkflowSpec(req admission.Request) (*v1alpha1.WorkflowSpec, *field.Path, error) {
+	spec := field.NewPath("spec")
+	switch v1alpha1.WorkflowKind(req.Kind.Kind) {
+	case v1alpha1.WorkflowKindWorkflow:
+		wf := v1alpha1.Workflow{}
+		if err := hook.decoder.Decode(req, &wf); err != nil {
+			return nil, nil, err
+		}
+		return &wf.Spec, spec, nil
+	case v1alpha1.WorkflowKindCronWorkflow:
+		cwf := v1alpha1.CronWorkflow{}
+		if err := hook.decoder.Decode(req, &cwf); err != nil {
+			return nil, nil, err
+		}
+		return &cwf.Spec.WorkflowSpec, spec.Child("workflowSpec"), nil
+	case v1alpha1.WorkflowKindWorkflowTemplate:
+		wft := v1alpha1.WorkflowTemplate{}
+		if err := hook.decoder.Decode(req, &wft); err != nil {
+			return nil, nil, err
+		}
+		return &wft.Spec.WorkflowSpec, spec, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported kind %s", req.Kind.Kind)
+}
+
+// validateReferences checks that the Connections and DataSets referred to by the InjectableValues exist,
+// that Connections in other namespaces are granted and that the Content templates only refer to keys they define.
+// WorkflowTemplates share one connection secret between their runs, so they cannot inject issued credentials.
+func (hook *workflowValidatorHook) validateReferences(ctx context.Context, kind v1alpha1.WorkflowKind, namespace string, wfs v1alpha1.WorkflowSpec, path *field.Path) (field.ErrorList, error) {
+	var errList field.ErrorList
+	for i, iv := range wfs.InjectableValues {
+		ivPath := path.Child("injectable").Index(i)
+		if iv.ConnectionRef.Name != "" {
+			key := iv.ConnectionRef.GetNamespacedName(namespace)
+			granted, err := hook.connectionGrantLister.Granted(ctx, key, namespace, wfs.GetInjectionServiceAccount())
+			if err != nil {
+				return nil, err
+			}
+			if !granted {
+				msg := fmt.Sprintf("no ConnectionGrant in namespace %s allows service account %s to consume Connection %s", key.Namespace, wfs.GetInjectionServiceAccount(), key.Name)
+				errList = append(errList, field.Forbidden(ivPath.Child("connectionRef"), msg))
+				continue
+			}
+
+			conn, err := hook.connectionLister.Find(ctx, key.Namespace, key.Name)
+			if errors.IsNotFound(err) {
+				errList = append(errList, field.NotFound(ivPath.Child("connectionRef"), iv.ConnectionRef.Name))
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if kind == v1alpha1.WorkflowKindWorkflowTemplate && conn.Spec.CredentialIssuer != nil {
+				errList = append(errList, field.Forbidden(ivPath.Child("connectionRef"), v1alpha1.IssuedCredentialsMessage(conn.Name)))
+				continue
+			}
+			connValues, err := hook.connectionPlaceholders(ctx, conn)
+			if err != nil {
+				return nil, err
+			}
+			errList = append(errList, validation.ValidateContent(iv, connValues, ivPath)...)
+		}
+
+		if iv.DataSetRef.Name != "" {
+			ds, err := hook.dataSetLister.Find(ctx, namespace, iv.DataSetRef.Name)
+			if errors.IsNotFound(err) {
+				errList = append(errList, field.NotFound(ivPath.Child("dataSetRef"), iv.DataSetRef.Name))
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			var connValues map[string]string
+			if ds.Spec.Connection.ConnectionFrom != nil {
+				conn, err := hook.connectionLister.Find(ctx, namespace, ds.Spec.Connection.ConnectionFrom.Name)
+				if errors.IsNotFound(err) {
+					// the Content cannot be checked until the Connection of the DataSet exists
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+				if kind == v1alpha1.WorkflowKindWorkflowTemplate && conn.Spec.CredentialIssuer != nil {
+					errList = append(errList, field.Forbidden(ivPath.Child("dataSetRef"), v1alpha1.IssuedCredentialsMessage(conn.Name)))
+					continue
+				}
+				connValues, err = hook.connectionPlaceholders(ctx, conn)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			var dsFields []v1alpha1.CredentialFieldSpec
+			dsType, err := hook.dataSetTypeLister.Find(ctx, namespace, ds.Spec.Type)
+			if err != nil && !errors.IsNotFound(err) {
+				return nil, err
+			}
+			if dsType != nil {
+				dsFields = dsType.Spec.MetadataFields.Fields
+			}
+			data := v1alpha1.DataSetTemplateData(validation.PlaceholderValues(v1alpha1.EffectiveFieldNames(ds.Spec.Metadata, nil, dsFields)), connValues)
+			errList = append(errList, validation.ValidateContent(iv, data, ivPath)...)
+		}
+	}
+	return errList, nil
+}
+
+// connectionPlaceholders returns placeholder values for the credentials and derived fields of the Connection
+func (hook *workflowValidatorHook) connectionPlaceholders(ctx context.Context, conn *v1alpha1.Connection) (map[string]string, error) {
+	var fields []v1alpha1.CredentialFieldSpec
+	conType, err := hook.connectionTypeLister.Find(ctx, conn.Namespace, conn.Spec.Type)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	if conType != nil {
+		fields = conType.Spec.Fields
+	}
+	return validation.PlaceholderValues(conn.FieldNames(fields)), nil
+}
@@ -0,0 +1,182 @@
+package webhooks
+
+import (
+	"context"
+
+	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+var _ = Describe("Workflow validation webhook", func() {
+
+	var connType *v1alpha1.ConnectionType
+	var conn *v1alpha1.Connection
+	BeforeEach(func() {
+		connType = &v1alpha1.ConnectionType{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "workflow-connection-type",
+				Namespace: "default",
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), connType)).Should(Succeed())
+
+		conn = &v1alpha1.Connection{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "workflow-connection",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.ConnectionSpec{
+				Type: "workflow-connection-type",
+				Credentials: v1alpha1.Credentials{
+					"password": v1alpha1.Value{Value: "secret"},
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), conn)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.Background(), conn)).Should(Succeed())
+		Expect(k8sClient.Delete(context.Background(), connType)).Should(Succeed())
+	})
+
+	workflowSpec := func(connection string) v1alpha1.WorkflowSpec {
+		return v1alpha1.WorkflowSpec{
+			InjectableValues: v1alpha1.InjectableValues{
+				{
+					Name:          "password",
+					ConnectionRef: v1alpha1.ConnectionReference{Name: connection},
+					EnvName:       "PASSWORD",
+					Content:       "{{.password}}",
+				},
+			},
+			InjectInto: []v1alpha1.TemplateRef{
+				{
+					Name:           "main",
+					InjectedValues: []string{"password"},
+				},
+			},
+			ArgoWorkflowSpec: wfv1.WorkflowSpec{
+				Entrypoint: "main",
+				Templates:  []wfv1.Template{{Name: "main"}},
+			},
+		}
+	}
+
+	It("Should accept a valid Workflow", func() {
+		wf := &v1alpha1.Workflow{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "valid-workflow",
+				Namespace: "default",
+			},
+			Spec: workflowSpec(conn.Name),
+		}
+
+		Expect(k8sClient.Create(context.Background(), wf)).Should(Succeed())
+		Expect(k8sClient.Delete(context.Background(), wf)).Should(Succeed())
+	})
+
+	It("Should return an error if a referenced Connection does not exist", func() {
+		wf := &v1alpha1.Workflow{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "unknown-connection-workflow",
+				Namespace: "default",
+			},
+			Spec: workflowSpec("unknown"),
+		}
+
+		err := k8sClient.Create(context.Background(), wf)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).To(Equal("admission webhook \"workflow.dataworkz.nl\" denied the request: spec.injectable[0].connectionRef: Not found: \"unknown\""))
+	})
+
+	It("Should return an error if a CronWorkflow injects into an unknown template", func() {
+		wfs := workflowSpec(conn.Name)
+		wfs.InjectInto[0].Name = "unknown"
+		cwf := &v1alpha1.CronWorkflow{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "unknown-template-cronworkflow",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.CronWorkflowSpec{
+				WorkflowSpec: wfs,
+				Schedule:     "* * * * *",
+			},
+		}
+
+		err := k8sClient.Create(context.Background(), cwf)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).To(Equal("admission webhook \"workflow.dataworkz.nl\" denied the request: spec.workflowSpec.injectInto[0].name: Not found: \"unknown\""))
+	})
+
+	It("Should return an error if the Content refers to a key the Connection does not define", func() {
+		wfs := workflowSpec(conn.Name)
+		wfs.InjectableValues[0].Content = "{{.user}}"
+		wf := &v1alpha1.Workflow{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "missing-key-workflow",
+				Namespace: "default",
+			},
+			Spec: wfs,
+		}
+
+		err := k8sClient.Create(context.Background(), wf)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("spec.injectable[0].content"))
+		Expect(err.Error()).To(ContainSubstring(`map has no entry for key "user"`))
+	})
+
+	It("Should return an error if an InjectableValue has neither content nor files", func() {
+		wfs := workflowSpec(conn.Name)
+		wfs.InjectableValues[0].Content = ""
+		wf := &v1alpha1.Workflow{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "empty-injectable-workflow",
+				Namespace: "default",
+			},
+			Spec: wfs,
+		}
+
+		err := k8sClient.Create(context.Background(), wf)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).To(Equal("admission webhook \"workflow.dataworkz.nl\" denied the request: spec.injectable[0].content: Required value: one of content or files must be set"))
+	})
+
+	It("Should only accept a Connection in another namespace if a ConnectionGrant allows it", func() {
+		wfs := workflowSpec(conn.Name)
+		wfs.InjectableValues[0].ConnectionRef.Namespace = conn.Namespace
+		wf := &v1alpha1.Workflow{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cross-namespace-workflow",
+				Namespace: "kube-public",
+			},
+			Spec: wfs,
+		}
+
+		err := k8sClient.Create(context.Background(), wf)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("spec.injectable[0].connectionRef: Forbidden"))
+
+		grant := &v1alpha1.ConnectionGrant{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "workflow-connection-grant",
+				Namespace: conn.Namespace,
+			},
+			Spec: v1alpha1.ConnectionGrantSpec{
+				From: []v1alpha1.ConnectionGrantFrom{{Namespace: wf.Namespace}},
+				To:   []v1alpha1.ConnectionGrantTo{{Name: conn.Name}},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), grant)).Should(Succeed())
+
+		Eventually(func() error {
+			return k8sClient.Create(context.Background(), wf)
+		}).Should(Succeed())
+		Expect(k8sClient.Delete(context.Background(), wf)).Should(Succeed())
+		Expect(k8sClient.Delete(context.Background(), grant)).Should(Succeed())
+	})
+})
@@ -8,6 +8,118 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// WorkflowKind is the kind of a KubeETL resource that embeds a WorkflowSpec
+type WorkflowKind string
+
+const (
+	WorkflowKindWorkflow         WorkflowKind = "Workflow"
+	WorkflowKindCronWorkflow     WorkflowKind = "CronWorkflow"
+	WorkflowKindWorkflowTemplate WorkflowKind = "WorkflowTemplate"
+)
+
+// InjectionMode defines how the connection secret of a Workflow is populated
+// +kubebuilder:validation:Enum=Daemon;Controller
+type InjectionMode string
+
+const (
+	// InjectionModeDaemon populates the connection secret from a daemon step that runs in the Argo Workflow
+	InjectionModeDaemon InjectionMode = "Daemon"
+	// InjectionModeController lets the controller populate the connection secret before the Argo Workflow runs
+	InjectionModeController InjectionMode = "Controller"
+)
+
+// ParseInjectionMode returns the InjectionMode for the given value or an error if the mode is unknown
+func ParseInjectionMode(mode string) (InjectionMode, error) {
+	switch m := InjectionMode(mode); m {
+	case InjectionModeDaemon, InjectionModeController:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown injection mode %q, expected %s or %s", mode, InjectionModeDaemon, InjectionModeController)
+}
+
+// InjectionTemplate configures the container that runs the injection step in InjectionModeDaemon
+type InjectionTemplate struct {
+	// Image is the image of the injection container. Use a digest to pin the image.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// ImagePullPolicy is the pull policy of the injection container
+	// +optional
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// ImagePullSecrets are added to the Argo Workflow to pull the injection image
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Resources are the compute resources of the injection container
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// SecurityContext is the security context of the injection container
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+
+	// NodeSelector constrains the nodes the injection step is scheduled on
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations are the tolerations of the injection step
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// SecretBackendConfig is the name of a Secret in the namespace of the Workflow that configures the external
+	// secret backends of the injection container, which reads the values of ExternalRefs in InjectionModeDaemon.
+	// The Secret is mounted at SecretBackendConfigMountPath and its SecretBackendConfigKey holds the configuration.
+	// Other keys, such as a Vault token, can be referred to from the configuration as files in the mount path.
+	// +optional
+	SecretBackendConfig string `json:"secretBackendConfig,omitempty"`
+}
+
+const (
+	// SecretBackendConfigMountPath is the directory the SecretBackendConfig of the injection container is mounted at
+	SecretBackendConfigMountPath = "/etc/kubeetl/secret-backends"
+	// SecretBackendConfigKey is the key of the SecretBackendConfig Secret that holds the secret backend configuration
+	SecretBackendConfigKey = "config.yaml"
+)
+
+// Merge returns a copy of the InjectionTemplate in which every field that is set in override replaces the original value
+func (it *InjectionTemplate) Merge(override *InjectionTemplate) InjectionTemplate {
+	res := *it.DeepCopy()
+	if override == nil {
+		return res
+	}
+
+	o := override.DeepCopy()
+	if o.Image != "" {
+		res.Image = o.Image
+	}
+	if o.ImagePullPolicy != "" {
+		res.ImagePullPolicy = o.ImagePullPolicy
+	}
+	if o.ImagePullSecrets != nil {
+		res.ImagePullSecrets = o.ImagePullSecrets
+	}
+	if o.Resources != nil {
+		res.Resources = o.Resources
+	}
+	if o.SecurityContext != nil {
+		res.SecurityContext = o.SecurityContext
+	}
+	if o.NodeSelector != nil {
+		res.NodeSelector = o.NodeSelector
+	}
+	if o.Tolerations != nil {
+		res.Tolerations = o.Tolerations
+	}
+	if o.SecretBackendConfig != "" {
+		res.SecretBackendConfig = o.SecretBackendConfig
+	}
+	return res
+}
+
+// ArgoWorkflowNameVariable is the Argo Workflows variable that resolves to the name of the running Argo Workflow
+const ArgoWorkflowNameVariable = "{{workflow.name}}"
+
 // NameWithHash returns the workflow name with an md5-hash added as a suffix.
 // This is used prevent naming conflicts when creating Workflow-related resources,
 // such as Connection Secrets
@@ -22,6 +134,11 @@ func ConnectionVolumeName(parentResourceName string) string {
 	return NameWithHash(parentResourceName)
 }
 
+// InjectableValueVolumeName returns the name of the volume that mounts the Files of an InjectableValue
+func InjectableValueVolumeName(injectableValueName string) string {
+	return fmt.Sprintf("kubeetl-%x", md5.Sum([]byte(injectableValueName)))
+}
+
 func ConnectionSecret(parentResourceName, namespace string) corev1.Secret {
 	return corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
@@ -30,3 +147,19 @@ func ConnectionSecret(parentResourceName, namespace string) corev1.Secret {
 		},
 	}
 }
+
+// RunConnectionSecretName returns the name of the connection secret of a single Argo Workflow run.
+// This is used for runs spawned from a CronWorkflow, so every run has its own snapshot of the credentials.
+func RunConnectionSecretName(runName string) string {
+	return fmt.Sprintf("%s-connections", runName)
+}
+
+// RunConnectionSecret returns the connection secret of a single Argo Workflow run
+func RunConnectionSecret(runName, namespace string) corev1.Secret {
+	return corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      RunConnectionSecretName(runName),
+		},
+	}
+}
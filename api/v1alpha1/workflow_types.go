@@ -8,11 +8,32 @@ import (
 	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 const (
-	InjectableValueTypeFile InjectableValueType = "File"
-	InjectableValueTypeEnv  InjectableValueType = "Env"
+	InjectableValueTypeFile      InjectableValueType = "File"
+	InjectableValueTypeEnv       InjectableValueType = "Env"
+	InjectableValueTypeDirectory InjectableValueType = "Directory"
+	InjectableValueTypeFields    InjectableValueType = "Fields"
+)
+
+const (
+	// WorkflowConditionBlocked indicates whether the Argo Workflow is held back
+	// because a referenced Connection or DataSet is not healthy.
+	WorkflowConditionBlocked = "Blocked"
+
+	// WorkflowConditionReady indicates whether the Argo Workflow has been submitted.
+	WorkflowConditionReady = "Ready"
+
+	// WorkflowConditionRunning indicates whether the Argo Workflow is running.
+	WorkflowConditionRunning = "Running"
+
+	// WorkflowConditionSucceeded indicates whether the Argo Workflow completed successfully.
+	WorkflowConditionSucceeded = "Succeeded"
+
+	// WorkflowConditionFailed indicates whether the Argo Workflow failed or errored.
+	WorkflowConditionFailed = "Failed"
 )
 
 // +kubebuilder:object:root=true
@@ -36,10 +57,84 @@ type Workflow struct {
 	Status WorkflowStatus `json:"status,omitempty"`
 }
 
-// ConnectionStatus defines the observed state of Workflow
+// WorkflowStatus defines the observed state of Workflow
 type WorkflowStatus struct {
 	// ArgoWorkflowRef is a reference to the Argo Workflow created for this Workflow
 	ArgoWorkflowRef *corev1.ObjectReference `json:"argoWorkflowRef,omitempty"`
+
+	// Phase is the phase of the Argo Workflow
+	// +optional
+	Phase wfv1.NodePhase `json:"phase,omitempty"`
+
+	// StartedAt is the time the Argo Workflow started
+	// +optional
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+
+	// FinishedAt is the time the Argo Workflow finished
+	// +optional
+	FinishedAt *metav1.Time `json:"finishedAt,omitempty"`
+
+	// Message is the message of the Argo Workflow, which typically explains a failure
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Progress of the Argo Workflow, formatted as completed/total
+	// +optional
+	Progress wfv1.Progress `json:"progress,omitempty"`
+
+	// FailedNodes contains the nodes of the Argo Workflow that failed or errored
+	// +optional
+	FailedNodes []NodeFailure `json:"failedNodes,omitempty"`
+
+	// History contains the most recent completed runs of this Workflow, oldest first
+	// +optional
+	History []WorkflowRun `json:"history,omitempty"`
+
+	// Conditions contains the latest observations of the Workflow state
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// NodeFailure describes a failed node of an Argo Workflow
+type NodeFailure struct {
+	// Name is the unique name of the node
+	Name string `json:"name"`
+
+	// DisplayName is the human readable name of the node
+	// +optional
+	DisplayName string `json:"displayName,omitempty"`
+
+	// TemplateName is the name of the template the node executed
+	// +optional
+	TemplateName string `json:"templateName,omitempty"`
+
+	// Phase of the node
+	Phase wfv1.NodePhase `json:"phase"`
+
+	// Message explaining the failure
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// WorkflowRun records the outcome of a completed Argo Workflow run
+type WorkflowRun struct {
+	// ArgoWorkflowUID is the UID of the Argo Workflow that executed the run
+	ArgoWorkflowUID types.UID `json:"argoWorkflowUID"`
+
+	// Phase is the final phase of the run
+	Phase wfv1.NodePhase `json:"phase"`
+
+	// StartedAt is the time the run started
+	// +optional
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+
+	// FinishedAt is the time the run finished
+	// +optional
+	FinishedAt *metav1.Time `json:"finishedAt,omitempty"`
+
+	// Message of the run
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // WorkflowSpec defines the desired state of Workflow
@@ -62,6 +157,15 @@ type WorkflowSpec struct {
 	// This defaults to the Workflow service account.
 	// +optional
 	InjectionServiceAccount string `json:"injectionServiceAccount"`
+
+	// InjectionMode defines how the connection secret is populated.
+	// Defaults to the injection mode configured on the manager.
+	// +optional
+	InjectionMode InjectionMode `json:"injectionMode,omitempty"`
+
+	// Injection overrides the injection container configured on the manager
+	// +optional
+	Injection *InjectionTemplate `json:"injection,omitempty"`
 }
 
 type InjectableValues []InjectableValue
@@ -81,9 +185,10 @@ type InjectableValue struct {
 	// +required
 	Name string `json:"name"`
 
-	// Name of the `Connection` that is being injected here
+	// Name of the `Connection` that is being injected here.
+	// The Connection may be in another namespace if a ConnectionGrant in that namespace allows it.
 	// +optional
-	ConnectionRef corev1.LocalObjectReference `json:"connectionRef"`
+	ConnectionRef ConnectionReference `json:"connectionRef"`
 
 	// Name of the `DataSet` that is being injected here
 	// +optional
@@ -98,22 +203,86 @@ type InjectableValue struct {
 	// +optional
 	EnvName string `json:"envName,omitempty"`
 
-	// Path where value will be mounted as a file
+	// Path where value will be mounted as a file.
+	// If Files is set, the path of the directory in which the files are mounted
 	// +optional
 	MountPath string `json:"mountPath,omitempty"`
 
+	// Files maps file names to the Go templates rendered into them. The files are mounted together
+	// as a directory at MountPath, which allows a single InjectableValue to produce a complete configuration bundle
+	// (e.g. a dbt profiles.yml or a Singer config.json). Files and Content are mutually exclusive.
+	// +optional
+	Files map[string]ContentTemplate `json:"files,omitempty"`
+
+	// FileMode sets the permission bits of the files mounted from Files. Defaults to 0644.
+	// +optional
+	FileMode *int32 `json:"fileMode,omitempty"`
+
+	// If true, every field of the Connection is injected as an environment variable of its own,
+	// named by the EnvKey of the corresponding ConnectionType field. Fields without an EnvKey are not injected.
+	// The fields are the credentials, the issued fields and the derived fields. Values from Secrets and ConfigMaps
+	// are injected using a secretKeyRef or configMapKeyRef, issued and derived fields and values from external
+	// secret backends are read from the connection secret. EnvName, MountPath, Content and Files are not used.
+	// +optional
+	ExpandFields bool `json:"expandFields,omitempty"`
+
+	// If true, the Argo Workflow is not submitted until the referenced Connection or DataSet
+	// (and the Connection of that DataSet) is Healthy. Resources without a health check are not considered.
+	// +optional
+	RequireHealthy bool `json:"requireHealthy,omitempty"`
+
 	// Go template that will be rendered using the connection/dataset fields as data
 	// Example: mysql://{{.user}}:{{.password}}@{{.host}}:{{.port}}/{{.database}}
-	// +required
-	Content ContentTemplate `json:"content"`
+	// Besides the text/template builtins, the functions urlPathEscape, b64enc, b64dec, default, required,
+	// quote, upper, lower, join and toJson are available.
+	// Templates that refer to keys the Connection or DataSet does not define are rejected on admission.
+	// Exactly one of Content or Files must be set, unless ExpandFields is true.
+	// +optional
+	Content ContentTemplate `json:"content,omitempty"`
+}
+
+// FileKey returns the key of the connection secret that holds the rendered file with the given name
+func (iv *InjectableValue) FileKey(filename string) string {
+	return fmt.Sprintf("%s.%s", iv.Name, filename)
+}
+
+// FieldKey returns the key of the connection secret that holds the value of an expanded field
+// that cannot be referred to directly
+func (iv *InjectableValue) FieldKey(field string) string {
+	return fmt.Sprintf("%s.%s", iv.Name, field)
+}
+
+// Templates returns the templates of the InjectableValue by the key of the connection secret they are rendered into.
+// That is the Content under the name of the InjectableValue, or each of the Files under its FileKey.
+// InjectableValues that expand the fields of their Connection are not rendered.
+func (iv *InjectableValue) Templates() map[string]ContentTemplate {
+	if iv.ExpandFields {
+		return nil
+	}
+	if len(iv.Files) == 0 {
+		return map[string]ContentTemplate{iv.Name: iv.Content}
+	}
+
+	templates := make(map[string]ContentTemplate, len(iv.Files))
+	for filename, ct := range iv.Files {
+		templates[iv.FileKey(filename)] = ct
+	}
+	return templates
 }
 
 type ContentTemplate string
 
-func (ct ContentTemplate) Render(data interface{}) (string, error) {
-	tmpl, err := template.New("content").
+// Parse parses the ContentTemplate without rendering it.
+// The functions of contentTemplateFuncs are available to the template.
+func (ct ContentTemplate) Parse() (*template.Template, error) {
+	return template.New("content").
 		Option("missingkey=error").
+		Funcs(contentTemplateFuncs).
 		Parse(string(ct))
+}
+
+func (ct ContentTemplate) Render(data interface{}) (string, error) {
+	tmpl, err := ct.Parse()
 	if err != nil {
 		return "", fmt.Errorf("error parsing template: %w", err)
 	}
@@ -127,12 +296,26 @@ func (ct ContentTemplate) Render(data interface{}) (string, error) {
 	return buf.String(), nil
 }
 
+// DataSetTemplateData returns the data the Content of an InjectableValue referring to a DataSet is rendered with.
+// The DataSet metadata is available as .metadata and, if the DataSet has a Connection, its credentials as .connection
+func DataSetTemplateData(metadata, connection map[string]string) map[string]map[string]string {
+	data := map[string]map[string]string{"metadata": metadata}
+	if connection != nil {
+		data["connection"] = connection
+	}
+	return data
+}
+
 type InjectableValueType string
 
 func (iv *InjectableValue) GetType() InjectableValueType {
 	switch {
+	case iv.ExpandFields:
+		return InjectableValueTypeFields
 	case iv.EnvName != "":
 		return InjectableValueTypeEnv
+	case iv.MountPath != "" && len(iv.Files) > 0:
+		return InjectableValueTypeDirectory
 	case iv.MountPath != "":
 		return InjectableValueTypeFile
 	default:
@@ -149,6 +332,39 @@ func (wfs *WorkflowSpec) GetInjectableValueByName(name string) (*InjectableValue
 	return nil, fmt.Errorf("no InjectableValue found with name %s", name)
 }
 
+// RequiresHealthyDependencies returns true if any InjectableValue requires its dependencies to be healthy.
+func (wfs *WorkflowSpec) RequiresHealthyDependencies() bool {
+	for _, iv := range wfs.InjectableValues {
+		if iv.RequireHealthy {
+			return true
+		}
+	}
+	return false
+}
+
+// GetInjectionServiceAccount returns the service account that injects the connections, which is
+// the InjectionServiceAccount or else the service account of the Argo Workflow.
+func (wfs *WorkflowSpec) GetInjectionServiceAccount() string {
+	if wfs.InjectionServiceAccount != "" {
+		return wfs.InjectionServiceAccount
+	}
+	if wfs.ArgoWorkflowSpec.ServiceAccountName != "" {
+		return wfs.ArgoWorkflowSpec.ServiceAccountName
+	}
+	return "default"
+}
+
+// GetInjectionMode returns the InjectionMode of the WorkflowSpec or defaultMode if it is not set.
+func (wfs *WorkflowSpec) GetInjectionMode(defaultMode InjectionMode) InjectionMode {
+	if wfs.InjectionMode != "" {
+		return wfs.InjectionMode
+	}
+	if defaultMode != "" {
+		return defaultMode
+	}
+	return InjectionModeDaemon
+}
+
 func init() {
 	SchemeBuilder.Register(&Workflow{}, &WorkflowList{})
 }
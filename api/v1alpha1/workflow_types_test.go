@@ -6,6 +6,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 )
 
 var _ = Describe("ContentTemplate", func() {
@@ -72,3 +73,78 @@ var _ = Describe("ContentTemplate", func() {
 		})
 	})
 })
+
+var _ = Describe("InjectableValue", func() {
+	It("Should render the Content under its name", func() {
+		iv := InjectableValue{Name: "url", EnvName: "URL", Content: "{{.host}}"}
+		Expect(iv.GetType()).To(Equal(InjectableValueTypeEnv))
+		Expect(iv.Templates()).To(Equal(map[string]ContentTemplate{"url": "{{.host}}"}))
+	})
+
+	It("Should render each of the Files under its FileKey", func() {
+		iv := InjectableValue{
+			Name:      "dbt",
+			MountPath: "/home/dbt/.dbt",
+			Files: map[string]ContentTemplate{
+				"profiles.yml": "host: {{.host}}",
+			},
+		}
+		Expect(iv.GetType()).To(Equal(InjectableValueTypeDirectory))
+		Expect(iv.Templates()).To(Equal(map[string]ContentTemplate{"dbt.profiles.yml": "host: {{.host}}"}))
+	})
+})
+
+var _ = Describe("WorkflowSpec", func() {
+	Context("Resolving the InjectionMode", func() {
+		It("Should prefer the mode set on the WorkflowSpec", func() {
+			wfs := WorkflowSpec{InjectionMode: InjectionModeController}
+			Expect(wfs.GetInjectionMode(InjectionModeDaemon)).To(Equal(InjectionModeController))
+		})
+
+		It("Should fall back to the default mode", func() {
+			wfs := WorkflowSpec{}
+			Expect(wfs.GetInjectionMode(InjectionModeController)).To(Equal(InjectionModeController))
+			Expect(wfs.GetInjectionMode("")).To(Equal(InjectionModeDaemon))
+		})
+
+		It("Should reject unknown modes", func() {
+			_, err := ParseInjectionMode("Sidecar")
+			Expect(err).To(HaveOccurred())
+
+			mode, err := ParseInjectionMode("Controller")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mode).To(Equal(InjectionModeController))
+		})
+	})
+})
+
+var _ = Describe("InjectionTemplate", func() {
+	It("Should only override the fields that are set", func() {
+		defaults := InjectionTemplate{
+			Image:           "kubeetl:main",
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			NodeSelector:    map[string]string{"pool": "default"},
+		}
+		override := &InjectionTemplate{
+			Image:               "kubeetl@sha256:1234",
+			Tolerations:         []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}},
+			SecretBackendConfig: "secret-backends",
+		}
+
+		res := defaults.Merge(override)
+		Expect(res.Image).To(Equal("kubeetl@sha256:1234"))
+		Expect(res.SecretBackendConfig).To(Equal("secret-backends"))
+		Expect(res.ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+		Expect(res.NodeSelector).To(Equal(map[string]string{"pool": "default"}))
+		Expect(res.Tolerations).To(HaveLen(1))
+
+		By("Not modifying the defaults")
+		Expect(defaults.Image).To(Equal("kubeetl:main"))
+		Expect(defaults.Tolerations).To(BeNil())
+	})
+
+	It("Should return the defaults without an override", func() {
+		defaults := InjectionTemplate{Image: "kubeetl:main"}
+		Expect(defaults.Merge(nil)).To(Equal(defaults))
+	})
+})
@@ -2,6 +2,8 @@ package controllers
 
 import (
 	"fmt"
+	"path"
+	"sort"
 
 	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
 	"github.com/dataworkz/kubeetl/api/v1alpha1"
@@ -9,33 +11,98 @@ import (
 	"k8s.io/utils/pointer"
 )
 
-// createArgoWorkflowSpec creates an Argo Workflow spec based on the supplied v1alpha1.WorkflowSpec
-func createArgoWorkflowSpec(wfs v1alpha1.WorkflowSpec, wfName, connectionInjectionImage, namespace string) (wfv1.WorkflowSpec, error) {
+const (
+	// injectionCommand is the KubeETL subcommand that provides the connection secret
+	injectionCommand = "connectionprovider"
+	// secretBackendConfigVolume is the name of the volume that mounts the secret backend configuration into the injection container
+	secretBackendConfigVolume = "kubeetl-secret-backends"
+)
+
+// createArgoWorkflowSpec creates an Argo Workflow spec based on the supplied v1alpha1.WorkflowSpec.
+// The kind and wfName identify the KubeETL resource the injection step provides the connection secret for.
+// In InjectionModeController the controller populates the secret, so no injection step is added.
+// The injection container is configured by the InjectionTemplate of the WorkflowSpec merged over the defaults.
+// The expanded environment variables are injected for InjectableValues that expand the fields of their Connection.
+func createArgoWorkflowSpec(wfs v1alpha1.WorkflowSpec, kind v1alpha1.WorkflowKind, mode v1alpha1.InjectionMode, wfName, namespace string, defaults v1alpha1.InjectionTemplate, expanded map[string][]corev1.EnvVar) (wfv1.WorkflowSpec, error) {
 	spec := wfs.ArgoWorkflowSpec
+	secretName := connectionSecretName(kind, wfName)
 	v := corev1.Volume{
 		Name: v1alpha1.NameWithHash(wfName),
 		VolumeSource: corev1.VolumeSource{
 			Secret: &corev1.SecretVolumeSource{
-				SecretName: v1alpha1.NameWithHash(wfName),
+				SecretName: secretName,
 			},
 		},
 	}
 	spec.Volumes = append(spec.Volumes, v)
+	for _, iv := range wfs.InjectableValues {
+		if iv.GetType() == v1alpha1.InjectableValueTypeDirectory {
+			spec.Volumes = append(spec.Volumes, filesVolume(iv, secretName))
+		}
+	}
+
+	if mode != v1alpha1.InjectionModeController {
+		addInjectionStep(&spec, wfs, kind, wfName, namespace, defaults.Merge(wfs.Injection))
+	}
+
+	for _, ii := range wfs.InjectInto {
+		ic, err := newInjectionContext(&spec, wfs, wfName, secretName, expanded, ii)
+		if err != nil {
+			// TODO: log
+			return wfv1.WorkflowSpec{}, err
+		}
+
+		template := getTemplateByName(&spec, ii.Name)
+		if template == nil {
+			// TODO: log?
+			return wfv1.WorkflowSpec{}, fmt.Errorf("InjectInto contains missing template: %s", ii.Name)
+		}
+		if err := inject(template, ic); err != nil {
+			return wfv1.WorkflowSpec{}, err
+		}
+	}
+	return spec, nil
+}
+
+// addInjectionStep wraps the entrypoint of the spec in a steps template that first runs
+// the injection daemon, which populates the connection secret from within the Argo Workflow.
+func addInjectionStep(spec *wfv1.WorkflowSpec, wfs v1alpha1.WorkflowSpec, kind v1alpha1.WorkflowKind, wfName, namespace string, it v1alpha1.InjectionTemplate) {
+	args := []string{
+		injectionCommand,
+		"--kind",
+		string(kind),
+		"--workflow",
+		wfName,
+		"--namespace",
+		namespace,
+	}
+	if kind == v1alpha1.WorkflowKindCronWorkflow {
+		args = append(args, "--run", v1alpha1.ArgoWorkflowNameVariable)
+	}
+	if it.SecretBackendConfig != "" {
+		args = append(args, "--secret-backend-config", path.Join(v1alpha1.SecretBackendConfigMountPath, v1alpha1.SecretBackendConfigKey))
+	}
 
 	injectTmpl := wfv1.Template{
 		Name:               "run-injection",
 		Daemon:             pointer.BoolPtr(true),
 		ServiceAccountName: wfs.InjectionServiceAccount,
+		NodeSelector:       it.NodeSelector,
+		Tolerations:        it.Tolerations,
 		Container: &corev1.Container{
-			Image: connectionInjectionImage,
-			Args: []string{
-				"--workflow",
-				wfName,
-				"--namespace",
-				namespace,
-			},
+			Image:           it.Image,
+			ImagePullPolicy: it.ImagePullPolicy,
+			SecurityContext: it.SecurityContext,
+			Args:            args,
 		},
 	}
+	if it.Resources != nil {
+		injectTmpl.Container.Resources = *it.Resources
+	}
+	if it.SecretBackendConfig != "" {
+		addSecretBackendConfig(spec, &injectTmpl, it.SecretBackendConfig)
+	}
+	spec.ImagePullSecrets = appendPullSecrets(spec.ImagePullSecrets, it.ImagePullSecrets)
 
 	oldEntrypoint := spec.Entrypoint
 	steps := wfv1.Template{
@@ -62,24 +129,76 @@ func createArgoWorkflowSpec(wfs v1alpha1.WorkflowSpec, wfName, connectionInjecti
 
 	spec.Templates = append(spec.Templates, injectTmpl, steps)
 	spec.Entrypoint = steps.Name
+}
 
-	for _, ii := range wfs.InjectInto {
-		ic, err := newInjectionContext(&spec, wfs, wfName, ii)
-		if err != nil {
-			// TODO: log
-			return wfv1.WorkflowSpec{}, err
-		}
+// addSecretBackendConfig mounts the Secret with the secret backend configuration into the injection container
+func addSecretBackendConfig(spec *wfv1.WorkflowSpec, injectTmpl *wfv1.Template, secretName string) {
+	v := corev1.Volume{
+		Name: secretBackendConfigVolume,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: secretName,
+			},
+		},
+	}
+	spec.Volumes = append(spec.Volumes, v)
+	injectTmpl.Container.VolumeMounts = append(injectTmpl.Container.VolumeMounts, corev1.VolumeMount{
+		Name:      secretBackendConfigVolume,
+		MountPath: v1alpha1.SecretBackendConfigMountPath,
+		ReadOnly:  true,
+	})
+}
 
-		template := getTemplateByName(&spec, ii.Name)
-		if template == nil {
-			// TODO: log?
-			return wfv1.WorkflowSpec{}, fmt.Errorf("InjectInto contains missing template: %s", ii.Name)
+// filesVolume returns the projected volume that mounts the rendered Files of the InjectableValue
+// from the connection secret as a single directory
+func filesVolume(iv v1alpha1.InjectableValue, secretName string) corev1.Volume {
+	filenames := make([]string, 0, len(iv.Files))
+	for filename := range iv.Files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	items := make([]corev1.KeyToPath, 0, len(filenames))
+	for _, filename := range filenames {
+		items = append(items, corev1.KeyToPath{
+			Key:  iv.FileKey(filename),
+			Path: filename,
+		})
+	}
+
+	return corev1.Volume{
+		Name: v1alpha1.InjectableValueVolumeName(iv.Name),
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{
+					{
+						Secret: &corev1.SecretProjection{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+							Items:                items,
+						},
+					},
+				},
+				DefaultMode: iv.FileMode,
+			},
+		},
+	}
+}
+
+// appendPullSecrets adds the secrets that are not referenced yet
+func appendPullSecrets(existing, secrets []corev1.LocalObjectReference) []corev1.LocalObjectReference {
+	for _, ps := range secrets {
+		found := false
+		for _, e := range existing {
+			if e.Name == ps.Name {
+				found = true
+				break
+			}
 		}
-		if err := inject(template, ic); err != nil {
-			return wfv1.WorkflowSpec{}, err
+		if !found {
+			existing = append(existing, ps)
 		}
 	}
-	return spec, nil
+	return existing
 }
 
 func getTemplateByName(spec *wfv1.WorkflowSpec, name string) *wfv1.Template {
@@ -91,10 +210,21 @@ func getTemplateByName(spec *wfv1.WorkflowSpec, name string) *wfv1.Template {
 	return nil
 }
 
-func newInjectionContext(awfSpec *wfv1.WorkflowSpec, wfSpec v1alpha1.WorkflowSpec, wfName string, injection v1alpha1.TemplateRef) (*injectionContext, error) {
+// connectionSecretName returns the name of the connection secret used by the Argo Workflow.
+// Every run of a CronWorkflow uses its own secret, the other kinds share a single secret.
+func connectionSecretName(kind v1alpha1.WorkflowKind, wfName string) string {
+	if kind == v1alpha1.WorkflowKindCronWorkflow {
+		return v1alpha1.RunConnectionSecretName(v1alpha1.ArgoWorkflowNameVariable)
+	}
+	return v1alpha1.NameWithHash(wfName)
+}
+
+func newInjectionContext(awfSpec *wfv1.WorkflowSpec, wfSpec v1alpha1.WorkflowSpec, wfName, secretName string, expanded map[string][]corev1.EnvVar, injection v1alpha1.TemplateRef) (*injectionContext, error) {
 	ic := injectionContext{
 		awfSpec:        awfSpec,
 		hashedWfName:   v1alpha1.NameWithHash(wfName),
+		secretName:     secretName,
+		expandedEnv:    expanded,
 		injectedValues: make([]v1alpha1.InjectableValue, 0, len(injection.InjectedValues)),
 	}
 
@@ -112,6 +242,9 @@ type injectionContext struct {
 	injectedValues []v1alpha1.InjectableValue
 	awfSpec        *wfv1.WorkflowSpec
 	hashedWfName   string
+	secretName     string
+	// expandedEnv contains the environment variables of InjectableValues that expand the fields of their Connection
+	expandedEnv map[string][]corev1.EnvVar
 }
 
 func inject(template *wfv1.Template, ic *injectionContext) error {
@@ -133,7 +266,7 @@ func (ic *injectionContext) getSecretKeyRef(injectableValue string) corev1.Secre
 	sks := corev1.SecretKeySelector{
 		// todo: get as workflow method?
 		LocalObjectReference: corev1.LocalObjectReference{
-			Name: ic.hashedWfName,
+			Name: ic.secretName,
 		},
 		Key: injectableValue,
 	}
@@ -160,6 +293,17 @@ func injectContainer(container *corev1.Container, ic *injectionContext) error {
 				SubPath:   iv.Name,
 			}
 			container.VolumeMounts = addVolumeMount(container.VolumeMounts, vm)
+		case v1alpha1.InjectableValueTypeFields:
+			for _, ev := range ic.expandedEnv[iv.Name] {
+				container.Env = addEnvVar(container.Env, ev)
+			}
+		case v1alpha1.InjectableValueTypeDirectory:
+			vm := corev1.VolumeMount{
+				MountPath: iv.MountPath,
+				Name:      v1alpha1.InjectableValueVolumeName(iv.Name),
+				ReadOnly:  true,
+			}
+			container.VolumeMounts = addVolumeMount(container.VolumeMounts, vm)
 		}
 	}
 	return nil
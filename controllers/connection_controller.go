@@ -0,0 +1,303 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	api "github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/api/v1alpha1/validation"
+	"github.com/dataworkz/kubeetl/listers"
+	"github.com/dataworkz/kubeetl/pkg/util"
+)
+
+// ConnectionReconciler reconciles a Connection object
+type ConnectionReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=connections,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=connections/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=connectiontypes;clusterconnectiontypes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=workflows,verbs=get;list;watch
+// +kubebuilder:rbac:groups=argoproj.io,resources=workflows,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets;configmaps,verbs=get;list;watch
+
+func (r *ConnectionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("connection", req.NamespacedName)
+
+	var conn api.Connection
+	if err := r.Get(ctx, req.NamespacedName, &conn); err != nil {
+		if !errors.IsNotFound(err) {
+			log.Error(err, "unable to fetch Connection")
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	old := conn.Status.DeepCopy()
+	if err := r.validateValues(ctx, &conn); err != nil {
+		log.Error(err, "unable to validate Connection values")
+		return ctrl.Result{}, err
+	}
+
+	var result ctrl.Result
+	if hc := conn.Spec.HealthCheck; hc != nil && hc.GetMode() != api.HealthCheckDisabled {
+		wait := untilNextProbe(hc, conn.Status.LastCheckTime, time.Now())
+		if wait <= 0 {
+			healthy, msg := r.probe(ctx, &conn)
+			now := metav1.Now()
+			conn.Status.Healthy = healthy
+			conn.Status.Message = msg
+			conn.Status.LastCheckTime = &now
+			wait = hc.GetInterval()
+		}
+		if hc.GetMode() == api.HealthCheckInterval {
+			result.RequeueAfter = wait
+		}
+	}
+
+	if equality.Semantic.DeepEqual(old, &conn.Status) {
+		return result, nil
+	}
+	if err := r.Status().Update(ctx, &conn); err != nil {
+		log.Error(err, "unable to update Connection status")
+		return ctrl.Result{}, err
+	}
+	return result, nil
+}
+
+// untilNextProbe returns how long it takes until the health check of the Connection is due.
+// An Interval health check is due once the interval passed since the last check,
+// an OnUse health check whenever the Connection is reconciled.
+func untilNextProbe(hc *api.ConnectionHealthCheck, lastCheckTime *metav1.Time, now time.Time) time.Duration {
+	if hc.GetMode() != api.HealthCheckInterval || lastCheckTime == nil {
+		return 0
+	}
+	return lastCheckTime.Add(hc.GetInterval()).Sub(now)
+}
+
+// probe performs the health check configured on the Connection and returns the result
+// together with a human readable explanation.
+func (r *ConnectionReconciler) probe(ctx context.Context, conn *api.Connection) (api.HealthEnum, string) {
+	if conn.Spec.HealthCheck.Workflow != nil {
+		return r.probeWorkflow(ctx, conn.Spec.HealthCheck.Workflow)
+	}
+
+	return r.probeCredentials(ctx, conn)
+}
+
+// probeWorkflow uses the latest run of the referenced Workflow as an indication of the Connection health.
+func (r *ConnectionReconciler) probeWorkflow(ctx context.Context, ref *api.WorkflowReference) (api.HealthEnum, string) {
+	var workflow api.Workflow
+	if err := r.Get(ctx, ref.GetNamespacedName(), &workflow); err != nil {
+		return api.Unknown, fmt.Sprintf("unable to fetch health check Workflow %s: %v", ref.Name, err)
+	}
+
+	wfr := workflow.Status.ArgoWorkflowRef
+	if wfr == nil {
+		return api.Unknown, fmt.Sprintf("no Argo Workflow created for health check Workflow %s", ref.Name)
+	}
+
+	var argoWorkflow wfv1.Workflow
+	key := types.NamespacedName{
+		Name:      wfr.Name,
+		Namespace: wfr.Namespace,
+	}
+	if err := r.Get(ctx, key, &argoWorkflow); err != nil {
+		return api.Unknown, fmt.Sprintf("unable to fetch Argo Workflow %s: %v", wfr.Name, err)
+	}
+
+	switch {
+	case argoWorkflow.Status.Successful():
+		return api.Healthy, fmt.Sprintf("health check Workflow %s succeeded", ref.Name)
+	case argoWorkflow.Status.Failed():
+		return api.Unhealthy, fmt.Sprintf("health check Workflow %s failed: %s", ref.Name, argoWorkflow.Status.Message)
+	default:
+		return api.Unknown, fmt.Sprintf("health check Workflow %s has not completed", ref.Name)
+	}
+}
+
+// probeCredentials verifies that every credential of the Connection can be resolved.
+func (r *ConnectionReconciler) probeCredentials(ctx context.Context, conn *api.Connection) (api.HealthEnum, string) {
+	credReader := util.NewCredentialReader(r.Client, conn)
+	for name := range conn.Spec.Credentials {
+		if _, err := credReader.ReadValue(ctx, name); err != nil {
+			return api.Unhealthy, fmt.Sprintf("unable to resolve credential %s: %v", name, err)
+		}
+	}
+
+	return api.Healthy, "all credentials resolved"
+}
+
+// validateValues validates the values the Connection reads from Secrets and ConfigMaps if its ConnectionType
+// enables DeepValidation and reports the result in the ValuesValid condition of its status, which is not updated.
+// The resolved values are never logged or included in the condition.
+func (r *ConnectionReconciler) validateValues(ctx context.Context, conn *api.Connection) error {
+	var conType *api.ConnectionType
+	if conn.Spec.Type != "" {
+		var err error
+		conType, err = listers.NewConnectionTypeLister(r.Client).Find(ctx, conn.Namespace, conn.Spec.Type)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	if conType == nil || !conType.Spec.DeepValidation {
+		meta.RemoveStatusCondition(&conn.Status.Conditions, api.ConnectionConditionValuesValid)
+		return nil
+	}
+
+	cond := resolvedValuesCondition(ctx, util.NewCredentialReader(r.Client, conn), conn.Spec.Credentials, conType.Spec.Fields)
+	cond.ObservedGeneration = conn.Generation
+	meta.SetStatusCondition(&conn.Status.Conditions, cond)
+	return nil
+}
+
+// resolvedValuesCondition resolves the credentials that are read from a Secret or ConfigMap and have a Validation
+// and returns the ValuesValid condition describing whether the resolved values are valid.
+func resolvedValuesCondition(ctx context.Context, credReader util.CredentialReader, creds api.Credentials, fields []api.CredentialFieldSpec) metav1.Condition {
+	values := make(map[string]string)
+	var unresolved []string
+	for _, f := range fields {
+		v, ok := creds[f.Name]
+		if !ok || v.ValueFrom == nil || f.Validation == nil {
+			continue
+		}
+
+		value, err := credReader.ReadValue(ctx, f.Name)
+		if err != nil {
+			unresolved = append(unresolved, f.Name)
+			continue
+		}
+		values[f.Name] = value
+	}
+
+	if len(unresolved) > 0 {
+		return metav1.Condition{
+			Type:    api.ConnectionConditionValuesValid,
+			Status:  metav1.ConditionUnknown,
+			Reason:  "UnresolvedValues",
+			Message: fmt.Sprintf("unable to resolve credentials: %s", strings.Join(unresolved, ", ")),
+		}
+	}
+
+	errs := validation.ValidateResolvedValues(values, fields, field.NewPath("spec").Child("credentials"))
+	if len(errs) > 0 {
+		return metav1.Condition{
+			Type:    api.ConnectionConditionValuesValid,
+			Status:  metav1.ConditionFalse,
+			Reason:  "InvalidValues",
+			Message: errs.ToAggregate().Error(),
+		}
+	}
+
+	return metav1.Condition{
+		Type:    api.ConnectionConditionValuesValid,
+		Status:  metav1.ConditionTrue,
+		Reason:  "ValuesValid",
+		Message: "all resolved values are valid",
+	}
+}
+
+func (r *ConnectionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.Client = mgr.GetClient()
+	r.Scheme = mgr.GetScheme()
+
+	wfKind := &source.Kind{Type: &api.Workflow{}}
+	// the values of a Connection are revalidated when a Secret or ConfigMap it reads from or its type changes
+	valueSourceChanged := predicate.Funcs{UpdateFunc: credentialsChangedPredicate.UpdateFunc}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.Connection{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(wfKind, connectionUsageEventHandler()).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.valueSourceConnectionRequests),
+			builder.WithPredicates(valueSourceChanged)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.valueSourceConnectionRequests),
+			builder.WithPredicates(valueSourceChanged)).
+		Watches(&source.Kind{Type: &api.ConnectionType{}}, handler.EnqueueRequestsFromMapFunc(r.typeConnectionRequests),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &api.ClusterConnectionType{}}, handler.EnqueueRequestsFromMapFunc(r.typeConnectionRequests),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
+
+// valueSourceConnectionRequests maps a Secret or ConfigMap to the Connections that read credentials from it
+func (r *ConnectionReconciler) valueSourceConnectionRequests(obj client.Object) []reconcile.Request {
+	key, ok := objectValueSourceKey(obj)
+	if !ok {
+		return nil
+	}
+	return r.indexedConnectionRequests(client.InNamespace(obj.GetNamespace()), client.MatchingFields{connectionValueSourceIndex: key})
+}
+
+// typeConnectionRequests maps a ConnectionType to the Connections of that type in its namespace,
+// and a ClusterConnectionType to the Connections of that type in all namespaces
+func (r *ConnectionReconciler) typeConnectionRequests(obj client.Object) []reconcile.Request {
+	opts := []client.ListOption{client.MatchingFields{connectionTypeIndex: obj.GetName()}}
+	if obj.GetNamespace() != "" {
+		opts = append(opts, client.InNamespace(obj.GetNamespace()))
+	}
+	return r.indexedConnectionRequests(opts...)
+}
+
+// indexedConnectionRequests returns a request for each Connection matching the list options
+func (r *ConnectionReconciler) indexedConnectionRequests(opts ...client.ListOption) []reconcile.Request {
+	var conns api.ConnectionList
+	if err := r.List(context.Background(), &conns, opts...); err != nil {
+		r.Log.Error(err, "unable to list Connections")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(conns.Items))
+	for _, conn := range conns.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: conn.Name, Namespace: conn.Namespace}})
+	}
+	return requests
+}
+
+// connectionUsageEventHandler returns a custom event handler to translate Workflow events into Connection events.
+// Every Connection referenced by an InjectableValue of the Workflow is enqueued, which allows the
+// reconciler to perform OnUse health checks. The Connection is in the namespace of its reference,
+// which defaults to the namespace of the workflow.
+func connectionUsageEventHandler() handler.EventHandler {
+	mapFn := func(obj client.Object) []reconcile.Request {
+		wf, ok := obj.(*api.Workflow)
+		if !ok {
+			return []reconcile.Request{}
+		}
+
+		var requests []reconcile.Request
+		for _, iv := range wf.Spec.InjectableValues {
+			if iv.ConnectionRef.Name == "" {
+				continue
+			}
+			req := reconcile.Request{
+				NamespacedName: iv.ConnectionRef.GetNamespacedName(wf.Namespace),
+			}
+			requests = append(requests, req)
+		}
+		return requests
+	}
+
+	return handler.EnqueueRequestsFromMapFunc(mapFn)
+}
@@ -0,0 +1,403 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	api "github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/pkg/util"
+)
+
+var _ = Describe("ConnectionReconciler", func() {
+	const timeout = time.Second * 5
+	const interval = time.Second * 1
+
+	createConnection := func(ctx context.Context, key types.NamespacedName, spec api.ConnectionSpec) *api.Connection {
+		conn := &api.Connection{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+			},
+			Spec: spec,
+		}
+		Expect(k8sClient.Create(ctx, conn)).Should(Succeed())
+		return conn
+	}
+
+	getHealth := func(ctx context.Context, key types.NamespacedName) func() api.HealthEnum {
+		return func() api.HealthEnum {
+			res := &api.Connection{}
+			if err := k8sClient.Get(ctx, key, res); err != nil {
+				return ""
+			}
+			return res.Status.Healthy
+		}
+	}
+
+	Context("Connection with the built-in health check", func() {
+		It("Should mark the Connection Healthy if all credentials resolve", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{
+				Name:      randomSuffix("healthy-connection"),
+				Namespace: "default",
+			}
+
+			conn := createConnection(ctx, key, api.ConnectionSpec{
+				Credentials: api.Credentials{
+					"host": api.Value{Value: "localhost"},
+				},
+				HealthCheck: &api.ConnectionHealthCheck{},
+			})
+
+			Eventually(getHealth(ctx, key), timeout, interval).Should(Equal(api.Healthy))
+
+			res := &api.Connection{}
+			Expect(k8sClient.Get(ctx, key, res)).Should(Succeed())
+			Expect(res.Status.LastCheckTime).ToNot(BeNil())
+
+			Expect(k8sClient.Delete(ctx, conn)).Should(Succeed())
+		})
+
+		It("Should mark the Connection Unhealthy if a credential cannot be resolved", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{
+				Name:      randomSuffix("unhealthy-connection"),
+				Namespace: "default",
+			}
+
+			conn := createConnection(ctx, key, api.ConnectionSpec{
+				Credentials: api.Credentials{
+					"password": api.Value{
+						ValueFrom: &api.ValueSource{
+							SecretKeyRef: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{Name: randomSuffix("missing-secret")},
+								Key:                  "password",
+							},
+						},
+					},
+				},
+				HealthCheck: &api.ConnectionHealthCheck{
+					Mode: api.HealthCheckOnUse,
+				},
+			})
+
+			Eventually(getHealth(ctx, key), timeout, interval).Should(Equal(api.Unhealthy))
+
+			Expect(k8sClient.Delete(ctx, conn)).Should(Succeed())
+		})
+	})
+
+	Context("Connection with a Workflow health check", func() {
+		It("Should set Connection health to Unknown for an unknown Workflow", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{
+				Name:      randomSuffix("workflow-connection"),
+				Namespace: "default",
+			}
+
+			conn := createConnection(ctx, key, api.ConnectionSpec{
+				Credentials: api.Credentials{},
+				HealthCheck: &api.ConnectionHealthCheck{
+					Workflow: &api.WorkflowReference{
+						Namespace: "default",
+						Name:      "unknown-wf",
+					},
+				},
+			})
+
+			Eventually(getHealth(ctx, key), timeout, interval).Should(Equal(api.Unknown))
+
+			Expect(k8sClient.Delete(ctx, conn)).Should(Succeed())
+		})
+	})
+
+	Context("Connection with a disabled health check", func() {
+		It("Should not update the Connection status", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{
+				Name:      randomSuffix("disabled-connection"),
+				Namespace: "default",
+			}
+
+			conn := createConnection(ctx, key, api.ConnectionSpec{
+				Credentials: api.Credentials{
+					"host": api.Value{Value: "localhost"},
+				},
+				HealthCheck: &api.ConnectionHealthCheck{
+					Mode: api.HealthCheckDisabled,
+				},
+			})
+
+			Consistently(getHealth(ctx, key), timeout, interval).Should(BeEmpty())
+
+			Expect(k8sClient.Delete(ctx, conn)).Should(Succeed())
+		})
+	})
+
+	Context("Connection with a ConnectionType that enables deep validation", func() {
+		It("Should report invalid values from a Secret without revealing them", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{
+				Name:      randomSuffix("deep-validation-connection"),
+				Namespace: "default",
+			}
+
+			conType := &api.ConnectionType{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      randomSuffix("deep-validation-type"),
+					Namespace: key.Namespace,
+				},
+				Spec: api.ConnectionTypeSpec{
+					DeepValidation: true,
+					Fields: []api.CredentialFieldSpec{
+						{
+							Name:       "password",
+							Sensitive:  true,
+							Validation: &api.Validation{MinLength: pointer.Int32Ptr(12)},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, conType)).Should(Succeed())
+
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      randomSuffix("deep-validation-secret"),
+					Namespace: key.Namespace,
+				},
+				StringData: map[string]string{"password": "too-short"},
+			}
+			Expect(k8sClient.Create(ctx, secret)).Should(Succeed())
+
+			conn := createConnection(ctx, key, api.ConnectionSpec{
+				Type: conType.Name,
+				Credentials: api.Credentials{
+					"password": api.Value{
+						ValueFrom: &api.ValueSource{
+							SecretKeyRef: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+								Key:                  "password",
+							},
+						},
+					},
+				},
+			})
+
+			var cond *metav1.Condition
+			Eventually(func() *metav1.Condition {
+				res := &api.Connection{}
+				if err := k8sClient.Get(ctx, key, res); err != nil {
+					return nil
+				}
+				cond = meta.FindStatusCondition(res.Status.Conditions, api.ConnectionConditionValuesValid)
+				return cond
+			}, timeout, interval).ShouldNot(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal("InvalidValues"))
+			Expect(cond.Message).To(ContainSubstring("spec.credentials.password"))
+			Expect(cond.Message).ToNot(ContainSubstring("too-short"))
+
+			By("Revalidating the values once the Secret changes")
+			Eventually(func() error {
+				res := &corev1.Secret{}
+				if err := k8sClient.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, res); err != nil {
+					return err
+				}
+				res.Data["password"] = []byte("long-enough-password")
+				return k8sClient.Update(ctx, res)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() metav1.ConditionStatus {
+				res := &api.Connection{}
+				if err := k8sClient.Get(ctx, key, res); err != nil {
+					return ""
+				}
+				if cond := meta.FindStatusCondition(res.Status.Conditions, api.ConnectionConditionValuesValid); cond != nil {
+					return cond.Status
+				}
+				return ""
+			}, timeout, interval).Should(Equal(metav1.ConditionTrue))
+
+			By("Revalidating the values once the ConnectionType changes")
+			Eventually(func() error {
+				res := &api.ConnectionType{}
+				if err := k8sClient.Get(ctx, types.NamespacedName{Name: conType.Name, Namespace: conType.Namespace}, res); err != nil {
+					return err
+				}
+				res.Spec.Fields[0].Validation.MinLength = pointer.Int32Ptr(32)
+				return k8sClient.Update(ctx, res)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() metav1.ConditionStatus {
+				res := &api.Connection{}
+				if err := k8sClient.Get(ctx, key, res); err != nil {
+					return ""
+				}
+				if cond := meta.FindStatusCondition(res.Status.Conditions, api.ConnectionConditionValuesValid); cond != nil {
+					return cond.Status
+				}
+				return ""
+			}, timeout, interval).Should(Equal(metav1.ConditionFalse))
+
+			Expect(k8sClient.Delete(ctx, conn)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, secret)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, conType)).Should(Succeed())
+		})
+	})
+})
+
+var _ = Describe("Validating resolved values", func() {
+	It("Should not reveal the content of the Secret in the ValuesValid condition", func() {
+		ctx := context.Background()
+		s := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(s)).To(Succeed())
+		Expect(api.AddToScheme(s)).To(Succeed())
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "tokens", Namespace: "default"},
+			Data:       map[string][]byte{"tokens": []byte(`{"admin-token": "s3cr3t"}`)},
+		}
+		conn := &api.Connection{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+			Spec: api.ConnectionSpec{
+				Credentials: api.Credentials{
+					"tokens": api.Value{ValueFrom: &api.ValueSource{SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+						Key:                  "tokens",
+					}}},
+				},
+			},
+		}
+		fields := []api.CredentialFieldSpec{{
+			Name:      "tokens",
+			Sensitive: true,
+			Validation: &api.Validation{JSONSchema: &apiextensionsv1.JSON{
+				Raw: []byte(`{"type": "object", "additionalProperties": {"type": "integer"}}`),
+			}},
+		}}
+		cl := fake.NewClientBuilder().WithScheme(s).WithObjects(secret).Build()
+
+		cond := resolvedValuesCondition(ctx, util.NewCredentialReader(cl, conn), conn.Spec.Credentials, fields)
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Message).To(ContainSubstring("spec.credentials.tokens"))
+		Expect(cond.Message).ToNot(ContainSubstring("admin-token"))
+		Expect(cond.Message).ToNot(ContainSubstring("s3cr3t"))
+	})
+})
+
+var _ = Describe("Scheduling Connection health checks", func() {
+	var (
+		ctx     context.Context
+		r       *ConnectionReconciler
+		conn    *api.Connection
+		updates int
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		s := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(s)).To(Succeed())
+		Expect(api.AddToScheme(s)).To(Succeed())
+
+		conType := &api.ConnectionType{
+			ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "default"},
+			Spec: api.ConnectionTypeSpec{
+				DeepValidation: true,
+				Fields:         []api.CredentialFieldSpec{{Name: "port", Validation: &api.Validation{Format: api.ValueFormatPort}}},
+			},
+		}
+		config := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "default"},
+			Data:       map[string]string{"port": "3306"},
+		}
+		lastCheckTime := metav1.NewTime(time.Now().Add(-10 * time.Minute))
+		conn = &api.Connection{
+			ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "default"},
+			Spec: api.ConnectionSpec{
+				Type: conType.Name,
+				Credentials: api.Credentials{
+					"port": api.Value{ValueFrom: &api.ValueSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: config.Name},
+						Key:                  "port",
+					}}},
+				},
+				HealthCheck: &api.ConnectionHealthCheck{Interval: &metav1.Duration{Duration: time.Hour}},
+			},
+			Status: api.ConnectionStatus{Healthy: api.Healthy, LastCheckTime: &lastCheckTime},
+		}
+
+		updates = 0
+		cl := fake.NewClientBuilder().WithScheme(s).WithObjects(conType, config, conn).Build()
+		r = &ConnectionReconciler{
+			Client: statusUpdateCounter{Client: cl, updates: &updates},
+			Log:    ctrl.Log.WithName("controllers").WithName("Connection"),
+		}
+	})
+
+	reconcileConnection := func() (ctrl.Result, *api.Connection) {
+		key := types.NamespacedName{Name: conn.Name, Namespace: conn.Namespace}
+		result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ToNot(HaveOccurred())
+		res := &api.Connection{}
+		Expect(r.Get(ctx, key, res)).To(Succeed())
+		return result, res
+	}
+
+	It("Should wait for the remainder of the interval before checking the health again", func() {
+		result, res := reconcileConnection()
+		Expect(result.RequeueAfter).To(BeNumerically("~", 50*time.Minute, time.Minute))
+		Expect(res.Status.LastCheckTime.Time).To(BeTemporally("~", conn.Status.LastCheckTime.Time, time.Second))
+		Expect(meta.IsStatusConditionTrue(res.Status.Conditions, api.ConnectionConditionValuesValid)).To(BeTrue())
+		Expect(updates).To(Equal(1))
+
+		By("Not updating the status if nothing changed")
+		reconcileConnection()
+		Expect(updates).To(Equal(1))
+	})
+
+	It("Should check the health once the interval passed and update the status once", func() {
+		Expect(r.Get(ctx, types.NamespacedName{Name: conn.Name, Namespace: conn.Namespace}, conn)).To(Succeed())
+		lastCheckTime := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+		conn.Status.LastCheckTime = &lastCheckTime
+		Expect(r.Status().Update(ctx, conn)).To(Succeed())
+		updates = 0
+
+		result, res := reconcileConnection()
+		Expect(result.RequeueAfter).To(Equal(time.Hour))
+		Expect(res.Status.LastCheckTime.Time).To(BeTemporally("~", time.Now(), time.Minute))
+		Expect(meta.IsStatusConditionTrue(res.Status.Conditions, api.ConnectionConditionValuesValid)).To(BeTrue())
+		Expect(updates).To(Equal(1))
+	})
+})
+
+// statusUpdateCounter counts the updates of the status of objects
+type statusUpdateCounter struct {
+	client.Client
+	updates *int
+}
+
+func (c statusUpdateCounter) Status() client.StatusWriter {
+	return countingStatusWriter{StatusWriter: c.Client.Status(), updates: c.updates}
+}
+
+type countingStatusWriter struct {
+	client.StatusWriter
+	updates *int
+}
+
+func (w countingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	*w.updates++
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
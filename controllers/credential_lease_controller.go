@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/pkg/util"
+)
+
+// CredentialLeaseReconciler revokes the credentials issued for the connection secret of an Argo Workflow
+// as soon as the Argo Workflow completes, instead of waiting for them to expire
+type CredentialLeaseReconciler struct {
+	client.Client
+	Log logr.Logger
+	// NewCredentialIssuer returns the CredentialIssuer of a Connection. Defaults to util.NewCredentialIssuer.
+	NewCredentialIssuer func(client.Client, *v1alpha1.Connection) (util.CredentialIssuer, error)
+}
+
+// +kubebuilder:rbac:groups=argoproj.io,resources=workflows,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=connections,verbs=get;list;watch
+
+func (r *CredentialLeaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("workflow", req.NamespacedName)
+
+	var awf wfv1.Workflow
+	if err := r.Get(ctx, req.NamespacedName, &awf); err != nil {
+		// the connection secret is garbage collected with the Argo Workflow and its credentials expire
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !awf.Status.Fulfilled() {
+		return ctrl.Result{}, nil
+	}
+
+	// a Workflow owns the connection secret of its Argo Workflow, a CronWorkflow run has a run secret
+	secrets := []corev1.Secret{
+		v1alpha1.ConnectionSecret(awf.Name, awf.Namespace),
+		v1alpha1.RunConnectionSecret(awf.Name, awf.Namespace),
+	}
+	for _, secret := range secrets {
+		key := types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}
+		if err := r.revokeLeases(ctx, log, key); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// revokeLeases revokes the credentials recorded on the secret and records the leases that could not be revoked.
+// The annotation is kept when all leases are revoked, so the secret is not rendered again.
+func (r *CredentialLeaseReconciler) revokeLeases(ctx context.Context, log logr.Logger, key types.NamespacedName) error {
+	var secret corev1.Secret
+	if err := r.Get(ctx, key, &secret); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	leases, err := v1alpha1.GetCredentialLeases(&secret)
+	if err != nil {
+		return err
+	}
+	if len(leases) == 0 {
+		return nil
+	}
+
+	remaining := []v1alpha1.CredentialLease{}
+	var revokeErr error
+	for _, lease := range leases {
+		if lease.ExpiresAt.Time.Before(time.Now()) {
+			continue
+		}
+		if err := r.revoke(ctx, lease); err != nil {
+			revokeErr = err
+			remaining = append(remaining, lease)
+			continue
+		}
+		log.Info("revoked issued credentials", "connection", lease.Name, "secret", secret.Name)
+	}
+
+	if err := v1alpha1.SetCredentialLeases(&secret, remaining); err != nil {
+		return err
+	}
+	if len(remaining) == 0 {
+		secret.Annotations[v1alpha1.CredentialLeasesAnnotation] = "[]"
+	}
+	if err := r.Update(ctx, &secret); err != nil {
+		return fmt.Errorf("unable to update connection secret %s: %w", secret.Name, err)
+	}
+	return revokeErr
+}
+
+func (r *CredentialLeaseReconciler) revoke(ctx context.Context, lease v1alpha1.CredentialLease) error {
+	var conn v1alpha1.Connection
+	err := r.Get(ctx, types.NamespacedName{Name: lease.Name, Namespace: lease.Namespace}, &conn)
+	if errors.IsNotFound(err) {
+		// without its Connection the issuer is unknown, so the credentials expire at the end of their lease
+		r.Log.Info("unable to revoke credentials of deleted Connection", "connection", lease.Name, "namespace", lease.Namespace)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to fetch Connection %s: %w", lease.Name, err)
+	}
+
+	issuer, err := r.NewCredentialIssuer(r.Client, &conn)
+	if err != nil {
+		return err
+	}
+	return issuer.Revoke(ctx, lease.LeaseID)
+}
+
+func (r *CredentialLeaseReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.Client = mgr.GetClient()
+	if r.NewCredentialIssuer == nil {
+		r.NewCredentialIssuer = util.NewCredentialIssuer
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("credentiallease").
+		For(&wfv1.Workflow{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			awf, ok := obj.(*wfv1.Workflow)
+			return ok && awf.Status.Fulfilled()
+		}))).
+		Complete(r)
+}
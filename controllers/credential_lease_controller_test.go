@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/pkg/util"
+)
+
+// recordingIssuer records the leases it revokes
+type recordingIssuer struct {
+	revoked []string
+}
+
+func (i *recordingIssuer) Issue(ctx context.Context, ttl time.Duration) (*util.IssuedCredentials, error) {
+	return &util.IssuedCredentials{}, nil
+}
+
+func (i *recordingIssuer) Revoke(ctx context.Context, leaseID string) error {
+	i.revoked = append(i.revoked, leaseID)
+	return nil
+}
+
+var _ = Describe("CredentialLeaseReconciler", func() {
+	It("Should revoke the credentials issued for a run once it completes", func() {
+		ctx := context.Background()
+		namespace := "default"
+
+		conn := api.Connection{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      randomSuffix("issuing-connection"),
+				Namespace: namespace,
+			},
+			Spec: api.ConnectionSpec{
+				Credentials: api.Credentials{},
+				CredentialIssuer: &api.CredentialIssuer{
+					Fields:  []string{"password"},
+					Webhook: &api.WebhookCredentialIssuer{URL: "https://issuer.example.com"},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &conn)).To(Succeed())
+
+		awf := wfv1.Workflow{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      generateWorkflowName(),
+				Namespace: namespace,
+			},
+		}
+		Expect(k8sClient.Create(ctx, &awf)).To(Succeed())
+
+		secret := api.RunConnectionSecret(awf.Name, namespace)
+		Expect(api.SetCredentialLeases(&secret, []api.CredentialLease{
+			{Namespace: namespace, Name: conn.Name, LeaseID: "active", ExpiresAt: metav1.NewTime(time.Now().Add(time.Hour))},
+			{Namespace: namespace, Name: conn.Name, LeaseID: "expired", ExpiresAt: metav1.NewTime(time.Now().Add(-time.Hour))},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &secret)).To(Succeed())
+
+		issuer := &recordingIssuer{}
+		r := &CredentialLeaseReconciler{
+			Client: k8sClient,
+			Log:    ctrl.Log.WithName("controllers").WithName("CredentialLease"),
+			NewCredentialIssuer: func(client.Client, *api.Connection) (util.CredentialIssuer, error) {
+				return issuer, nil
+			},
+		}
+		req := ctrl.Request{NamespacedName: types.NamespacedName{Name: awf.Name, Namespace: namespace}}
+
+		By("Keeping the credentials while the run is active")
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(issuer.revoked).To(BeEmpty())
+
+		By("Revoking the credentials that have not expired once the run completes")
+		Expect(k8sClient.Get(ctx, req.NamespacedName, &awf)).To(Succeed())
+		awf.Status.Phase = wfv1.NodeSucceeded
+		Expect(k8sClient.Update(ctx, &awf)).To(Succeed())
+
+		_, err = r.Reconcile(ctx, req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(issuer.revoked).To(Equal([]string{"active"}))
+
+		var res corev1.Secret
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: namespace}, &res)).To(Succeed())
+		leases, err := api.GetCredentialLeases(&res)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(leases).To(BeEmpty())
+		Expect(res.Annotations).To(HaveKey(api.CredentialLeasesAnnotation))
+	})
+})
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/internal/provider"
+	"github.com/dataworkz/kubeetl/listers"
+)
+
+// credentialDependent is a resource that injects a Connection or DataSet referring to a changed Secret or ConfigMap
+type credentialDependent struct {
+	object client.Object
+	// via describes the Connection or DataSet through which the resource depends on the credentials
+	via string
+}
+
+// credentialDependents returns the resources of the list type that inject a Connection or DataSet
+// referring to the Secret or ConfigMap with the given index key.
+func credentialDependents(ctx context.Context, cl client.Client, namespace, key string, newList func() client.ObjectList) ([]credentialDependent, error) {
+	var conns v1alpha1.ConnectionList
+	if err := cl.List(ctx, &conns, client.InNamespace(namespace), client.MatchingFields{connectionValueSourceIndex: key}); err != nil {
+		return nil, fmt.Errorf("unable to list Connections: %w", err)
+	}
+
+	var datasets v1alpha1.DataSetList
+	if err := cl.List(ctx, &datasets, client.InNamespace(namespace), client.MatchingFields{dataSetValueSourceIndex: key}); err != nil {
+		return nil, fmt.Errorf("unable to list DataSets: %w", err)
+	}
+	for _, conn := range conns.Items {
+		var connDataSets v1alpha1.DataSetList
+		if err := cl.List(ctx, &connDataSets, client.InNamespace(namespace), client.MatchingFields{listers.DataSetConnectionIndex: conn.Name}); err != nil {
+			return nil, fmt.Errorf("unable to list DataSets: %w", err)
+		}
+		datasets.Items = append(datasets.Items, connDataSets.Items...)
+	}
+
+	var dependents []credentialDependent
+	seen := make(map[types.NamespacedName]bool)
+	collect := func(via string, opts ...client.ListOption) error {
+		list := newList()
+		if err := cl.List(ctx, list, opts...); err != nil {
+			return fmt.Errorf("unable to list dependents of %s: %w", via, err)
+		}
+		return meta.EachListItem(list, func(o runtime.Object) error {
+			obj := o.(client.Object)
+			key := types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}
+			if seen[key] {
+				return nil
+			}
+			seen[key] = true
+			dependents = append(dependents, credentialDependent{object: obj, via: via})
+			return nil
+		})
+	}
+
+	for _, conn := range conns.Items {
+		via := fmt.Sprintf("Connection %s", conn.Name)
+		if err := collect(via, client.InNamespace(namespace), client.MatchingFields{listers.WorkflowConnectionIndex: conn.Name}); err != nil {
+			return nil, err
+		}
+		// resources in other namespaces a ConnectionGrant shares the Connection with
+		connKey := types.NamespacedName{Name: conn.Name, Namespace: conn.Namespace}
+		if err := collect(fmt.Sprintf("Connection %s", connKey), client.MatchingFields{listers.WorkflowGrantedConnectionIndex: connKey.String()}); err != nil {
+			return nil, err
+		}
+	}
+	for _, ds := range datasets.Items {
+		if err := collect(fmt.Sprintf("DataSet %s", ds.Name), client.InNamespace(namespace), client.MatchingFields{listers.WorkflowDataSetIndex: ds.Name}); err != nil {
+			return nil, err
+		}
+	}
+
+	return dependents, nil
+}
+
+// credentialsChangedPredicate passes the updates of Secrets and ConfigMaps that change their data.
+// Creations, including those replayed when the cache syncs, and deletions don't change rendered credentials.
+var credentialsChangedPredicate = predicate.Funcs{
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		switch old := e.ObjectOld.(type) {
+		case *corev1.Secret:
+			new, ok := e.ObjectNew.(*corev1.Secret)
+			return ok && !equality.Semantic.DeepEqual(old.Data, new.Data)
+		case *corev1.ConfigMap:
+			new, ok := e.ObjectNew.(*corev1.ConfigMap)
+			return ok && (!equality.Semantic.DeepEqual(old.Data, new.Data) || !equality.Semantic.DeepEqual(old.BinaryData, new.BinaryData))
+		}
+		return false
+	},
+}
+
+// credentialRolloutHandler maps a changed Secret or ConfigMap to the resources of the list type that inject
+// a Connection or DataSet referring to it
+func credentialRolloutHandler(cl client.Client, log logr.Logger, newList func() client.ObjectList) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		key, ok := objectValueSourceKey(obj)
+		if !ok {
+			return nil
+		}
+
+		dependents, err := credentialDependents(context.Background(), cl, obj.GetNamespace(), key, newList)
+		if err != nil {
+			log.Error(err, "unable to find resources depending on changed credentials", "source", key)
+			return nil
+		}
+
+		requests := make([]reconcile.Request, 0, len(dependents))
+		for _, d := range dependents {
+			log.V(1).Info("rolling out changed credentials", "source", key, "via", d.via, "name", d.object.GetName(), "namespace", d.object.GetNamespace())
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: d.object.GetName(), Namespace: d.object.GetNamespace()},
+			})
+		}
+		return requests
+	})
+}
+
+// recordCredentialsChanged records an event on a resource of which the rendered connection secret changed
+func recordCredentialsChanged(recorder record.EventRecorder, obj client.Object, secret *corev1.Secret) {
+	recorder.Eventf(obj, corev1.EventTypeNormal, "CredentialsChanged", "Rendered changed credentials into Secret %s", secret.Name)
+}
+
+// renderedSecret is the outcome of rendering a connection secret
+type renderedSecret struct {
+	// changed is true if data that was rendered before changed
+	changed bool
+	// leases of the credentials issued while rendering, which must be revoked if the secret is not stored
+	leases []v1alpha1.CredentialLease
+}
+
+// upsertConnectionSecret creates or updates the connection secret, rendering it with the render function.
+// Credentials issued while rendering are only revoked by the CredentialLeaseReconciler once their leases
+// are stored on the secret, so they are revoked right away if the secret cannot be written.
+func upsertConnectionSecret(ctx context.Context, cl client.Client, sp provider.SecretProvider, secret *corev1.Secret, render func() (renderedSecret, error)) (controllerutil.OperationResult, renderedSecret, error) {
+	var rendered renderedSecret
+	op, err := ctrl.CreateOrUpdate(ctx, cl, secret, func() (err error) {
+		rendered, err = render()
+		return err
+	})
+	if err != nil {
+		sp.RevokeLeases(ctx, rendered.leases)
+		return op, renderedSecret{}, err
+	}
+	return op, rendered, nil
+}
+
+// renderConnectionSecret renders the InjectableValues into the connection secret.
+// In InjectionModeDaemon the injection step populates the secret, so it is only re-rendered
+// once it holds data, which rolls out credential changes to secrets that have been provided before.
+func renderConnectionSecret(ctx context.Context, sp provider.SecretProvider, kind v1alpha1.WorkflowKind, mode v1alpha1.InjectionMode, namespace string, wfs *v1alpha1.WorkflowSpec, secret *corev1.Secret) (renderedSecret, error) {
+	if mode != v1alpha1.InjectionModeController && len(secret.Data) == 0 {
+		return renderedSecret{}, nil
+	}
+	if _, issued := secret.Annotations[v1alpha1.CredentialLeasesAnnotation]; issued {
+		// issued credentials are rendered once and revoked when the run completes
+		return renderedSecret{}, nil
+	}
+
+	data, leases, err := sp.RenderSecretData(ctx, kind, namespace, wfs)
+	if err != nil {
+		return renderedSecret{}, err
+	}
+	if equality.Semantic.DeepEqual(secret.Data, data) {
+		// leave the secret untouched, so reconciles that don't change the credentials don't update it
+		sp.RevokeLeases(ctx, leases)
+		return renderedSecret{}, nil
+	}
+
+	if err := v1alpha1.SetCredentialLeases(secret, leases); err != nil {
+		sp.RevokeLeases(ctx, leases)
+		return renderedSecret{}, err
+	}
+	rendered := renderedSecret{changed: len(secret.Data) > 0, leases: leases}
+	secret.Data = data
+	return rendered, nil
+}
@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	api "github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/internal/provider"
+)
+
+var _ = Describe("Credential rollout", func() {
+	const timeout = time.Second * 5
+	const interval = time.Second * 1
+
+	It("Should re-render the connection secret when a referenced Secret changes", func() {
+		ctx := context.Background()
+		namespace := "default"
+
+		backing := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      randomSuffix("rollout-secret"),
+				Namespace: namespace,
+			},
+			StringData: map[string]string{"password": "old"},
+		}
+		Expect(k8sClient.Create(ctx, &backing)).To(Succeed())
+
+		conn := api.Connection{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      randomSuffix("rollout-connection"),
+				Namespace: namespace,
+			},
+			Spec: api.ConnectionSpec{
+				Credentials: api.Credentials{
+					"password": api.Value{
+						ValueFrom: &api.ValueSource{
+							SecretKeyRef: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{Name: backing.Name},
+								Key:                  "password",
+							},
+						},
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &conn)).To(Succeed())
+
+		wf := api.Workflow{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      generateWorkflowName(),
+				Namespace: namespace,
+			},
+			Spec: api.WorkflowSpec{
+				InjectionMode: api.InjectionModeController,
+				InjectableValues: api.InjectableValues{
+					api.InjectableValue{
+						Name:          "password",
+						ConnectionRef: api.ConnectionReference{Name: conn.Name},
+						Content:       "{{.password}}",
+					},
+				},
+				ArgoWorkflowSpec: wfv1.WorkflowSpec{},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &wf)).To(Succeed())
+
+		cs := api.ConnectionSecret(wf.Name, wf.Namespace)
+		csKey := types.NamespacedName{Name: cs.Name, Namespace: cs.Namespace}
+		renderedPassword := func() string {
+			var res corev1.Secret
+			if err := k8sClient.Get(ctx, csKey, &res); err != nil {
+				return ""
+			}
+			return string(res.Data["password"])
+		}
+		Eventually(renderedPassword, timeout, interval).Should(Equal("old"))
+
+		By("Updating the referenced Secret")
+		Eventually(func() error {
+			var res corev1.Secret
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: backing.Name, Namespace: namespace}, &res); err != nil {
+				return err
+			}
+			res.Data["password"] = []byte("new")
+			return k8sClient.Update(ctx, &res)
+		}, timeout, interval).Should(Succeed())
+
+		Eventually(renderedPassword, timeout, interval).Should(Equal("new"))
+
+		By("Recording an event on the Workflow")
+		Eventually(func(g Gomega) {
+			var events corev1.EventList
+			g.Expect(k8sClient.List(ctx, &events, client.InNamespace(namespace))).To(Succeed())
+			found := false
+			for _, e := range events.Items {
+				if e.InvolvedObject.Name == wf.Name && e.Reason == "CredentialsChanged" {
+					found = true
+				}
+			}
+			g.Expect(found).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+
+		var awf wfv1.Workflow
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: wf.Name, Namespace: namespace}, &awf)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, &wf)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, &awf)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, &conn)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, &backing)).To(Succeed())
+	})
+
+	It("Should only roll out updates that change the data of a Secret or ConfigMap", func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default", ResourceVersion: "1"},
+			Data:       map[string][]byte{"password": []byte("old")},
+		}
+		relabeled := secret.DeepCopy()
+		relabeled.ResourceVersion = "2"
+		relabeled.Labels = map[string]string{"team": "etl"}
+		changed := secret.DeepCopy()
+		changed.ResourceVersion = "3"
+		changed.Data["password"] = []byte("new")
+
+		Expect(credentialsChangedPredicate.Create(event.CreateEvent{Object: secret})).To(BeFalse())
+		Expect(credentialsChangedPredicate.Delete(event.DeleteEvent{Object: secret})).To(BeFalse())
+		Expect(credentialsChangedPredicate.Update(event.UpdateEvent{ObjectOld: secret, ObjectNew: relabeled})).To(BeFalse())
+		Expect(credentialsChangedPredicate.Update(event.UpdateEvent{ObjectOld: secret, ObjectNew: changed})).To(BeTrue())
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "default"},
+			Data:       map[string]string{"host": "db"},
+		}
+		moved := configMap.DeepCopy()
+		moved.Data["host"] = "db2"
+		Expect(credentialsChangedPredicate.Update(event.UpdateEvent{ObjectOld: configMap, ObjectNew: moved})).To(BeTrue())
+	})
+
+	It("Should leave a provided connection secret untouched if its credentials did not change", func() {
+		ctx := context.Background()
+		sp := &renderedSecretProvider{data: map[string][]byte{"password": []byte("s3cr3t")}}
+		wfs := &api.WorkflowSpec{}
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "provided", Namespace: "default", ResourceVersion: "1"},
+			Data:       map[string][]byte{"password": []byte("s3cr3t")},
+		}
+		provided := secret.DeepCopy()
+		rendered, err := renderConnectionSecret(ctx, sp, api.WorkflowKindWorkflow, api.InjectionModeDaemon, "default", wfs, secret)
+		Expect(err).To(Succeed())
+		Expect(rendered.changed).To(BeFalse())
+		Expect(secret).To(Equal(provided))
+
+		By("Re-rendering the secret once the credentials changed")
+		sp.data = map[string][]byte{"password": []byte("rotated")}
+		rendered, err = renderConnectionSecret(ctx, sp, api.WorkflowKindWorkflow, api.InjectionModeDaemon, "default", wfs, secret)
+		Expect(err).To(Succeed())
+		Expect(rendered.changed).To(BeTrue())
+		Expect(string(secret.Data["password"])).To(Equal("rotated"))
+
+		By("Leaving a secret that has not been provided yet to the injection step")
+		empty := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "empty", Namespace: "default"}}
+		rendered, err = renderConnectionSecret(ctx, sp, api.WorkflowKindWorkflow, api.InjectionModeDaemon, "default", wfs, empty)
+		Expect(err).To(Succeed())
+		Expect(rendered.changed).To(BeFalse())
+		Expect(empty.Data).To(BeEmpty())
+	})
+
+	It("Should revoke issued credentials if the connection secret cannot be written", func() {
+		ctx := context.Background()
+		lease := api.CredentialLease{Name: "issuing-connection", Namespace: "default", LeaseID: "lease-1"}
+		sp := &renderedSecretProvider{
+			data:   map[string][]byte{"password": []byte("issued")},
+			leases: []api.CredentialLease{lease},
+		}
+		s := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(s)).To(Succeed())
+		cl := failingWriteClient{fake.NewClientBuilder().WithScheme(s).Build()}
+
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "issued", Namespace: "default"}}
+		_, _, err := upsertConnectionSecret(ctx, cl, sp, secret, func() (renderedSecret, error) {
+			return renderConnectionSecret(ctx, sp, api.WorkflowKindWorkflow, api.InjectionModeController, "default", &api.WorkflowSpec{}, secret)
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(sp.revoked).To(Equal([]api.CredentialLease{lease}))
+	})
+})
+
+// renderedSecretProvider renders the same connection secret data for every WorkflowSpec and records revoked leases
+type renderedSecretProvider struct {
+	provider.SecretProvider
+	data    map[string][]byte
+	leases  []api.CredentialLease
+	revoked []api.CredentialLease
+}
+
+func (sp *renderedSecretProvider) RenderSecretData(context.Context, api.WorkflowKind, string, *api.WorkflowSpec) (map[string][]byte, []api.CredentialLease, error) {
+	return sp.data, sp.leases, nil
+}
+
+func (sp *renderedSecretProvider) RevokeLeases(ctx context.Context, leases []api.CredentialLease) {
+	sp.revoked = append(sp.revoked, leases...)
+}
+
+// failingWriteClient fails to create or update any object, as if the API server rejected the write
+type failingWriteClient struct {
+	client.Client
+}
+
+func (c failingWriteClient) Create(context.Context, client.Object, ...client.CreateOption) error {
+	return errors.NewConflict(schema.GroupResource{Resource: "secrets"}, "", fmt.Errorf("conflict"))
+}
+
+func (c failingWriteClient) Update(context.Context, client.Object, ...client.UpdateOption) error {
+	return errors.NewConflict(schema.GroupResource{Resource: "secrets"}, "", fmt.Errorf("conflict"))
+}
@@ -29,11 +29,20 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
 	"github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/internal/provider"
+	"github.com/dataworkz/kubeetl/pkg/util"
 )
 
 // CronWorkflowReconciler reconciles a CronWorkflow object
@@ -41,12 +50,30 @@ type CronWorkflowReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
-	// ConnectionInjectionImage is the image of the container that will provide connection injections
-	ConnectionInjectionImage string
+	// InjectionTemplate is the default configuration of the container that provides connection injections
+	InjectionTemplate v1alpha1.InjectionTemplate
+	// InjectionMode is the default InjectionMode for resources that do not specify one
+	InjectionMode v1alpha1.InjectionMode
+	// SecretProvider renders the connection secret in InjectionModeController
+	SecretProvider provider.SecretProvider
+	// Recorder records events when credentials of the resource change
+	Recorder record.EventRecorder
 }
 
-// +kubebuilder:rbac:groups=etl.dataworkz.nl.dataworkz.nl,resources=cronworkflows,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=etl.dataworkz.nl.dataworkz.nl,resources=cronworkflows/status,verbs=get;update;patch
+const (
+	// cronWorkflowLabel is set by Argo on every Workflow spawned from a CronWorkflow
+	cronWorkflowLabel = "workflows.argoproj.io/cron-workflow"
+)
+
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=cronworkflows,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=cronworkflows/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=connections;datasets;connectiontypes;datasettypes;clusterconnectiontypes;clusterdatasettypes;connectiongrants,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=argoproj.io,resources=cronworkflows,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=argoproj.io,resources=workflows,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
 
 func (r *CronWorkflowReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := r.Log.WithValues("workflow", req.NamespacedName)
@@ -59,38 +86,84 @@ func (r *CronWorkflowReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	cs := v1alpha1.ConnectionSecret(cwf.Name, cwf.Namespace)
-
-	log.Info("creating connection secret", "name", cs.Name, "namespace", cs.Namespace)
-
-	_, err := ctrl.CreateOrUpdate(ctx, r.Client, &cs, func() error { return r.updateSecret(&cwf, &cs) })
-	if err != nil {
-		return ctrl.Result{}, fmt.Errorf("error creating workflow connection secret: %w", err)
-	}
-
 	acwf := wfv1.CronWorkflow{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      cwf.Name,
 			Namespace: cwf.Namespace,
 		},
 	}
-	_, err = ctrl.CreateOrUpdate(ctx, r.Client, &acwf, func() error { return r.updateCronWorkflow(&cwf, &acwf) })
+	_, err := ctrl.CreateOrUpdate(ctx, r.Client, &acwf, func() error { return r.updateCronWorkflow(ctx, &cwf, &acwf) })
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("error upserting argo workflow: %w", err)
 	}
 
+	var runs wfv1.WorkflowList
+	if err := r.List(ctx, &runs, client.InNamespace(cwf.Namespace), client.MatchingLabels{cronWorkflowLabel: cwf.Name}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("error listing cron workflow runs: %w", err)
+	}
+
+	for i := range runs.Items {
+		run := &runs.Items[i]
+		if run.Status.Fulfilled() || !run.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		cs := v1alpha1.RunConnectionSecret(run.Name, run.Namespace)
+		op, rendered, err := upsertConnectionSecret(ctx, r.Client, r.SecretProvider, &cs, func() (renderedSecret, error) {
+			return r.updateRunSecret(ctx, &cwf, run, &cs)
+		})
+		if util.IsAccessDenied(err) {
+			r.Recorder.Event(&cwf, corev1.EventTypeWarning, "AccessDenied", err.Error())
+			return ctrl.Result{RequeueAfter: blockedRequeueInterval}, nil
+		}
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("error creating run connection secret: %w", err)
+		}
+		if op == controllerutil.OperationResultCreated {
+			log.Info("created run connection secret", "name", cs.Name, "run", run.Name)
+		}
+		if rendered.changed {
+			recordCredentialsChanged(r.Recorder, &cwf, &cs)
+		}
+	}
+
 	return ctrl.Result{}, nil
 }
 
-func (r *CronWorkflowReconciler) updateSecret(cwf *v1alpha1.CronWorkflow, secret *corev1.Secret) error {
-	if err := ctrl.SetControllerReference(cwf, secret, r.Scheme); err != nil {
-		return fmt.Errorf("error setting owner reference on connection secret: %w", err)
+// updateRunSecret makes the Argo Workflow run the owner of its connection secret,
+// so the secret is garbage collected together with the run.
+// In InjectionModeController the secret is rendered once, so the run sees a consistent snapshot of the credentials.
+// In InjectionModeDaemon the secret provided by the injection step of the run is re-rendered when credentials change.
+func (r *CronWorkflowReconciler) updateRunSecret(ctx context.Context, cwf *v1alpha1.CronWorkflow, run *wfv1.Workflow, secret *corev1.Secret) (renderedSecret, error) {
+	if err := ctrl.SetControllerReference(run, secret, r.Scheme); err != nil {
+		return renderedSecret{}, fmt.Errorf("error setting owner reference on run connection secret: %w", err)
 	}
-	return nil
+	mode := cwf.Spec.WorkflowSpec.GetInjectionMode(r.InjectionMode)
+	if mode != v1alpha1.InjectionModeController {
+		return renderConnectionSecret(ctx, r.SecretProvider, v1alpha1.WorkflowKindCronWorkflow, mode, cwf.Namespace, &cwf.Spec.WorkflowSpec, secret)
+	}
+	if len(secret.Data) > 0 {
+		return renderedSecret{}, nil
+	}
+
+	data, leases, err := r.SecretProvider.RenderSecretData(ctx, v1alpha1.WorkflowKindCronWorkflow, cwf.Namespace, &cwf.Spec.WorkflowSpec)
+	if err != nil {
+		return renderedSecret{}, err
+	}
+	if err := v1alpha1.SetCredentialLeases(secret, leases); err != nil {
+		r.SecretProvider.RevokeLeases(ctx, leases)
+		return renderedSecret{}, err
+	}
+	secret.Data = data
+	return renderedSecret{leases: leases}, nil
 }
 
-func (r *CronWorkflowReconciler) updateCronWorkflow(cwf *v1alpha1.CronWorkflow, acwf *wfv1.CronWorkflow) error {
-	awfSpec, err := createArgoWorkflowSpec(cwf.Spec.WorkflowSpec, acwf.Name, r.ConnectionInjectionImage, acwf.Namespace)
+func (r *CronWorkflowReconciler) updateCronWorkflow(ctx context.Context, cwf *v1alpha1.CronWorkflow, acwf *wfv1.CronWorkflow) error {
+	expanded, err := expandFields(ctx, r.Client, cwf.Namespace, connectionSecretName(v1alpha1.WorkflowKindCronWorkflow, acwf.Name), cwf.Spec.WorkflowSpec)
+	if err != nil {
+		return fmt.Errorf("error expanding connection fields: %w", err)
+	}
+	awfSpec, err := createArgoWorkflowSpec(cwf.Spec.WorkflowSpec, v1alpha1.WorkflowKindCronWorkflow, cwf.Spec.WorkflowSpec.GetInjectionMode(r.InjectionMode), acwf.Name, acwf.Namespace, r.InjectionTemplate, expanded)
 	if err != nil {
 		return fmt.Errorf("error creating argo workflow spec: %w", err)
 	}
@@ -102,8 +175,36 @@ func (r *CronWorkflowReconciler) updateCronWorkflow(cwf *v1alpha1.CronWorkflow,
 	return nil
 }
 
+// runEventHandler maps Argo Workflows spawned by a CronWorkflow to the owning KubeETL CronWorkflow
+func runEventHandler() handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		name, ok := obj.GetLabels()[cronWorkflowLabel]
+		if !ok {
+			return nil
+		}
+		return []reconcile.Request{
+			{NamespacedName: types.NamespacedName{Name: name, Namespace: obj.GetNamespace()}},
+		}
+	})
+}
+
 func (r *CronWorkflowReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.Client = mgr.GetClient()
+	r.Scheme = mgr.GetScheme()
+	if r.SecretProvider == nil {
+		r.SecretProvider = provider.NewAccessReviewingSecretProvider(r.Client)
+	}
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor("cronworkflow-controller")
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.CronWorkflow{}).
+		Owns(&wfv1.CronWorkflow{}).
+		Watches(&source.Kind{Type: &wfv1.Workflow{}}, runEventHandler()).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, credentialRolloutHandler(r.Client, r.Log, func() client.ObjectList { return &v1alpha1.CronWorkflowList{} }),
+			builder.WithPredicates(credentialsChangedPredicate)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, credentialRolloutHandler(r.Client, r.Log, func() client.ObjectList { return &v1alpha1.CronWorkflowList{} }),
+			builder.WithPredicates(credentialsChangedPredicate)).
 		Complete(r)
 }
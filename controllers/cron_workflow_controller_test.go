@@ -58,7 +58,7 @@ var _ = Describe("CronWorkflowReconciler", func() {
 				InjectableValues: api.InjectableValues{
 					api.InjectableValue{
 						Name:          "injectable-host",
-						ConnectionRef: v1.LocalObjectReference{Name: "default-connection"},
+						ConnectionRef: v1alpha1.ConnectionReference{Name: "default-connection"},
 						Content:       "{{.Host}}",
 						MountPath:     mountPath,
 					},
@@ -147,7 +147,7 @@ var _ = Describe("CronWorkflowReconciler", func() {
 				InjectableValues: api.InjectableValues{
 					api.InjectableValue{
 						Name:          "injectable-host",
-						ConnectionRef: v1.LocalObjectReference{Name: "default-connection"},
+						ConnectionRef: v1alpha1.ConnectionReference{Name: "default-connection"},
 						Content:       "{{.Host}}",
 						EnvName:       "HOST",
 					},
@@ -207,7 +207,7 @@ var _ = Describe("CronWorkflowReconciler", func() {
 				g.Expect(err).ToNot(HaveOccurred())
 
 				for _, c := range containers {
-					isInjected := envContainsInjectableValue(c.Env, *iv, v1alpha1.ConnectionSecret(created.Name, created.Namespace).Name)
+					isInjected := envContainsInjectableValue(c.Env, *iv, v1alpha1.RunConnectionSecretName(v1alpha1.ArgoWorkflowNameVariable))
 					g.Expect(isInjected).To(BeTrue())
 				}
 			}, timeout, interval).Should(Succeed())
@@ -227,7 +227,7 @@ var _ = Describe("CronWorkflowReconciler", func() {
 				InjectableValues: api.InjectableValues{
 					api.InjectableValue{
 						Name:          "injectable-host",
-						ConnectionRef: v1.LocalObjectReference{Name: "default-connection"},
+						ConnectionRef: v1alpha1.ConnectionReference{Name: "default-connection"},
 						Content:       "{{.Host}}",
 						EnvName:       "HOST",
 					},
@@ -253,7 +253,7 @@ var _ = Describe("CronWorkflowReconciler", func() {
 					Name: api.ConnectionVolumeName(created.Name),
 					VolumeSource: v1.VolumeSource{
 						Secret: &v1.SecretVolumeSource{
-							SecretName: v1alpha1.ConnectionSecret(created.Name, created.Namespace).Name,
+							SecretName: v1alpha1.RunConnectionSecretName(v1alpha1.ArgoWorkflowNameVariable),
 						},
 					},
 				}
@@ -274,7 +274,7 @@ var _ = Describe("CronWorkflowReconciler", func() {
 				InjectableValues: api.InjectableValues{
 					api.InjectableValue{
 						Name:          "injectable-host",
-						ConnectionRef: v1.LocalObjectReference{Name: "default-connection"},
+						ConnectionRef: v1alpha1.ConnectionReference{Name: "default-connection"},
 						Content:       "{{.Host}}",
 						EnvName:       "HOST",
 					},
@@ -310,6 +310,55 @@ var _ = Describe("CronWorkflowReconciler", func() {
 			Expect(k8sClient.Delete(ctx, &res)).To(Succeed())
 		})
 	})
+
+	Context("CronWorkflow runs", func() {
+		It("Should create a connection secret owned by each run", func() {
+			ctx := context.Background()
+			wfName := generateWorkflowName()
+			key := types.NamespacedName{
+				Name:      wfName,
+				Namespace: "default",
+			}
+			created := api.CronWorkflow{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      key.Name,
+					Namespace: key.Namespace,
+				},
+				Spec: api.CronWorkflowSpec{
+					WorkflowSpec: api.WorkflowSpec{
+						ArgoWorkflowSpec: wfv1.WorkflowSpec{},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, &created)).To(Succeed())
+
+			// Fake the Argo CronWorkflow controller spawning a run
+			run := wfv1.Workflow{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      wfName + "-1234",
+					Namespace: key.Namespace,
+					Labels:    map[string]string{cronWorkflowLabel: wfName},
+				},
+			}
+			Expect(k8sClient.Create(ctx, &run)).To(Succeed())
+
+			var secret v1.Secret
+			secretKey := types.NamespacedName{Name: api.RunConnectionSecretName(run.Name), Namespace: key.Namespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, secretKey, &secret)).To(Succeed())
+				owner := metav1.GetControllerOf(&secret)
+				g.Expect(owner).ToNot(BeNil())
+				g.Expect(owner.UID).To(Equal(run.UID))
+			}, timeout, interval).Should(Succeed())
+
+			var res wfv1.CronWorkflow
+			Expect(k8sClient.Get(ctx, key, &res)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &run)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &secret)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &created)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &res)).To(Succeed())
+		})
+	})
 })
 
 func cronWorkflowAsWorkflow(cwf wfv1.CronWorkflow) wfv1.Workflow {
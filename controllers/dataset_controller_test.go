@@ -5,7 +5,6 @@ import (
 	"time"
 
 	"github.com/dataworkz/kubeetl/labels"
-	corev1 "k8s.io/api/core/v1"
 
 	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
 	. "github.com/onsi/ginkgo"
@@ -24,7 +23,7 @@ var _ = Describe("DataSetReconciler", func() {
 		It("Should set DataSet health to Unknown for a unknown Workflow", func() {
 			ctx := context.Background()
 			key := types.NamespacedName{
-				Name:      "default-dataset",
+				Name:      randomSuffix("default-dataset"),
 				Namespace: "default",
 			}
 
@@ -88,20 +87,11 @@ var _ = Describe("DataSetReconciler", func() {
 
 			Expect(k8sClient.Create(ctx, &wf)).Should(Succeed())
 
-			argoWfKey = types.NamespacedName{
-				Name:      "default-argo-workflow",
-				Namespace: "default",
-			}
-			argoWfSpec := wfv1.WorkflowSpec{}
-
-			argoWf = wfv1.Workflow{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      argoWfKey.Name,
-					Namespace: argoWfKey.Namespace,
-				},
-				Spec: argoWfSpec,
-			}
-			Expect(k8sClient.Create(ctx, &argoWf)).Should(Succeed())
+			// The Workflow reconciler creates the Argo Workflow
+			argoWfKey = wfKey
+			Eventually(func() error {
+				return k8sClient.Get(ctx, argoWfKey, &argoWf)
+			}, time.Second*5, time.Second*1).Should(Succeed())
 		})
 
 		AfterEach(func() {
@@ -116,7 +106,7 @@ var _ = Describe("DataSetReconciler", func() {
 		It("Should use an existing Workflow as DataSet healthcheck indicator", func() {
 			ctx := context.Background()
 			key := types.NamespacedName{
-				Name:      "default-dataset",
+				Name:      randomSuffix("default-dataset"),
 				Namespace: "default",
 			}
 
@@ -151,20 +141,14 @@ var _ = Describe("DataSetReconciler", func() {
 			}, timeout, interval).Should(BeTrue())
 
 			By("Updating the status if the workflow executed")
-			// First fake Workflow controller behaviour
-			argoWf.Status.Phase = wfv1.NodeFailed
-			wf := &api.Workflow{}
-			Expect(k8sClient.Update(ctx, &argoWf)).Should(Succeed())
-
-			Eventually(func() bool {
-				err := k8sClient.Get(ctx, wfKey, wf)
-				return err == nil
-			}).Should(BeTrue())
-			wf.Status.ArgoWorkflowRef = &corev1.ObjectReference{
-				Name:      argoWfKey.Name,
-				Namespace: argoWfKey.Namespace,
-			}
-			Expect(k8sClient.Status().Update(ctx, wf)).Should(Succeed())
+			// Fake Argo Workflow controller behaviour
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, argoWfKey, &argoWf); err != nil {
+					return err
+				}
+				argoWf.Status.Phase = wfv1.NodeFailed
+				return k8sClient.Update(ctx, &argoWf)
+			}, timeout, interval).Should(Succeed())
 
 			Eventually(func() bool {
 				res := &api.DataSet{}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/listers"
+)
+
+// expandFields returns the environment variables of the InjectableValues that expand the fields of their Connection,
+// keyed by the name of the InjectableValue. The variables are named by the EnvKeys of the ConnectionType fields
+// and refer to the Secrets and ConfigMaps the credentials are read from, so sensitive values never end up in the spec.
+// Issued and derived fields and values from external secret backends are read from the connection secret.
+func expandFields(ctx context.Context, cl client.Client, namespace, secretName string, wfs v1alpha1.WorkflowSpec) (map[string][]corev1.EnvVar, error) {
+	connectionLister := listers.NewConnectionLister(cl)
+	connectionTypeLister := listers.NewConnectionTypeLister(cl)
+
+	expanded := make(map[string][]corev1.EnvVar)
+	for _, iv := range wfs.InjectableValues {
+		if !iv.ExpandFields {
+			continue
+		}
+
+		conn, err := connectionLister.Find(ctx, namespace, iv.ConnectionRef.Name)
+		if errors.IsNotFound(err) {
+			return nil, fmt.Errorf("Connection %s not found", iv.ConnectionRef.Name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to find Connection %s: %w", iv.ConnectionRef.Name, err)
+		}
+
+		conType, err := connectionTypeLister.Find(ctx, namespace, conn.Spec.Type)
+		if errors.IsNotFound(err) {
+			return nil, fmt.Errorf("ConnectionType %s of Connection %s not found", conn.Spec.Type, conn.Name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to find ConnectionType %s: %w", conn.Spec.Type, err)
+		}
+
+		var vars []corev1.EnvVar
+		for _, field := range conn.ExpandedFields(conType.Spec.Fields) {
+			if field.Value != nil {
+				vars = append(vars, fieldEnvVar(field.EnvKey, *field.Value))
+				continue
+			}
+			vars = append(vars, corev1.EnvVar{
+				Name: field.EnvKey,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						Key:                  iv.FieldKey(field.Name),
+					},
+				},
+			})
+		}
+		expanded[iv.Name] = vars
+	}
+	return expanded, nil
+}
+
+// fieldEnvVar returns the environment variable that injects the credential value
+func fieldEnvVar(name string, value v1alpha1.Value) corev1.EnvVar {
+	ev := corev1.EnvVar{Name: name}
+	switch {
+	case value.ValueFrom == nil:
+		ev.Value = value.Value
+	case value.ValueFrom.SecretKeyRef != nil:
+		ev.ValueFrom = &corev1.EnvVarSource{SecretKeyRef: value.ValueFrom.SecretKeyRef.DeepCopy()}
+	case value.ValueFrom.ConfigMapKeyRef != nil:
+		ev.ValueFrom = &corev1.EnvVarSource{ConfigMapKeyRef: value.ValueFrom.ConfigMapKeyRef.DeepCopy()}
+	}
+	return ev
+}
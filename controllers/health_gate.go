@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+// unhealthyDependencies returns a description of every Connection and DataSet referenced by an
+// InjectableValue with RequireHealthy that is not Healthy. Resources without a health check are skipped.
+func unhealthyDependencies(ctx context.Context, cl client.Client, namespace string, wfs api.WorkflowSpec) ([]string, error) {
+	var unhealthy []string
+	seen := make(map[string]bool)
+	add := func(kind, name, state string) {
+		key := kind + "/" + name
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		if state == "" {
+			state = string(api.Unknown)
+		}
+		unhealthy = append(unhealthy, fmt.Sprintf("%s %s is %s", kind, name, state))
+	}
+
+	checkConnection := func(key types.NamespacedName) error {
+		name := key.Name
+		if key.Namespace != namespace {
+			name = key.String()
+		}
+
+		var conn api.Connection
+		if err := cl.Get(ctx, key, &conn); err != nil {
+			if errors.IsNotFound(err) {
+				add("Connection", name, "not found")
+				return nil
+			}
+			return fmt.Errorf("unable to fetch Connection %s: %w", name, err)
+		}
+
+		hc := conn.Spec.HealthCheck
+		if hc != nil && hc.GetMode() != api.HealthCheckDisabled && conn.Status.Healthy != api.Healthy {
+			add("Connection", name, string(conn.Status.Healthy))
+		}
+		return nil
+	}
+
+	for _, iv := range wfs.InjectableValues {
+		if !iv.RequireHealthy {
+			continue
+		}
+
+		if iv.ConnectionRef.Name != "" {
+			if err := checkConnection(iv.ConnectionRef.GetNamespacedName(namespace)); err != nil {
+				return nil, err
+			}
+		}
+
+		if iv.DataSetRef.Name != "" {
+			var ds api.DataSet
+			if err := cl.Get(ctx, types.NamespacedName{Name: iv.DataSetRef.Name, Namespace: namespace}, &ds); err != nil {
+				if errors.IsNotFound(err) {
+					add("DataSet", iv.DataSetRef.Name, "not found")
+					continue
+				}
+				return nil, fmt.Errorf("unable to fetch DataSet %s: %w", iv.DataSetRef.Name, err)
+			}
+
+			if ds.Spec.HealthCheck != nil && ds.Status.Healthy != api.Healthy {
+				add("DataSet", ds.Name, string(ds.Status.Healthy))
+			}
+
+			if ds.Spec.Connection.ConnectionFrom != nil {
+				key := types.NamespacedName{Name: ds.Spec.Connection.ConnectionFrom.Name, Namespace: namespace}
+				if err := checkConnection(key); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return unhealthy, nil
+}
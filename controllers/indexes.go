@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+const (
+	// connectionValueSourceIndex indexes Connections by the Secrets and ConfigMaps referenced by their credentials
+	connectionValueSourceIndex = ".spec.credentials.valueFrom"
+	// dataSetValueSourceIndex indexes DataSets by the Secrets and ConfigMaps referenced by their metadata
+	dataSetValueSourceIndex = ".spec.metadata.valueFrom"
+	// connectionTypeIndex indexes Connections by their ConnectionType
+	connectionTypeIndex = ".spec.type"
+	// dataSetTypeIndex indexes DataSets by their DataSetType
+	dataSetTypeIndex = ".spec.type"
+)
+
+// valueSourceKey returns the index key of a Secret or ConfigMap referenced by a ValueSource
+func valueSourceKey(kind, name string) string {
+	return fmt.Sprintf("%s/%s", kind, name)
+}
+
+// credentialsValueSourceKeys returns the index keys of all Secrets and ConfigMaps referenced by the Credentials
+func credentialsValueSourceKeys(c v1alpha1.Credentials) []string {
+	var keys []string
+	for _, name := range c.SecretNames() {
+		keys = append(keys, valueSourceKey("Secret", name))
+	}
+	for _, name := range c.ConfigMapNames() {
+		keys = append(keys, valueSourceKey("ConfigMap", name))
+	}
+	return keys
+}
+
+// SetupIndexes registers the field indexes used by the KubeETL reconcilers, next to the indexes of listers.SetupIndexes.
+// The indexes must be registered once, before the manager is started.
+func SetupIndexes(mgr ctrl.Manager) error {
+	ctx := context.Background()
+	indexer := mgr.GetFieldIndexer()
+
+	if err := indexer.IndexField(ctx, &v1alpha1.Connection{}, connectionValueSourceIndex, func(obj client.Object) []string {
+		return credentialsValueSourceKeys(obj.(*v1alpha1.Connection).Spec.Credentials)
+	}); err != nil {
+		return fmt.Errorf("unable to index Connections: %w", err)
+	}
+
+	if err := indexer.IndexField(ctx, &v1alpha1.DataSet{}, dataSetValueSourceIndex, func(obj client.Object) []string {
+		return credentialsValueSourceKeys(obj.(*v1alpha1.DataSet).Spec.Metadata)
+	}); err != nil {
+		return fmt.Errorf("unable to index DataSets: %w", err)
+	}
+
+	if err := indexer.IndexField(ctx, &v1alpha1.Connection{}, connectionTypeIndex, func(obj client.Object) []string {
+		return nonEmpty(obj.(*v1alpha1.Connection).Spec.Type)
+	}); err != nil {
+		return fmt.Errorf("unable to index Connections: %w", err)
+	}
+
+	if err := indexer.IndexField(ctx, &v1alpha1.DataSet{}, dataSetTypeIndex, func(obj client.Object) []string {
+		return nonEmpty(obj.(*v1alpha1.DataSet).Spec.Type)
+	}); err != nil {
+		return fmt.Errorf("unable to index DataSets: %w", err)
+	}
+
+	return nil
+}
+
+// objectValueSourceKey returns the index key of a Secret or ConfigMap
+func objectValueSourceKey(obj client.Object) (string, bool) {
+	switch obj.(type) {
+	case *corev1.Secret:
+		return valueSourceKey("Secret", obj.GetName()), true
+	case *corev1.ConfigMap:
+		return valueSourceKey("ConfigMap", obj.GetName()), true
+	}
+	return "", false
+}
+
+func nonEmpty(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return []string{value}
+}
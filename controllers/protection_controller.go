@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	api "github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+// protectedKind creates the objects and lists of a kind protected by the InUseProtectionReconciler
+type protectedKind struct {
+	newObject func() client.Object
+	newList   func() client.ObjectList
+}
+
+// protectedKinds contains the kinds protected by the InUseProtectionReconciler
+var protectedKinds = map[string]protectedKind{
+	"Connection": {
+		newObject: func() client.Object { return &api.Connection{} },
+		newList:   func() client.ObjectList { return &api.ConnectionList{} },
+	},
+	"DataSet": {
+		newObject: func() client.Object { return &api.DataSet{} },
+		newList:   func() client.ObjectList { return &api.DataSetList{} },
+	},
+	"ConnectionType": {
+		newObject: func() client.Object { return &api.ConnectionType{} },
+		newList:   func() client.ObjectList { return &api.ConnectionTypeList{} },
+	},
+	"DataSetType": {
+		newObject: func() client.Object { return &api.DataSetType{} },
+		newList:   func() client.ObjectList { return &api.DataSetTypeList{} },
+	},
+	"ClusterConnectionType": {
+		newObject: func() client.Object { return &api.ClusterConnectionType{} },
+		newList:   func() client.ObjectList { return &api.ClusterConnectionTypeList{} },
+	},
+	"ClusterDataSetType": {
+		newObject: func() client.Object { return &api.ClusterDataSetType{} },
+		newList:   func() client.ObjectList { return &api.ClusterDataSetTypeList{} },
+	},
+}
+
+// statusConditions returns the conditions in the status of a protected resource
+func statusConditions(obj client.Object) *[]metav1.Condition {
+	switch o := obj.(type) {
+	case *api.Connection:
+		return &o.Status.Conditions
+	case *api.DataSet:
+		return &o.Status.Conditions
+	case *api.ConnectionType:
+		return &o.Status.Conditions
+	case *api.DataSetType:
+		return &o.Status.Conditions
+	case *api.ClusterConnectionType:
+		return &o.Status.Conditions
+	case *api.ClusterDataSetType:
+		return &o.Status.Conditions
+	}
+	return nil
+}
+
+// InUseProtectionReconciler adds the InUseProtectionFinalizer to resources of the given Kind
+// and only removes it once no other resource refers to them.
+type InUseProtectionReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Kind is the protected kind: Connection, DataSet, ConnectionType, DataSetType,
+	// ClusterConnectionType or ClusterDataSetType
+	Kind string
+}
+
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=connections;datasets;connectiontypes;datasettypes;clusterconnectiontypes;clusterdatasettypes,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=connections/status;datasets/status;connectiontypes/status;datasettypes/status;clusterconnectiontypes/status;clusterdatasettypes/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=workflows;cronworkflows;workflowtemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *InUseProtectionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues(strings.ToLower(r.Kind), req.NamespacedName)
+
+	obj := protectedKinds[r.Kind].newObject()
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		if !errors.IsNotFound(err) {
+			log.Error(err, "unable to fetch "+r.Kind)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if obj.GetDeletionTimestamp().IsZero() {
+		if controllerutil.ContainsFinalizer(obj, api.InUseProtectionFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+		controllerutil.AddFinalizer(obj, api.InUseProtectionFinalizer)
+		if err := r.Patch(ctx, obj, patch); err != nil {
+			return ctrl.Result{}, fmt.Errorf("unable to add finalizer to %s: %w", r.Kind, err)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(obj, api.InUseProtectionFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	dependents, err := dependentsOf(ctx, r.Client, r.Kind, obj.GetNamespace(), obj.GetName())
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(dependents) > 0 {
+		// the deletion is re-evaluated when a dependent changes or is deleted
+		msg := fmt.Sprintf("%s %s is in use by %s", r.Kind, obj.GetName(), strings.Join(dependents, ", "))
+		log.Info("deletion blocked", "dependents", dependents)
+		return ctrl.Result{}, r.setDeletionBlocked(ctx, obj, msg)
+	}
+
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	controllerutil.RemoveFinalizer(obj, api.InUseProtectionFinalizer)
+	if err := r.Patch(ctx, obj, patch); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to remove finalizer from %s: %w", r.Kind, err)
+	}
+	return ctrl.Result{}, nil
+}
+
+// setDeletionBlocked sets the DeletionBlocked condition listing the dependents of the resource,
+// and records an event when they change
+func (r *InUseProtectionReconciler) setDeletionBlocked(ctx context.Context, obj client.Object, msg string) error {
+	conditions := statusConditions(obj)
+	if existing := meta.FindStatusCondition(*conditions, api.ConditionDeletionBlocked); existing != nil &&
+		existing.Status == metav1.ConditionTrue && existing.Message == msg {
+		return nil
+	}
+
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               api.ConditionDeletionBlocked,
+		Status:             metav1.ConditionTrue,
+		Reason:             "InUse",
+		Message:            msg,
+		ObservedGeneration: obj.GetGeneration(),
+	})
+	if err := r.Status().Update(ctx, obj); err != nil {
+		return fmt.Errorf("unable to update %s status: %w", r.Kind, err)
+	}
+	r.Recorder.Event(obj, corev1.EventTypeWarning, "DeletionBlocked", msg)
+	return nil
+}
+
+// deletingRequests returns a request for every resource of the protected kind whose deletion is blocked,
+// as a change of a dependent may unblock it
+func (r *InUseProtectionReconciler) deletingRequests(client.Object) []reconcile.Request {
+	list := protectedKinds[r.Kind].newList()
+	if err := r.List(context.Background(), list); err != nil {
+		r.Log.Error(err, "unable to list "+r.Kind)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	_ = meta.EachListItem(list, func(o runtime.Object) error {
+		obj := o.(client.Object)
+		if !obj.GetDeletionTimestamp().IsZero() && controllerutil.ContainsFinalizer(obj, api.InUseProtectionFinalizer) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}})
+		}
+		return nil
+	})
+	return requests
+}
+
+func (r *InUseProtectionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	kind, ok := protectedKinds[r.Kind]
+	if !ok {
+		return fmt.Errorf("unsupported kind %s", r.Kind)
+	}
+
+	r.Client = mgr.GetClient()
+	r.Scheme = mgr.GetScheme()
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor("in-use-protection")
+	}
+
+	bldr := ctrl.NewControllerManagedBy(mgr).
+		Named(strings.ToLower(r.Kind) + "-protection").
+		For(kind.newObject())
+	for _, dependent := range dependentObjects(r.Kind) {
+		bldr = bldr.Watches(&source.Kind{Type: dependent}, handler.EnqueueRequestsFromMapFunc(r.deletingRequests))
+	}
+	return bldr.Complete(r)
+}
@@ -0,0 +1,290 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	api "github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+var _ = Describe("InUseProtectionReconciler", func() {
+	const timeout = time.Second * 5
+	const interval = time.Second * 1
+
+	isDeleted := func(ctx context.Context, key types.NamespacedName, obj client.Object) func() bool {
+		return func() bool {
+			return errors.IsNotFound(k8sClient.Get(ctx, key, obj))
+		}
+	}
+
+	It("Should block deletion of a Connection used by a DataSet", func() {
+		ctx := context.Background()
+
+		connType := api.ConnectionType{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      randomSuffix("protected-type"),
+				Namespace: "default",
+			},
+		}
+		Expect(k8sClient.Create(ctx, &connType)).To(Succeed())
+
+		conn := api.Connection{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      randomSuffix("protected-connection"),
+				Namespace: "default",
+			},
+			Spec: api.ConnectionSpec{
+				Type:        connType.Name,
+				Credentials: api.Credentials{},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &conn)).To(Succeed())
+		connKey := types.NamespacedName{Name: conn.Name, Namespace: conn.Namespace}
+
+		ds := api.DataSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      randomSuffix("protected-dataset"),
+				Namespace: "default",
+			},
+			Spec: api.DataSetSpec{
+				Type:        "MySQL DataSet",
+				StorageType: api.PersistentType,
+				Connection: api.ConnectionFrom{
+					ConnectionFrom: &api.ConnectionRef{
+						LocalObjectReference: corev1.LocalObjectReference{Name: conn.Name},
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &ds)).To(Succeed())
+
+		By("Adding the finalizer")
+		Eventually(func(g Gomega) {
+			var res api.Connection
+			g.Expect(k8sClient.Get(ctx, connKey, &res)).To(Succeed())
+			g.Expect(res.Finalizers).To(ContainElement(api.InUseProtectionFinalizer))
+		}, timeout, interval).Should(Succeed())
+
+		By("Blocking the deletion while the DataSet refers to the Connection")
+		Expect(k8sClient.Delete(ctx, &conn)).To(Succeed())
+		Consistently(func(g Gomega) {
+			var res api.Connection
+			g.Expect(k8sClient.Get(ctx, connKey, &res)).To(Succeed())
+			g.Expect(res.DeletionTimestamp).ToNot(BeNil())
+		}, timeout, interval).Should(Succeed())
+
+		By("Listing the DataSet in the DeletionBlocked condition")
+		var blocked api.Connection
+		Expect(k8sClient.Get(ctx, connKey, &blocked)).To(Succeed())
+		cond := meta.FindStatusCondition(blocked.Status.Conditions, api.ConditionDeletionBlocked)
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Message).To(Equal(fmt.Sprintf("Connection %s is in use by DataSet %s", conn.Name, ds.Name)))
+
+		By("Blocking the deletion of the ConnectionType while the Connection exists")
+		Expect(k8sClient.Delete(ctx, &connType)).To(Succeed())
+
+		By("Deleting the Connection once the DataSet is gone")
+		Expect(k8sClient.Delete(ctx, &ds)).To(Succeed())
+		Eventually(isDeleted(ctx, connKey, &api.Connection{}), timeout, interval).Should(BeTrue())
+		Eventually(isDeleted(ctx, types.NamespacedName{Name: connType.Name, Namespace: connType.Namespace}, &api.ConnectionType{}),
+			timeout, interval).Should(BeTrue())
+	})
+
+	It("Should block deletion of a Connection used by a Workflow in another namespace", func() {
+		ctx := context.Background()
+
+		consumer := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: randomSuffix("protection-consumer")}}
+		Expect(k8sClient.Create(ctx, &consumer)).To(Succeed())
+
+		conn := api.Connection{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      randomSuffix("granted-connection"),
+				Namespace: "default",
+			},
+			Spec: api.ConnectionSpec{
+				Credentials: api.Credentials{},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &conn)).To(Succeed())
+		connKey := types.NamespacedName{Name: conn.Name, Namespace: conn.Namespace}
+
+		wf := api.Workflow{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      generateWorkflowName(),
+				Namespace: consumer.Name,
+			},
+			Spec: api.WorkflowSpec{
+				InjectableValues: api.InjectableValues{
+					api.InjectableValue{
+						Name:          "connection",
+						ConnectionRef: api.ConnectionReference{Name: conn.Name, Namespace: conn.Namespace},
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &wf)).To(Succeed())
+
+		By("Adding the finalizer")
+		Eventually(func(g Gomega) {
+			var res api.Connection
+			g.Expect(k8sClient.Get(ctx, connKey, &res)).To(Succeed())
+			g.Expect(res.Finalizers).To(ContainElement(api.InUseProtectionFinalizer))
+		}, timeout, interval).Should(Succeed())
+
+		By("Blocking the deletion while the Workflow refers to the Connection")
+		Expect(k8sClient.Delete(ctx, &conn)).To(Succeed())
+		Consistently(func(g Gomega) {
+			var res api.Connection
+			g.Expect(k8sClient.Get(ctx, connKey, &res)).To(Succeed())
+			g.Expect(res.DeletionTimestamp).ToNot(BeNil())
+		}, timeout, interval).Should(Succeed())
+
+		By("Deleting the Connection once the Workflow is gone")
+		Expect(k8sClient.Delete(ctx, &wf)).To(Succeed())
+		Eventually(isDeleted(ctx, connKey, &api.Connection{}), timeout, interval).Should(BeTrue())
+	})
+
+	It("Should block deletion of a ClusterConnectionType used by a Connection in any namespace", func() {
+		ctx := context.Background()
+
+		consumer := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: randomSuffix("cluster-type-consumer")}}
+		Expect(k8sClient.Create(ctx, &consumer)).To(Succeed())
+
+		clusterType := api.ClusterConnectionType{
+			ObjectMeta: metav1.ObjectMeta{Name: randomSuffix("protected-cluster-type")},
+		}
+		Expect(k8sClient.Create(ctx, &clusterType)).To(Succeed())
+		typeKey := types.NamespacedName{Name: clusterType.Name}
+
+		conn := api.Connection{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      randomSuffix("cluster-typed-connection"),
+				Namespace: consumer.Name,
+			},
+			Spec: api.ConnectionSpec{
+				Type:        clusterType.Name,
+				Credentials: api.Credentials{},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &conn)).To(Succeed())
+
+		By("Adding the finalizer")
+		Eventually(func(g Gomega) {
+			var res api.ClusterConnectionType
+			g.Expect(k8sClient.Get(ctx, typeKey, &res)).To(Succeed())
+			g.Expect(res.Finalizers).To(ContainElement(api.InUseProtectionFinalizer))
+		}, timeout, interval).Should(Succeed())
+
+		By("Blocking the deletion while the Connection uses the ClusterConnectionType")
+		Expect(k8sClient.Delete(ctx, &clusterType)).To(Succeed())
+		Consistently(func(g Gomega) {
+			var res api.ClusterConnectionType
+			g.Expect(k8sClient.Get(ctx, typeKey, &res)).To(Succeed())
+			g.Expect(res.DeletionTimestamp).ToNot(BeNil())
+		}, timeout, interval).Should(Succeed())
+
+		By("Deleting the ClusterConnectionType once the Connection is gone")
+		Expect(k8sClient.Delete(ctx, &conn)).To(Succeed())
+		Eventually(isDeleted(ctx, typeKey, &api.ClusterConnectionType{}), timeout, interval).Should(BeTrue())
+	})
+
+	It("Should not consider resources that use a namespaced type of the same name dependents of a cluster-scoped type", func() {
+		ctx := context.Background()
+		s := runtime.NewScheme()
+		Expect(api.AddToScheme(s)).To(Succeed())
+
+		shadowing := &api.ConnectionType{ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "team-a"}}
+		shadowed := &api.Connection{
+			ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "team-a"},
+			Spec:       api.ConnectionSpec{Type: "mysql"},
+		}
+		dependent := &api.Connection{
+			ObjectMeta: metav1.ObjectMeta{Name: "invoices", Namespace: "team-b"},
+			Spec:       api.ConnectionSpec{Type: "mysql"},
+		}
+		cl := fake.NewClientBuilder().WithScheme(s).WithObjects(shadowing, shadowed, dependent).Build()
+
+		dependents, err := dependentsOf(ctx, cl, "ClusterConnectionType", "", "mysql")
+		Expect(err).To(Succeed())
+		Expect(dependents).To(Equal([]string{"Connection team-b/invoices"}))
+	})
+
+	It("Should report the dependents that block the deletion and re-evaluate it when they change", func() {
+		ctx := context.Background()
+		s := runtime.NewScheme()
+		Expect(api.AddToScheme(s)).To(Succeed())
+
+		now := metav1.Now()
+		conn := &api.Connection{ObjectMeta: metav1.ObjectMeta{
+			Name:              "orders",
+			Namespace:         "default",
+			Finalizers:        []string{api.InUseProtectionFinalizer},
+			DeletionTimestamp: &now,
+		}}
+		unused := &api.Connection{ObjectMeta: metav1.ObjectMeta{
+			Name:       "invoices",
+			Namespace:  "default",
+			Finalizers: []string{api.InUseProtectionFinalizer},
+		}}
+		ds := &api.DataSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "orders-table", Namespace: "default"},
+			Spec: api.DataSetSpec{Connection: api.ConnectionFrom{
+				ConnectionFrom: &api.ConnectionRef{LocalObjectReference: corev1.LocalObjectReference{Name: conn.Name}},
+			}},
+		}
+		recorder := record.NewFakeRecorder(10)
+		r := &InUseProtectionReconciler{
+			Client:   fake.NewClientBuilder().WithScheme(s).WithObjects(conn, unused, ds).Build(),
+			Log:      ctrl.Log.WithName("controllers").WithName("ConnectionProtection"),
+			Recorder: recorder,
+			Kind:     "Connection",
+		}
+		key := types.NamespacedName{Name: conn.Name, Namespace: conn.Namespace}
+
+		result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(Equal(ctrl.Result{}))
+
+		var res api.Connection
+		Expect(r.Get(ctx, key, &res)).To(Succeed())
+		cond := meta.FindStatusCondition(res.Status.Conditions, api.ConditionDeletionBlocked)
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Message).To(Equal("Connection orders is in use by DataSet orders-table"))
+		Expect(recorder.Events).To(HaveLen(1))
+
+		By("Not recording the same dependents twice")
+		_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(recorder.Events).To(HaveLen(1))
+
+		By("Enqueueing the blocked Connection when a dependent changes")
+		Expect(r.deletingRequests(ds)).To(Equal([]reconcile.Request{{NamespacedName: key}}))
+		Expect(dependentObjects("Connection")).To(ConsistOf(&api.DataSet{}, &api.Workflow{}, &api.CronWorkflow{}, &api.WorkflowTemplate{}))
+		Expect(dependentObjects("ClusterConnectionType")).To(ConsistOf(&api.Connection{}, &api.ConnectionType{}))
+
+		By("Removing the finalizer once the dependent is gone")
+		Expect(r.Delete(ctx, ds)).To(Succeed())
+		_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).ToNot(HaveOccurred())
+		var deleted api.Connection
+		Expect(r.Get(ctx, key, &deleted)).To(Succeed())
+		Expect(deleted.Finalizers).ToNot(ContainElement(api.InUseProtectionFinalizer))
+	})
+})
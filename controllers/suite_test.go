@@ -28,6 +28,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	apiv1alpha1 "github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/listers"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -73,6 +74,19 @@ var _ = BeforeSuite(func(done Done) {
 	})
 	Expect(err).ToNot(HaveOccurred())
 
+	err = SetupIndexes(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = listers.SetupIndexes(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&ConnectionReconciler{
+		Client: k8sManager.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("Connection"),
+		Scheme: k8sManager.GetScheme(),
+	}).SetupWithManager(k8sManager)
+	Expect(err).NotTo(HaveOccurred())
+
 	err = (&DataSetReconciler{
 		Client: k8sManager.GetClient(),
 		Log:    ctrl.Log.WithName("controllers").WithName("DataSet"),
@@ -81,29 +95,48 @@ var _ = BeforeSuite(func(done Done) {
 	Expect(err).NotTo(HaveOccurred())
 
 	err = (&WorkflowReconciler{
-		Client:                   k8sManager.GetClient(),
-		Log:                      ctrl.Log.WithName("controllers").WithName("Workflow"),
-		Scheme:                   k8sManager.GetScheme(),
-		ConnectionInjectionImage: "kubeetl/connection-injector",
+		Client:            k8sManager.GetClient(),
+		Log:               ctrl.Log.WithName("controllers").WithName("Workflow"),
+		Scheme:            k8sManager.GetScheme(),
+		InjectionTemplate: apiv1alpha1.InjectionTemplate{Image: "kubeetl/connection-injector"},
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
 	err = (&CronWorkflowReconciler{
-		Client:                   k8sManager.GetClient(),
-		Log:                      ctrl.Log.WithName("controllers").WithName("CronWorkflow"),
-		Scheme:                   k8sManager.GetScheme(),
-		ConnectionInjectionImage: "kubeetl/connection-injector",
+		Client:            k8sManager.GetClient(),
+		Log:               ctrl.Log.WithName("controllers").WithName("CronWorkflow"),
+		Scheme:            k8sManager.GetScheme(),
+		InjectionTemplate: apiv1alpha1.InjectionTemplate{Image: "kubeetl/connection-injector"},
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
 	err = (&WorkflowTemplateReconciler{
-		Client:                   k8sManager.GetClient(),
-		Log:                      ctrl.Log.WithName("controllers").WithName("WorkflowTemplate"),
-		Scheme:                   k8sManager.GetScheme(),
-		ConnectionInjectionImage: "kubeetl/connection-injector",
+		Client:            k8sManager.GetClient(),
+		Log:               ctrl.Log.WithName("controllers").WithName("WorkflowTemplate"),
+		Scheme:            k8sManager.GetScheme(),
+		InjectionTemplate: apiv1alpha1.InjectionTemplate{Image: "kubeetl/connection-injector"},
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
+	for _, kind := range []string{"Connection", "DataSet", "ConnectionType", "DataSetType", "ClusterConnectionType", "ClusterDataSetType"} {
+		err = (&InUseProtectionReconciler{
+			Client: k8sManager.GetClient(),
+			Log:    ctrl.Log.WithName("controllers").WithName(kind + "Protection"),
+			Scheme: k8sManager.GetScheme(),
+			Kind:   kind,
+		}).SetupWithManager(k8sManager)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	for _, kind := range []string{"Connection", "DataSet"} {
+		err = (&UsageReconciler{
+			Client: k8sManager.GetClient(),
+			Log:    ctrl.Log.WithName("controllers").WithName(kind + "Usage"),
+			Kind:   kind,
+		}).SetupWithManager(k8sManager)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
 	go func() {
 		err = k8sManager.Start(ctrl.SetupSignalHandler())
 		Expect(err).ToNot(HaveOccurred())
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/listers"
+)
+
+// usageQuery lists the resources of one kind that refer to a resource using a field index
+type usageQuery struct {
+	kind  string
+	index string
+	// crossNamespace queries the index in all namespaces by the namespace/name of the resource,
+	// instead of in the namespace of the resource by its name
+	crossNamespace bool
+	// shadowedBy returns the namespaced resource that takes precedence over a cluster-scoped resource with the same name.
+	// Resources in a namespace that contains it do not depend on the cluster-scoped resource.
+	shadowedBy func() client.Object
+	newList    func() client.ObjectList
+}
+
+// listOptions returns the options that list the resources referring to the resource with the given namespace and name
+func (q usageQuery) listOptions(namespace, name string) []client.ListOption {
+	if q.crossNamespace {
+		return []client.ListOption{client.MatchingFields{q.index: types.NamespacedName{Namespace: namespace, Name: name}.String()}}
+	}
+	if namespace == "" {
+		// cluster-scoped resources are used in all namespaces
+		return []client.ListOption{client.MatchingFields{q.index: name}}
+	}
+	return []client.ListOption{client.InNamespace(namespace), client.MatchingFields{q.index: name}}
+}
+
+// shadowed returns true if the namespace of the dependent contains the namespaced resource that takes precedence
+// over the cluster-scoped resource with the given name
+func (q usageQuery) shadowed(ctx context.Context, cl client.Client, dependent client.Object, name string) (bool, error) {
+	if q.shadowedBy == nil {
+		return false, nil
+	}
+	err := cl.Get(ctx, types.NamespacedName{Namespace: dependent.GetNamespace(), Name: name}, q.shadowedBy())
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+var (
+	workflowUsageQueries = func(index string, crossNamespace bool) []usageQuery {
+		return []usageQuery{
+			{kind: "Workflow", index: index, crossNamespace: crossNamespace, newList: func() client.ObjectList { return &v1alpha1.WorkflowList{} }},
+			{kind: "CronWorkflow", index: index, crossNamespace: crossNamespace, newList: func() client.ObjectList { return &v1alpha1.CronWorkflowList{} }},
+			{kind: "WorkflowTemplate", index: index, crossNamespace: crossNamespace, newList: func() client.ObjectList { return &v1alpha1.WorkflowTemplateList{} }},
+		}
+	}
+
+	// usageQueries contains the queries that find the dependents of each protected kind.
+	// Connections are also used by the resources in other namespaces a ConnectionGrant shares them with.
+	// ClusterConnectionTypes and ClusterDataSetTypes are used in every namespace without a namespaced type of the same name.
+	usageQueries = map[string][]usageQuery{
+		"Connection": append(append([]usageQuery{
+			{kind: "DataSet", index: listers.DataSetConnectionIndex, newList: func() client.ObjectList { return &v1alpha1.DataSetList{} }},
+		}, workflowUsageQueries(listers.WorkflowConnectionIndex, false)...), workflowUsageQueries(listers.WorkflowGrantedConnectionIndex, true)...),
+		"DataSet": workflowUsageQueries(listers.WorkflowDataSetIndex, false),
+		"ConnectionType": {
+			{kind: "Connection", index: connectionTypeIndex, newList: func() client.ObjectList { return &v1alpha1.ConnectionList{} }},
+		},
+		"DataSetType": {
+			{kind: "DataSet", index: dataSetTypeIndex, newList: func() client.ObjectList { return &v1alpha1.DataSetList{} }},
+		},
+		"ClusterConnectionType": {
+			{
+				kind:       "Connection",
+				index:      connectionTypeIndex,
+				shadowedBy: func() client.Object { return &v1alpha1.ConnectionType{} },
+				newList:    func() client.ObjectList { return &v1alpha1.ConnectionList{} },
+			},
+		},
+		"ClusterDataSetType": {
+			{
+				kind:       "DataSet",
+				index:      dataSetTypeIndex,
+				shadowedBy: func() client.Object { return &v1alpha1.DataSetType{} },
+				newList:    func() client.ObjectList { return &v1alpha1.DataSetList{} },
+			},
+		},
+	}
+)
+
+// dependentKinds creates a resource of each kind usageQueries list. Their changes are watched,
+// as they may unblock the deletion of the resources they referred to.
+var dependentKinds = map[string]func() client.Object{
+	"Connection":       func() client.Object { return &v1alpha1.Connection{} },
+	"DataSet":          func() client.Object { return &v1alpha1.DataSet{} },
+	"Workflow":         func() client.Object { return &v1alpha1.Workflow{} },
+	"CronWorkflow":     func() client.Object { return &v1alpha1.CronWorkflow{} },
+	"WorkflowTemplate": func() client.Object { return &v1alpha1.WorkflowTemplate{} },
+}
+
+// dependentObjects returns a resource of every kind that may depend on a resource of the given kind,
+// including the namespaced types that take precedence over a cluster-scoped type
+func dependentObjects(kind string) []client.Object {
+	seen := sets.NewString()
+	var objects []client.Object
+	for _, q := range usageQueries[kind] {
+		if !seen.Has(q.kind) {
+			seen.Insert(q.kind)
+			objects = append(objects, dependentKinds[q.kind]())
+		}
+		if q.shadowedBy != nil {
+			objects = append(objects, q.shadowedBy())
+		}
+	}
+	return objects
+}
+
+// dependentsOf returns a sorted description ("Kind name", or "Kind namespace/name" for resources in other namespaces)
+// of every resource that refers to the resource of the given kind. Resources that are being deleted themselves
+// are not considered dependents.
+func dependentsOf(ctx context.Context, cl client.Client, kind, namespace, name string) ([]string, error) {
+	queries, ok := usageQueries[kind]
+	if !ok {
+		return nil, fmt.Errorf("unsupported kind %s", kind)
+	}
+
+	var dependents []string
+	for _, q := range queries {
+		list := q.newList()
+		if err := cl.List(ctx, list, q.listOptions(namespace, name)...); err != nil {
+			return nil, fmt.Errorf("unable to list %s dependents: %w", q.kind, err)
+		}
+		err := meta.EachListItem(list, func(o runtime.Object) error {
+			obj := o.(client.Object)
+			if !obj.GetDeletionTimestamp().IsZero() {
+				return nil
+			}
+			if shadowed, err := q.shadowed(ctx, cl, obj, name); err != nil || shadowed {
+				return err
+			}
+			if obj.GetNamespace() == namespace {
+				dependents = append(dependents, fmt.Sprintf("%s %s", q.kind, obj.GetName()))
+			} else {
+				dependents = append(dependents, fmt.Sprintf("%s %s/%s", q.kind, obj.GetNamespace(), obj.GetName()))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(dependents)
+	return dependents, nil
+}
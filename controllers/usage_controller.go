@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	api "github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/listers"
+)
+
+// UsageReconciler records which Workflows, CronWorkflows, WorkflowTemplates and DataSets use
+// the Connections or DataSets of the given Kind in their status.
+type UsageReconciler struct {
+	client.Client
+	Log logr.Logger
+	// Kind is the kind of which the usage is recorded: Connection or DataSet
+	Kind string
+}
+
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=connections;datasets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=connections/status;datasets/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=workflows;cronworkflows;workflowtemplates,verbs=get;list;watch
+
+func (r *UsageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues(strings.ToLower(r.Kind), req.NamespacedName)
+
+	var obj client.Object
+	var status **api.Usage
+	switch r.Kind {
+	case "Connection":
+		conn := &api.Connection{}
+		obj, status = conn, &conn.Status.Usage
+	case "DataSet":
+		ds := &api.DataSet{}
+		obj, status = ds, &ds.Status.Usage
+	default:
+		return ctrl.Result{}, fmt.Errorf("unsupported kind %s", r.Kind)
+	}
+
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		if !errors.IsNotFound(err) {
+			log.Error(err, "unable to fetch "+r.Kind)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	usage, err := r.usageOf(ctx, req.NamespacedName)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if equality.Semantic.DeepEqual(*status, usage) {
+		return ctrl.Result{}, nil
+	}
+
+	*status = usage
+	if err := r.Status().Update(ctx, obj); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to update usage of %s: %w", r.Kind, err)
+	}
+	return ctrl.Result{}, nil
+}
+
+// usageOf looks up the resources that use the Connection or DataSet with the given key.
+// It returns nil if there are none.
+func (r *UsageReconciler) usageOf(ctx context.Context, key types.NamespacedName) (*api.Usage, error) {
+	workflowLister := listers.NewWorkflowLister(r.Client)
+	cronWorkflowLister := listers.NewCronWorkflowLister(r.Client)
+	workflowTemplateLister := listers.NewWorkflowTemplateLister(r.Client)
+
+	var wfs, cwfs, wfts, dss client.ObjectList
+	var err error
+	if r.Kind == "Connection" {
+		if wfs, err = workflowLister.ListByConnection(ctx, key); err != nil {
+			return nil, err
+		}
+		if cwfs, err = cronWorkflowLister.ListByConnection(ctx, key); err != nil {
+			return nil, err
+		}
+		if wfts, err = workflowTemplateLister.ListByConnection(ctx, key); err != nil {
+			return nil, err
+		}
+		if dss, err = listers.NewDataSetLister(r.Client).ListByConnection(ctx, key); err != nil {
+			return nil, err
+		}
+	} else {
+		if wfs, err = workflowLister.ListByDataSet(ctx, key.Namespace, key.Name); err != nil {
+			return nil, err
+		}
+		if cwfs, err = cronWorkflowLister.ListByDataSet(ctx, key.Namespace, key.Name); err != nil {
+			return nil, err
+		}
+		if wfts, err = workflowTemplateLister.ListByDataSet(ctx, key.Namespace, key.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	usage := api.Usage{
+		Workflows:         usageNames(key.Namespace, wfs),
+		CronWorkflows:     usageNames(key.Namespace, cwfs),
+		WorkflowTemplates: usageNames(key.Namespace, wfts),
+		DataSets:          usageNames(key.Namespace, dss),
+	}
+	if len(usage.Workflows)+len(usage.CronWorkflows)+len(usage.WorkflowTemplates)+len(usage.DataSets) == 0 {
+		return nil, nil
+	}
+	return &usage, nil
+}
+
+// usageNames returns the sorted names of the objects in the list, prefixed by their namespace if it is not the given namespace
+func usageNames(namespace string, list client.ObjectList) []string {
+	if list == nil {
+		return nil
+	}
+
+	var names []string
+	_ = meta.EachListItem(list, func(o runtime.Object) error {
+		obj := o.(client.Object)
+		if obj.GetNamespace() == namespace {
+			names = append(names, obj.GetName())
+		} else {
+			names = append(names, types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}.String())
+		}
+		return nil
+	})
+	sort.Strings(names)
+	return names
+}
+
+func (r *UsageReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	var obj client.Object
+	var mapFn handler.MapFunc
+	switch r.Kind {
+	case "Connection":
+		obj, mapFn = &api.Connection{}, injectedConnectionRequests
+	case "DataSet":
+		obj, mapFn = &api.DataSet{}, injectedDataSetRequests
+	default:
+		return fmt.Errorf("unsupported kind %s", r.Kind)
+	}
+
+	r.Client = mgr.GetClient()
+
+	bldr := ctrl.NewControllerManagedBy(mgr).
+		Named(strings.ToLower(r.Kind)+"-usage").
+		For(obj, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &api.Workflow{}}, handler.EnqueueRequestsFromMapFunc(mapFn)).
+		Watches(&source.Kind{Type: &api.CronWorkflow{}}, handler.EnqueueRequestsFromMapFunc(mapFn)).
+		Watches(&source.Kind{Type: &api.WorkflowTemplate{}}, handler.EnqueueRequestsFromMapFunc(mapFn))
+	if r.Kind == "Connection" {
+		bldr = bldr.Watches(&source.Kind{Type: &api.DataSet{}}, handler.EnqueueRequestsFromMapFunc(dataSetConnectionRequests))
+	}
+	return bldr.Complete(r)
+}
+
+// injectingSpec returns the WorkflowSpec of a Workflow, CronWorkflow or WorkflowTemplate
+func injectingSpec(obj client.Object) (api.WorkflowSpec, bool) {
+	switch o := obj.(type) {
+	case *api.Workflow:
+		return o.Spec, true
+	case *api.CronWorkflow:
+		return o.Spec.WorkflowSpec, true
+	case *api.WorkflowTemplate:
+		return o.Spec.WorkflowSpec, true
+	}
+	return api.WorkflowSpec{}, false
+}
+
+// injectedConnectionRequests maps a Workflow, CronWorkflow or WorkflowTemplate to the Connections it injects.
+// On updates both the old and the new object are mapped, so Connections that are no longer injected are requeued as well.
+func injectedConnectionRequests(obj client.Object) []reconcile.Request {
+	wfs, ok := injectingSpec(obj)
+	if !ok {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, iv := range wfs.InjectableValues {
+		if iv.ConnectionRef.Name != "" {
+			requests = append(requests, reconcile.Request{NamespacedName: iv.ConnectionRef.GetNamespacedName(obj.GetNamespace())})
+		}
+	}
+	return requests
+}
+
+// injectedDataSetRequests maps a Workflow, CronWorkflow or WorkflowTemplate to the DataSets it injects
+func injectedDataSetRequests(obj client.Object) []reconcile.Request {
+	wfs, ok := injectingSpec(obj)
+	if !ok {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, iv := range wfs.InjectableValues {
+		if iv.DataSetRef.Name != "" {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: iv.DataSetRef.Name, Namespace: obj.GetNamespace()},
+			})
+		}
+	}
+	return requests
+}
+
+// dataSetConnectionRequests maps a DataSet to the Connection it refers to
+func dataSetConnectionRequests(obj client.Object) []reconcile.Request {
+	ds, ok := obj.(*api.DataSet)
+	if !ok || ds.Spec.Connection.ConnectionFrom == nil || ds.Spec.Connection.ConnectionFrom.Name == "" {
+		return nil
+	}
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Name: ds.Spec.Connection.ConnectionFrom.Name, Namespace: ds.Namespace},
+	}}
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	api "github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+var _ = Describe("UsageReconciler", func() {
+	const timeout = time.Second * 5
+	const interval = time.Second * 1
+
+	It("Should record the usage of Connections and DataSets", func() {
+		ctx := context.Background()
+		namespace := "default"
+
+		conn := api.Connection{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      randomSuffix("used-connection"),
+				Namespace: namespace,
+			},
+			Spec: api.ConnectionSpec{
+				Credentials: api.Credentials{},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &conn)).To(Succeed())
+		connKey := types.NamespacedName{Name: conn.Name, Namespace: conn.Namespace}
+
+		ds := api.DataSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      randomSuffix("used-dataset"),
+				Namespace: namespace,
+			},
+			Spec: api.DataSetSpec{
+				Type:        "MySQL DataSet",
+				StorageType: api.PersistentType,
+				Connection: api.ConnectionFrom{
+					ConnectionFrom: &api.ConnectionRef{
+						LocalObjectReference: corev1.LocalObjectReference{Name: conn.Name},
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &ds)).To(Succeed())
+		dsKey := types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}
+
+		wf := api.Workflow{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      generateWorkflowName(),
+				Namespace: namespace,
+			},
+			Spec: api.WorkflowSpec{
+				InjectableValues: api.InjectableValues{
+					api.InjectableValue{
+						Name:          "connection",
+						ConnectionRef: api.ConnectionReference{Name: conn.Name},
+					},
+					api.InjectableValue{
+						Name:       "dataset",
+						DataSetRef: corev1.LocalObjectReference{Name: ds.Name},
+					},
+				},
+				ArgoWorkflowSpec: wfv1.WorkflowSpec{},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &wf)).To(Succeed())
+
+		By("Listing the Workflow and DataSet using the Connection")
+		Eventually(func(g Gomega) {
+			var res api.Connection
+			g.Expect(k8sClient.Get(ctx, connKey, &res)).To(Succeed())
+			g.Expect(res.Status.Usage).ToNot(BeNil())
+			g.Expect(res.Status.Usage.Workflows).To(ConsistOf(wf.Name))
+			g.Expect(res.Status.Usage.DataSets).To(ConsistOf(ds.Name))
+		}, timeout, interval).Should(Succeed())
+
+		By("Listing the Workflow using the DataSet")
+		Eventually(func(g Gomega) {
+			var res api.DataSet
+			g.Expect(k8sClient.Get(ctx, dsKey, &res)).To(Succeed())
+			g.Expect(res.Status.Usage).ToNot(BeNil())
+			g.Expect(res.Status.Usage.Workflows).To(ConsistOf(wf.Name))
+		}, timeout, interval).Should(Succeed())
+
+		By("Clearing the usage once the Workflow no longer injects the DataSet")
+		Eventually(func() error {
+			var res api.Workflow
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: wf.Name, Namespace: wf.Namespace}, &res); err != nil {
+				return err
+			}
+			res.Spec.InjectableValues = res.Spec.InjectableValues[:1]
+			return k8sClient.Update(ctx, &res)
+		}, timeout, interval).Should(Succeed())
+		Eventually(func(g Gomega) {
+			var res api.DataSet
+			g.Expect(k8sClient.Get(ctx, dsKey, &res)).To(Succeed())
+			g.Expect(res.Status.Usage).To(BeNil())
+		}, timeout, interval).Should(Succeed())
+	})
+})
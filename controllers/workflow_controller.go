@@ -22,18 +22,33 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
 	"github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/internal/provider"
+	"github.com/dataworkz/kubeetl/pkg/util"
+)
+
+const (
+	// blockedRequeueInterval is the interval at which a blocked Workflow re-evaluates its dependencies
+	blockedRequeueInterval = 30 * time.Second
 )
 
 // WorkflowReconciler reconciles a Workflow object
@@ -41,12 +56,24 @@ type WorkflowReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
-	// ConnectionInjectionImage is the image of the container that will provide connection injections
-	ConnectionInjectionImage string
+	// InjectionTemplate is the default configuration of the container that provides connection injections
+	InjectionTemplate v1alpha1.InjectionTemplate
+	// InjectionMode is the default InjectionMode for resources that do not specify one
+	InjectionMode v1alpha1.InjectionMode
+	// SecretProvider renders the connection secret in InjectionModeController
+	SecretProvider provider.SecretProvider
+	// Recorder records events when credentials of the resource change
+	Recorder record.EventRecorder
 }
 
-// +kubebuilder:rbac:groups=etl.dataworkz.nl.dataworkz.nl,resources=workflows,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=etl.dataworkz.nl.dataworkz.nl,resources=workflows/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=workflows,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=workflows/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=connections;datasets;connectiontypes;datasettypes;clusterconnectiontypes;clusterdatasettypes;connectiongrants,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=argoproj.io,resources=workflows,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
 
 func (r *WorkflowReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := r.Log.WithValues("workflow", req.NamespacedName)
@@ -59,13 +86,38 @@ func (r *WorkflowReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	blocked, err := r.handleHealthGate(ctx, &workflow)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if blocked {
+		log.Info("workflow blocked by unhealthy dependencies")
+		return ctrl.Result{RequeueAfter: blockedRequeueInterval}, nil
+	}
+
 	cs := v1alpha1.ConnectionSecret(workflow.Name, workflow.Namespace)
 	log.Info("creating connection secret", "name", cs.Name, "namespace", cs.Namespace)
 
-	_, err := ctrl.CreateOrUpdate(ctx, r.Client, &cs, func() error { return r.updateSecret(&workflow, &cs) })
+	_, rendered, err := upsertConnectionSecret(ctx, r.Client, r.SecretProvider, &cs, func() (renderedSecret, error) {
+		return r.updateSecret(ctx, &workflow, &cs)
+	})
+	if util.IsAccessDenied(err) {
+		// access is granted through RBAC, which the controller does not watch
+		msg := err.Error()
+		log.Info("injection service account is not allowed to read a dependency", "reason", msg)
+		if err := r.updateStatus(ctx, &workflow, func(status *v1alpha1.WorkflowStatus) {
+			setPhaseCondition(status, v1alpha1.WorkflowConditionReady, false, "AccessDenied", msg, workflow.Generation)
+		}); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: blockedRequeueInterval}, nil
+	}
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("error creating workflow connection secret: %w", err)
 	}
+	if rendered.changed {
+		recordCredentialsChanged(r.Recorder, &workflow, &cs)
+	}
 
 	awf := wfv1.Workflow{
 		ObjectMeta: metav1.ObjectMeta{
@@ -73,23 +125,109 @@ func (r *WorkflowReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 			Name:      workflow.Name,
 		},
 	}
-	_, err = ctrl.CreateOrUpdate(ctx, r.Client, &awf, func() error { return r.updateWorkflow(&workflow, &awf) })
+	_, err = ctrl.CreateOrUpdate(ctx, r.Client, &awf, func() error { return r.updateWorkflow(ctx, &workflow, &awf) })
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("error upserting argo workflow: %w", err)
 	}
 
+	if err := r.updateStatus(ctx, &workflow, func(status *v1alpha1.WorkflowStatus) {
+		syncWorkflowStatus(status, &awf, workflow.Generation)
+	}); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	return ctrl.Result{}, nil
 }
 
-func (r *WorkflowReconciler) updateSecret(workflow *v1alpha1.Workflow, secret *corev1.Secret) error {
-	if err := ctrl.SetControllerReference(workflow, secret, r.Scheme); err != nil {
-		return fmt.Errorf("error setting owner reference on connection secret: %w", err)
+// handleHealthGate evaluates the health of the dependencies that must be healthy before the Argo Workflow
+// is submitted and records the result in the Blocked condition. An Argo Workflow that has already been
+// submitted is never held back, nor is a Workflow that no longer requires healthy dependencies.
+func (r *WorkflowReconciler) handleHealthGate(ctx context.Context, workflow *v1alpha1.Workflow) (bool, error) {
+	if !workflow.Spec.RequiresHealthyDependencies() {
+		// the gate has been removed while the Argo Workflow was held back
+		if !meta.IsStatusConditionTrue(workflow.Status.Conditions, v1alpha1.WorkflowConditionBlocked) {
+			return false, nil
+		}
+		return false, r.updateStatus(ctx, workflow, func(status *v1alpha1.WorkflowStatus) {
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:               v1alpha1.WorkflowConditionBlocked,
+				Status:             metav1.ConditionFalse,
+				Reason:             "NoRequiredDependencies",
+				Message:            "no dependencies are required to be healthy",
+				ObservedGeneration: workflow.Generation,
+			})
+		})
+	}
+
+	var awf wfv1.Workflow
+	err := r.Get(ctx, types.NamespacedName{Name: workflow.Name, Namespace: workflow.Namespace}, &awf)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.IsNotFound(err) {
+		return false, fmt.Errorf("unable to fetch argo workflow: %w", err)
+	}
+
+	unhealthy, err := unhealthyDependencies(ctx, r.Client, workflow.Namespace, workflow.Spec)
+	if err != nil {
+		return false, err
+	}
+
+	cond := metav1.Condition{
+		Type:               v1alpha1.WorkflowConditionBlocked,
+		Status:             metav1.ConditionFalse,
+		Reason:             "DependenciesHealthy",
+		Message:            "all required dependencies are healthy",
+		ObservedGeneration: workflow.Generation,
+	}
+	blocked := len(unhealthy) > 0
+	if blocked {
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = "UnhealthyDependencies"
+		cond.Message = fmt.Sprintf("waiting for healthy dependencies: %s", strings.Join(unhealthy, ", "))
+	}
+
+	err = r.updateStatus(ctx, workflow, func(status *v1alpha1.WorkflowStatus) {
+		meta.SetStatusCondition(&status.Conditions, cond)
+		if blocked {
+			setPhaseCondition(status, v1alpha1.WorkflowConditionReady, false, cond.Reason, cond.Message, workflow.Generation)
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return blocked, nil
+}
+
+// updateStatus applies the mutation to the Workflow status and only updates the status if it changed.
+func (r *WorkflowReconciler) updateStatus(ctx context.Context, workflow *v1alpha1.Workflow, mutate func(*v1alpha1.WorkflowStatus)) error {
+	old := workflow.Status.DeepCopy()
+	mutate(&workflow.Status)
+	if equality.Semantic.DeepEqual(old, &workflow.Status) {
+		return nil
+	}
+
+	if err := r.Status().Update(ctx, workflow); err != nil {
+		return fmt.Errorf("unable to update Workflow status: %w", err)
 	}
 	return nil
 }
 
-func (r *WorkflowReconciler) updateWorkflow(workflow *v1alpha1.Workflow, awf *wfv1.Workflow) error {
-	awfSpec, err := createArgoWorkflowSpec(workflow.Spec, awf.Name, r.ConnectionInjectionImage, awf.Namespace)
+func (r *WorkflowReconciler) updateSecret(ctx context.Context, workflow *v1alpha1.Workflow, secret *corev1.Secret) (renderedSecret, error) {
+	if err := ctrl.SetControllerReference(workflow, secret, r.Scheme); err != nil {
+		return renderedSecret{}, fmt.Errorf("error setting owner reference on connection secret: %w", err)
+	}
+	mode := workflow.Spec.GetInjectionMode(r.InjectionMode)
+	return renderConnectionSecret(ctx, r.SecretProvider, v1alpha1.WorkflowKindWorkflow, mode, workflow.Namespace, &workflow.Spec, secret)
+}
+
+func (r *WorkflowReconciler) updateWorkflow(ctx context.Context, workflow *v1alpha1.Workflow, awf *wfv1.Workflow) error {
+	expanded, err := expandFields(ctx, r.Client, workflow.Namespace, connectionSecretName(v1alpha1.WorkflowKindWorkflow, awf.Name), workflow.Spec)
+	if err != nil {
+		return fmt.Errorf("error expanding connection fields: %w", err)
+	}
+	awfSpec, err := createArgoWorkflowSpec(workflow.Spec, v1alpha1.WorkflowKindWorkflow, workflow.Spec.GetInjectionMode(r.InjectionMode), awf.Name, awf.Namespace, r.InjectionTemplate, expanded)
 	if err != nil {
 		return fmt.Errorf("error creating argo workflow spec: %w", err)
 	}
@@ -104,8 +242,19 @@ func (r *WorkflowReconciler) updateWorkflow(workflow *v1alpha1.Workflow, awf *wf
 func (r *WorkflowReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.Client = mgr.GetClient()
 	r.Scheme = mgr.GetScheme()
+	if r.SecretProvider == nil {
+		r.SecretProvider = provider.NewAccessReviewingSecretProvider(r.Client)
+	}
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor("workflow-controller")
+	}
 
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.Workflow{}).
+		Owns(&wfv1.Workflow{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, credentialRolloutHandler(r.Client, r.Log, func() client.ObjectList { return &v1alpha1.WorkflowList{} }),
+			builder.WithPredicates(credentialsChangedPredicate)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, credentialRolloutHandler(r.Client, r.Log, func() client.ObjectList { return &v1alpha1.WorkflowList{} }),
+			builder.WithPredicates(credentialsChangedPredicate)).
 		Complete(r)
 }
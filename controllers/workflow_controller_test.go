@@ -12,8 +12,14 @@ import (
 
 	// corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/dataworkz/kubeetl/api/v1alpha1"
 	api "github.com/dataworkz/kubeetl/api/v1alpha1"
@@ -78,7 +84,7 @@ var _ = Describe("WorkflowReconciler", func() {
 				InjectableValues: api.InjectableValues{
 					api.InjectableValue{
 						Name:          "injectable-host",
-						ConnectionRef: v1.LocalObjectReference{Name: "default-connection"},
+						ConnectionRef: v1alpha1.ConnectionReference{Name: "default-connection"},
 						Content:       "{{.Host}}",
 						MountPath:     mountPath,
 					},
@@ -147,6 +153,196 @@ var _ = Describe("WorkflowReconciler", func() {
 		})
 	})
 
+	Context("Injections mounted as a directory of files", func() {
+		It("Should mount the rendered files in a projected volume", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{
+				Name:      generateWorkflowName(),
+				Namespace: "default",
+			}
+
+			iv := api.InjectableValue{
+				Name:          "profiles",
+				ConnectionRef: v1alpha1.ConnectionReference{Name: resources.connKey.Name},
+				MountPath:     "/home/dbt/.dbt",
+				Files: map[string]api.ContentTemplate{
+					"profiles.yml": "host: {{.host}}",
+					"config.json":  `{"host": {{.host | toJson}}}`,
+				},
+				FileMode: pointer.Int32Ptr(0400),
+			}
+			created := api.Workflow{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      key.Name,
+					Namespace: key.Namespace,
+				},
+				Spec: api.WorkflowSpec{
+					InjectionMode: api.InjectionModeController,
+					InjectInto: []api.TemplateRef{
+						{Name: "containertemplate", InjectedValues: []string{iv.Name}},
+					},
+					InjectableValues: api.InjectableValues{iv},
+					ArgoWorkflowSpec: wfv1.WorkflowSpec{
+						Templates: []wfv1.Template{
+							{Name: "containertemplate", Container: &v1.Container{}},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, &created)).Should(Succeed())
+
+			var res wfv1.Workflow
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, key, &res)).Should(Succeed())
+
+				var volume *v1.Volume
+				for i := range res.Spec.Volumes {
+					if res.Spec.Volumes[i].Name == api.InjectableValueVolumeName(iv.Name) {
+						volume = &res.Spec.Volumes[i]
+					}
+				}
+				g.Expect(volume).ToNot(BeNil())
+				g.Expect(volume.Projected).ToNot(BeNil())
+				g.Expect(volume.Projected.DefaultMode).To(Equal(pointer.Int32Ptr(0400)))
+				g.Expect(volume.Projected.Sources).To(HaveLen(1))
+				g.Expect(volume.Projected.Sources[0].Secret.Items).To(Equal([]v1.KeyToPath{
+					{Key: "profiles.config.json", Path: "config.json"},
+					{Key: "profiles.profiles.yml", Path: "profiles.yml"},
+				}))
+
+				container := res.GetTemplateByName("containertemplate")
+				g.Expect(container).ToNot(BeNil())
+				g.Expect(container.Container.VolumeMounts).To(ContainElement(v1.VolumeMount{
+					Name:      api.InjectableValueVolumeName(iv.Name),
+					MountPath: iv.MountPath,
+					ReadOnly:  true,
+				}))
+			}, timeout, interval).Should(Succeed())
+
+			By("Rendering every file into the connection secret")
+			cs := api.ConnectionSecret(created.Name, created.Namespace)
+			Eventually(func(g Gomega) {
+				var secret v1.Secret
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: cs.Name, Namespace: cs.Namespace}, &secret)).To(Succeed())
+				g.Expect(string(secret.Data["profiles.profiles.yml"])).To(Equal("host: localhost"))
+				g.Expect(string(secret.Data["profiles.config.json"])).To(Equal(`{"host": "localhost"}`))
+			}, timeout, interval).Should(Succeed())
+
+			Expect(k8sClient.Delete(ctx, &created)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &res)).To(Succeed())
+		})
+	})
+
+	Context("Injections expanding the fields of a Connection", func() {
+		It("Should inject every field as an environment variable named by the ConnectionType", func() {
+			ctx := context.Background()
+
+			connType := api.ConnectionType{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      randomSuffix("expand-type"),
+					Namespace: "default",
+				},
+				Spec: api.ConnectionTypeSpec{
+					Fields: []api.CredentialFieldSpec{
+						{Name: "host", EnvKey: "DB_HOST"},
+						{Name: "user", EnvKey: "DB_USER"},
+						{Name: "password", EnvKey: "DB_PASSWORD", Sensitive: true},
+						{Name: "port"},
+						{Name: "dsn", EnvKey: "DB_DSN", Derived: "{{.user}}:{{.password}}@{{.host}}"},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, &connType)).Should(Succeed())
+
+			var conn api.Connection
+			Expect(k8sClient.Get(ctx, resources.connKey, &conn)).Should(Succeed())
+			expandConn := api.Connection{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      randomSuffix("expand-connection"),
+					Namespace: "default",
+				},
+				Spec: conn.Spec,
+			}
+			expandConn.Spec.Type = connType.Name
+			expandConn.Spec.Credentials["port"] = api.Value{Value: "3306"}
+			Expect(k8sClient.Create(ctx, &expandConn)).Should(Succeed())
+
+			key := types.NamespacedName{
+				Name:      generateWorkflowName(),
+				Namespace: "default",
+			}
+			created := api.Workflow{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      key.Name,
+					Namespace: key.Namespace,
+				},
+				Spec: api.WorkflowSpec{
+					InjectInto: []api.TemplateRef{
+						{Name: "containertemplate", InjectedValues: []string{"database"}},
+					},
+					InjectableValues: api.InjectableValues{
+						{
+							Name:          "database",
+							ConnectionRef: v1alpha1.ConnectionReference{Name: expandConn.Name},
+							ExpandFields:  true,
+						},
+					},
+					ArgoWorkflowSpec: wfv1.WorkflowSpec{
+						Templates: []wfv1.Template{
+							{Name: "containertemplate", Container: &v1.Container{}},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, &created)).Should(Succeed())
+
+			var res wfv1.Workflow
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, key, &res)).Should(Succeed())
+
+				container := res.GetTemplateByName("containertemplate")
+				g.Expect(container).ToNot(BeNil())
+				g.Expect(container.Container.Env).To(Equal([]v1.EnvVar{
+					{Name: "DB_HOST", Value: "localhost"},
+					{
+						Name: "DB_USER",
+						ValueFrom: &v1.EnvVarSource{
+							ConfigMapKeyRef: &v1.ConfigMapKeySelector{LocalObjectReference: resources.cmRef, Key: "user"},
+						},
+					},
+					{
+						Name: "DB_PASSWORD",
+						ValueFrom: &v1.EnvVarSource{
+							SecretKeyRef: &v1.SecretKeySelector{LocalObjectReference: resources.secretRef, Key: "password"},
+						},
+					},
+					{
+						Name: "DB_DSN",
+						ValueFrom: &v1.EnvVarSource{
+							SecretKeyRef: &v1.SecretKeySelector{
+								LocalObjectReference: v1.LocalObjectReference{Name: api.NameWithHash(created.Name)},
+								Key:                  "database.dsn",
+							},
+						},
+					},
+				}))
+			}, timeout, interval).Should(Succeed())
+
+			By("Rendering the derived field into the connection secret")
+			cs := api.ConnectionSecret(created.Name, created.Namespace)
+			Eventually(func(g Gomega) {
+				var secret v1.Secret
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: cs.Name, Namespace: cs.Namespace}, &secret)).To(Succeed())
+				g.Expect(secret.Data).To(HaveKey("database.dsn"))
+			}, timeout, interval).Should(Succeed())
+
+			Expect(k8sClient.Delete(ctx, &created)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &res)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &expandConn)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &connType)).To(Succeed())
+		})
+	})
+
 	Context("Workflow with injections as environment variables", func() {
 		It("Should inject templates in a DAG", func() {
 			ctx := context.Background()
@@ -166,7 +362,7 @@ var _ = Describe("WorkflowReconciler", func() {
 				InjectableValues: api.InjectableValues{
 					api.InjectableValue{
 						Name:          "injectable-host",
-						ConnectionRef: v1.LocalObjectReference{Name: "default-connection"},
+						ConnectionRef: v1alpha1.ConnectionReference{Name: "default-connection"},
 						Content:       "{{.Host}}",
 						EnvName:       "HOST",
 					},
@@ -243,7 +439,7 @@ var _ = Describe("WorkflowReconciler", func() {
 				InjectableValues: api.InjectableValues{
 					api.InjectableValue{
 						Name:          "injectable-host",
-						ConnectionRef: v1.LocalObjectReference{Name: "default-connection"},
+						ConnectionRef: v1alpha1.ConnectionReference{Name: "default-connection"},
 						Content:       "{{.Host}}",
 						EnvName:       "HOST",
 					},
@@ -290,7 +486,7 @@ var _ = Describe("WorkflowReconciler", func() {
 				InjectableValues: api.InjectableValues{
 					api.InjectableValue{
 						Name:          "injectable-host",
-						ConnectionRef: v1.LocalObjectReference{Name: "default-connection"},
+						ConnectionRef: v1alpha1.ConnectionReference{Name: "default-connection"},
 						Content:       "{{.Host}}",
 						EnvName:       "HOST",
 					},
@@ -317,14 +513,376 @@ var _ = Describe("WorkflowReconciler", func() {
 
 				g.Expect(len(ep.Steps)).To(Equal(2))
 				g.Expect(ep.Steps[0].Steps[0].Name).To(Equal("run-injection"))
+
+				injection := res.GetTemplateByName("run-injection")
+				g.Expect(injection).ToNot(BeNil())
+				g.Expect(injection.Container.Args).To(Equal([]string{
+					"connectionprovider", "--kind", "Workflow", "--workflow", key.Name, "--namespace", key.Namespace,
+				}))
+			}, timeout, interval).Should(Succeed())
+
+			Expect(k8sClient.Delete(ctx, &created)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &res)).To(Succeed())
+		})
+	})
+
+	Context("Workflow with an injection override", func() {
+		It("Should configure the injection container", func() {
+			ctx := context.Background()
+			wfName := generateWorkflowName()
+			key := types.NamespacedName{
+				Name:      wfName,
+				Namespace: "default",
+			}
+
+			override := &api.InjectionTemplate{
+				Image:            "kubeetl/connection-injector@sha256:1234",
+				ImagePullPolicy:  v1.PullAlways,
+				ImagePullSecrets: []v1.LocalObjectReference{{Name: "registry"}},
+				Resources: &v1.ResourceRequirements{
+					Limits: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m")},
+				},
+				SecurityContext: &v1.SecurityContext{RunAsNonRoot: pointer.BoolPtr(true)},
+				Tolerations:     []v1.Toleration{{Key: "dedicated", Operator: v1.TolerationOpExists}},
+			}
+			created := api.Workflow{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      key.Name,
+					Namespace: key.Namespace,
+				},
+				Spec: api.WorkflowSpec{
+					Injection:        override,
+					ArgoWorkflowSpec: wfv1.WorkflowSpec{},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, &created)).To(Succeed())
+
+			var res wfv1.Workflow
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, key, &res)).To(Succeed())
+				injection := res.GetTemplateByName("run-injection")
+				g.Expect(injection).ToNot(BeNil())
+				g.Expect(injection.Container.Image).To(Equal(override.Image))
+				g.Expect(injection.Container.ImagePullPolicy).To(Equal(v1.PullAlways))
+				g.Expect(injection.Container.Resources.Limits.Cpu().String()).To(Equal("100m"))
+				g.Expect(injection.Container.SecurityContext).To(Equal(override.SecurityContext))
+				g.Expect(injection.Tolerations).To(Equal(override.Tolerations))
+				g.Expect(res.Spec.ImagePullSecrets).To(Equal(override.ImagePullSecrets))
+			}, timeout, interval).Should(Succeed())
+
+			Expect(k8sClient.Delete(ctx, &created)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &res)).To(Succeed())
+		})
+
+		It("Should pass the secret backend config to the injection container", func() {
+			ctx := context.Background()
+			wfName := generateWorkflowName()
+			key := types.NamespacedName{
+				Name:      wfName,
+				Namespace: "default",
+			}
+
+			created := api.Workflow{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      key.Name,
+					Namespace: key.Namespace,
+				},
+				Spec: api.WorkflowSpec{
+					Injection:        &api.InjectionTemplate{SecretBackendConfig: "secret-backends"},
+					ArgoWorkflowSpec: wfv1.WorkflowSpec{},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, &created)).To(Succeed())
+
+			var res wfv1.Workflow
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, key, &res)).To(Succeed())
+				injection := res.GetTemplateByName("run-injection")
+				g.Expect(injection).ToNot(BeNil())
+				g.Expect(injection.Container.Args).To(ContainElements("--secret-backend-config", "/etc/kubeetl/secret-backends/config.yaml"))
+				g.Expect(injection.Container.VolumeMounts).To(ContainElement(v1.VolumeMount{
+					Name:      "kubeetl-secret-backends",
+					MountPath: api.SecretBackendConfigMountPath,
+					ReadOnly:  true,
+				}))
+				g.Expect(res.Spec.Volumes).To(ContainElement(v1.Volume{
+					Name: "kubeetl-secret-backends",
+					VolumeSource: v1.VolumeSource{
+						Secret: &v1.SecretVolumeSource{SecretName: "secret-backends"},
+					},
+				}))
+			}, timeout, interval).Should(Succeed())
+
+			Expect(k8sClient.Delete(ctx, &created)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &res)).To(Succeed())
+		})
+	})
+
+	Context("Workflow with InjectionModeController", func() {
+		It("Should render the connection secret without an injection step", func() {
+			ctx := context.Background()
+			wfName := generateWorkflowName()
+			key := types.NamespacedName{
+				Name:      wfName,
+				Namespace: "default",
+			}
+
+			conn := api.Connection{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      randomSuffix("inline-connection"),
+					Namespace: key.Namespace,
+				},
+				Spec: api.ConnectionSpec{
+					Credentials: api.Credentials{
+						"host": api.Value{Value: "localhost"},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, &conn)).To(Succeed())
+
+			created := api.Workflow{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      key.Name,
+					Namespace: key.Namespace,
+				},
+				Spec: api.WorkflowSpec{
+					InjectionMode: api.InjectionModeController,
+					InjectableValues: api.InjectableValues{
+						api.InjectableValue{
+							Name:          "injectable-host",
+							ConnectionRef: v1alpha1.ConnectionReference{Name: conn.Name},
+							Content:       "{{.host}}",
+							EnvName:       "HOST",
+						},
+					},
+					ArgoWorkflowSpec: wfv1.WorkflowSpec{Entrypoint: "main"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, &created)).To(Succeed())
+
+			var secret v1.Secret
+			cs := api.ConnectionSecret(created.Name, created.Namespace)
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: cs.Name, Namespace: cs.Namespace}, &secret)).To(Succeed())
+				g.Expect(string(secret.Data["injectable-host"])).To(Equal("localhost"))
+			}, timeout, interval).Should(Succeed())
+
+			var res wfv1.Workflow
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, key, &res)).To(Succeed())
+				g.Expect(res.Spec.Entrypoint).To(Equal("main"))
+				g.Expect(res.GetTemplateByName("run-injection")).To(BeNil())
 			}, timeout, interval).Should(Succeed())
 
 			Expect(k8sClient.Delete(ctx, &created)).To(Succeed())
 			Expect(k8sClient.Delete(ctx, &res)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &conn)).To(Succeed())
 		})
 	})
 })
 
+var _ = Describe("WorkflowReconciler health gate", func() {
+	const timeout = time.Second * 5
+	const interval = time.Second * 1
+
+	It("Should not submit the Argo Workflow while a required DataSet is unhealthy", func() {
+		ctx := context.Background()
+
+		dsKey := types.NamespacedName{
+			Name:      randomSuffix("gated-dataset"),
+			Namespace: "default",
+		}
+		ds := api.DataSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      dsKey.Name,
+				Namespace: dsKey.Namespace,
+			},
+			Spec: api.DataSetSpec{
+				StorageType: api.PersistentType,
+				Type:        "MySQL DataSet",
+				HealthCheck: &api.WorkflowReference{
+					Namespace: "default",
+					Name:      "unknown-wf",
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &ds)).Should(Succeed())
+
+		key := types.NamespacedName{
+			Name:      generateWorkflowName(),
+			Namespace: "default",
+		}
+		created := api.Workflow{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+			},
+			Spec: api.WorkflowSpec{
+				InjectableValues: api.InjectableValues{
+					api.InjectableValue{
+						Name:           "injectable-table",
+						DataSetRef:     v1.LocalObjectReference{Name: dsKey.Name},
+						Content:        "{{.metadata.table}}",
+						EnvName:        "TABLE",
+						RequireHealthy: true,
+					},
+				},
+				ArgoWorkflowSpec: wfv1.WorkflowSpec{},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &created)).Should(Succeed())
+
+		By("Setting the Blocked condition")
+		Eventually(func(g Gomega) {
+			var res api.Workflow
+			g.Expect(k8sClient.Get(ctx, key, &res)).Should(Succeed())
+			cond := meta.FindStatusCondition(res.Status.Conditions, api.WorkflowConditionBlocked)
+			g.Expect(cond).ToNot(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		}, timeout, interval).Should(Succeed())
+
+		var awf wfv1.Workflow
+		Consistently(func() bool {
+			err := k8sClient.Get(ctx, key, &awf)
+			return errors.IsNotFound(err)
+		}, timeout, interval).Should(BeTrue())
+
+		By("Submitting the Argo Workflow once the gate is removed")
+		Eventually(func() error {
+			var res api.Workflow
+			if err := k8sClient.Get(ctx, key, &res); err != nil {
+				return err
+			}
+			res.Spec.InjectableValues[0].RequireHealthy = false
+			return k8sClient.Update(ctx, &res)
+		}, timeout, interval).Should(Succeed())
+
+		Eventually(func() error {
+			return k8sClient.Get(ctx, key, &awf)
+		}, timeout, interval).Should(Succeed())
+
+		By("Clearing the Blocked condition once the gate is removed")
+		Eventually(func(g Gomega) {
+			var res api.Workflow
+			g.Expect(k8sClient.Get(ctx, key, &res)).Should(Succeed())
+			cond := meta.FindStatusCondition(res.Status.Conditions, api.WorkflowConditionBlocked)
+			g.Expect(cond).ToNot(BeNil())
+			g.Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			g.Expect(cond.ObservedGeneration).To(Equal(res.Generation))
+			g.Expect(meta.IsStatusConditionTrue(res.Status.Conditions, api.WorkflowConditionReady)).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+
+		Expect(k8sClient.Delete(ctx, &created)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, &awf)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, &ds)).To(Succeed())
+	})
+})
+
+var _ = Describe("Removing the health gate", func() {
+	It("Should set the Blocked condition to false", func() {
+		ctx := context.Background()
+		s := runtime.NewScheme()
+		Expect(api.AddToScheme(s)).To(Succeed())
+
+		workflow := &api.Workflow{
+			ObjectMeta: metav1.ObjectMeta{Name: "ungated", Namespace: "default", Generation: 2},
+			Status: api.WorkflowStatus{Conditions: []metav1.Condition{
+				{Type: api.WorkflowConditionBlocked, Status: metav1.ConditionTrue, Reason: "UnhealthyDependencies", ObservedGeneration: 1},
+				{Type: api.WorkflowConditionReady, Status: metav1.ConditionFalse, Reason: "UnhealthyDependencies", ObservedGeneration: 1},
+			}},
+		}
+		r := &WorkflowReconciler{Client: fake.NewClientBuilder().WithScheme(s).WithObjects(workflow).Build()}
+
+		blocked, err := r.handleHealthGate(ctx, workflow)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(blocked).To(BeFalse())
+
+		var res api.Workflow
+		Expect(r.Get(ctx, types.NamespacedName{Name: workflow.Name, Namespace: workflow.Namespace}, &res)).To(Succeed())
+		cond := meta.FindStatusCondition(res.Status.Conditions, api.WorkflowConditionBlocked)
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal("NoRequiredDependencies"))
+		Expect(cond.ObservedGeneration).To(Equal(int64(2)))
+	})
+})
+
+var _ = Describe("WorkflowReconciler status", func() {
+	const timeout = time.Second * 5
+	const interval = time.Second * 1
+
+	It("Should mirror the Argo Workflow status", func() {
+		ctx := context.Background()
+		key := types.NamespacedName{
+			Name:      generateWorkflowName(),
+			Namespace: "default",
+		}
+		created := api.Workflow{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+			},
+			Spec: api.WorkflowSpec{
+				ArgoWorkflowSpec: wfv1.WorkflowSpec{},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &created)).Should(Succeed())
+
+		By("Marking the Workflow Ready once the Argo Workflow is submitted")
+		Eventually(func(g Gomega) {
+			var res api.Workflow
+			g.Expect(k8sClient.Get(ctx, key, &res)).Should(Succeed())
+			g.Expect(res.Status.ArgoWorkflowRef).ToNot(BeNil())
+			g.Expect(res.Status.ArgoWorkflowRef.Name).To(Equal(key.Name))
+			g.Expect(meta.IsStatusConditionTrue(res.Status.Conditions, api.WorkflowConditionReady)).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+
+		By("Copying the phase and failed nodes of the Argo Workflow")
+		var awf wfv1.Workflow
+		Eventually(func() error {
+			if err := k8sClient.Get(ctx, key, &awf); err != nil {
+				return err
+			}
+			awf.Status.Phase = wfv1.NodeFailed
+			awf.Status.Message = "child failed"
+			awf.Status.Progress = "0/1"
+			awf.Status.StartedAt = metav1.Now()
+			awf.Status.FinishedAt = metav1.Now()
+			awf.Status.Nodes = wfv1.Nodes{
+				"node-1": wfv1.NodeStatus{
+					ID:           "node-1",
+					Name:         "main",
+					TemplateName: "main",
+					Type:         wfv1.NodeTypePod,
+					Phase:        wfv1.NodeFailed,
+					Message:      "exit code 1",
+				},
+			}
+			return k8sClient.Update(ctx, &awf)
+		}, timeout, interval).Should(Succeed())
+
+		Eventually(func(g Gomega) {
+			var res api.Workflow
+			g.Expect(k8sClient.Get(ctx, key, &res)).Should(Succeed())
+			g.Expect(res.Status.Phase).To(Equal(wfv1.NodeFailed))
+			g.Expect(res.Status.Message).To(Equal("child failed"))
+			g.Expect(res.Status.Progress).To(Equal(wfv1.Progress("0/1")))
+			g.Expect(res.Status.FinishedAt).ToNot(BeNil())
+			g.Expect(res.Status.FailedNodes).To(HaveLen(1))
+			g.Expect(res.Status.FailedNodes[0].Message).To(Equal("exit code 1"))
+			g.Expect(res.Status.History).To(HaveLen(1))
+			g.Expect(meta.IsStatusConditionTrue(res.Status.Conditions, api.WorkflowConditionFailed)).To(BeTrue())
+			g.Expect(meta.IsStatusConditionTrue(res.Status.Conditions, api.WorkflowConditionSucceeded)).To(BeFalse())
+			g.Expect(meta.IsStatusConditionTrue(res.Status.Conditions, api.WorkflowConditionRunning)).To(BeFalse())
+		}, timeout, interval).Should(Succeed())
+
+		Expect(k8sClient.Delete(ctx, &created)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, &awf)).To(Succeed())
+	})
+})
+
 func envContainsInjectableValue(env []v1.EnvVar, iv api.InjectableValue, connectionSecretName string) bool {
 	for _, e := range env {
 		if e.Name == iv.EnvName {
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"sort"
+
+	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+const (
+	// workflowHistoryLimit is the maximum number of completed runs kept in the WorkflowStatus
+	workflowHistoryLimit = 10
+)
+
+// syncWorkflowStatus copies the observed state of the Argo Workflow into the WorkflowStatus
+// and updates the Ready, Running, Succeeded and Failed conditions.
+func syncWorkflowStatus(status *v1alpha1.WorkflowStatus, awf *wfv1.Workflow, generation int64) {
+	status.ArgoWorkflowRef = &corev1.ObjectReference{
+		APIVersion: wfv1.WorkflowSchemaGroupVersionKind.GroupVersion().String(),
+		Kind:       wfv1.WorkflowSchemaGroupVersionKind.Kind,
+		Name:       awf.Name,
+		Namespace:  awf.Namespace,
+		UID:        awf.UID,
+	}
+
+	as := awf.Status
+	status.Phase = as.Phase
+	status.StartedAt = timeOrNil(as.StartedAt)
+	status.FinishedAt = timeOrNil(as.FinishedAt)
+	status.Message = as.Message
+	status.Progress = as.Progress
+	status.FailedNodes = failedNodes(as.Nodes)
+
+	if as.Fulfilled() {
+		status.History = recordRun(status.History, v1alpha1.WorkflowRun{
+			ArgoWorkflowUID: awf.UID,
+			Phase:           as.Phase,
+			StartedAt:       status.StartedAt,
+			FinishedAt:      status.FinishedAt,
+			Message:         as.Message,
+		})
+	}
+
+	phase := string(as.Phase)
+	if phase == "" {
+		phase = string(wfv1.NodePending)
+	}
+	setPhaseCondition(status, v1alpha1.WorkflowConditionReady, true, "Submitted", "Argo Workflow has been submitted", generation)
+	setPhaseCondition(status, v1alpha1.WorkflowConditionRunning, as.Phase == wfv1.NodeRunning, phase, as.Message, generation)
+	setPhaseCondition(status, v1alpha1.WorkflowConditionSucceeded, as.Successful(), phase, as.Message, generation)
+	setPhaseCondition(status, v1alpha1.WorkflowConditionFailed, as.Phase.FailedOrError(), phase, as.Message, generation)
+}
+
+func setPhaseCondition(status *v1alpha1.WorkflowStatus, condType string, value bool, reason, message string, generation int64) {
+	cond := metav1.Condition{
+		Type:               condType,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: generation,
+	}
+	if value {
+		cond.Status = metav1.ConditionTrue
+	}
+	meta.SetStatusCondition(&status.Conditions, cond)
+}
+
+// failedNodes returns the failed or errored nodes, sorted by name
+func failedNodes(nodes wfv1.Nodes) []v1alpha1.NodeFailure {
+	var failures []v1alpha1.NodeFailure
+	for _, n := range nodes {
+		if !n.Phase.FailedOrError() {
+			continue
+		}
+		failures = append(failures, v1alpha1.NodeFailure{
+			Name:         n.Name,
+			DisplayName:  n.DisplayName,
+			TemplateName: n.TemplateName,
+			Phase:        n.Phase,
+			Message:      n.Message,
+		})
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].Name < failures[j].Name
+	})
+	return failures
+}
+
+// recordRun adds the run to the history if it is not recorded yet, keeping at most workflowHistoryLimit runs
+func recordRun(history []v1alpha1.WorkflowRun, run v1alpha1.WorkflowRun) []v1alpha1.WorkflowRun {
+	for _, r := range history {
+		if r.ArgoWorkflowUID == run.ArgoWorkflowUID {
+			return history
+		}
+	}
+	history = append(history, run)
+	if len(history) > workflowHistoryLimit {
+		history = history[len(history)-workflowHistoryLimit:]
+	}
+	return history
+}
+
+func timeOrNil(t metav1.Time) *metav1.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -29,11 +29,16 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
 	"github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/internal/provider"
+	"github.com/dataworkz/kubeetl/pkg/util"
 )
 
 // WorkflowTemplateReconciler reconciles a WorkflowTemplate object
@@ -41,12 +46,24 @@ type WorkflowTemplateReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
-	// ConnectionInjectionImage is the image of the container that will provide connection injections
-	ConnectionInjectionImage string
+	// InjectionTemplate is the default configuration of the container that provides connection injections
+	InjectionTemplate v1alpha1.InjectionTemplate
+	// InjectionMode is the default InjectionMode for resources that do not specify one
+	InjectionMode v1alpha1.InjectionMode
+	// SecretProvider renders the connection secret in InjectionModeController
+	SecretProvider provider.SecretProvider
+	// Recorder records events when credentials of the resource change
+	Recorder record.EventRecorder
 }
 
-// +kubebuilder:rbac:groups=etl.dataworkz.nl.dataworkz.nl,resources=workflowtemplates,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=etl.dataworkz.nl.dataworkz.nl,resources=workflowtemplates/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=workflowtemplates,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=workflowtemplates/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=etl.dataworkz.nl,resources=connections;datasets;connectiontypes;datasettypes;clusterconnectiontypes;clusterdatasettypes;connectiongrants,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=argoproj.io,resources=workflowtemplates,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
 
 func (r *WorkflowTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := r.Log.WithValues("workflow", req.NamespacedName)
@@ -63,10 +80,19 @@ func (r *WorkflowTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Req
 
 	log.Info("creating connection secret", "name", cs.Name, "namespace", cs.Namespace)
 
-	_, err := ctrl.CreateOrUpdate(ctx, r.Client, &cs, func() error { return r.updateSecret(&wft, &cs) })
+	_, rendered, err := upsertConnectionSecret(ctx, r.Client, r.SecretProvider, &cs, func() (renderedSecret, error) {
+		return r.updateSecret(ctx, &wft, &cs)
+	})
+	if util.IsAccessDenied(err) {
+		r.Recorder.Event(&wft, corev1.EventTypeWarning, "AccessDenied", err.Error())
+		return ctrl.Result{RequeueAfter: blockedRequeueInterval}, nil
+	}
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("error creating workflow connection secret: %w", err)
 	}
+	if rendered.changed {
+		recordCredentialsChanged(r.Recorder, &wft, &cs)
+	}
 
 	acwf := wfv1.WorkflowTemplate{
 		ObjectMeta: metav1.ObjectMeta{
@@ -74,7 +100,7 @@ func (r *WorkflowTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Req
 			Namespace: wft.Namespace,
 		},
 	}
-	_, err = ctrl.CreateOrUpdate(ctx, r.Client, &acwf, func() error { return r.updateWorkflowTemplate(&wft, &acwf) })
+	_, err = ctrl.CreateOrUpdate(ctx, r.Client, &acwf, func() error { return r.updateWorkflowTemplate(ctx, &wft, &acwf) })
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("error upserting argo workflow template: %w", err)
 	}
@@ -82,15 +108,20 @@ func (r *WorkflowTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Req
 	return ctrl.Result{}, nil
 }
 
-func (r *WorkflowTemplateReconciler) updateSecret(cwf *v1alpha1.WorkflowTemplate, secret *corev1.Secret) error {
-	if err := ctrl.SetControllerReference(cwf, secret, r.Scheme); err != nil {
-		return fmt.Errorf("error setting owner reference on connection secret: %w", err)
+func (r *WorkflowTemplateReconciler) updateSecret(ctx context.Context, wft *v1alpha1.WorkflowTemplate, secret *corev1.Secret) (renderedSecret, error) {
+	if err := ctrl.SetControllerReference(wft, secret, r.Scheme); err != nil {
+		return renderedSecret{}, fmt.Errorf("error setting owner reference on connection secret: %w", err)
 	}
-	return nil
+	mode := wft.Spec.WorkflowSpec.GetInjectionMode(r.InjectionMode)
+	return renderConnectionSecret(ctx, r.SecretProvider, v1alpha1.WorkflowKindWorkflowTemplate, mode, wft.Namespace, &wft.Spec.WorkflowSpec, secret)
 }
 
-func (r *WorkflowTemplateReconciler) updateWorkflowTemplate(wft *v1alpha1.WorkflowTemplate, awft *wfv1.WorkflowTemplate) error {
-	awfSpec, err := createArgoWorkflowSpec(wft.Spec.WorkflowSpec, awft.Name, r.ConnectionInjectionImage, awft.Namespace)
+func (r *WorkflowTemplateReconciler) updateWorkflowTemplate(ctx context.Context, wft *v1alpha1.WorkflowTemplate, awft *wfv1.WorkflowTemplate) error {
+	expanded, err := expandFields(ctx, r.Client, wft.Namespace, connectionSecretName(v1alpha1.WorkflowKindWorkflowTemplate, awft.Name), wft.Spec.WorkflowSpec)
+	if err != nil {
+		return fmt.Errorf("error expanding connection fields: %w", err)
+	}
+	awfSpec, err := createArgoWorkflowSpec(wft.Spec.WorkflowSpec, v1alpha1.WorkflowKindWorkflowTemplate, wft.Spec.WorkflowSpec.GetInjectionMode(r.InjectionMode), awft.Name, awft.Namespace, r.InjectionTemplate, expanded)
 	if err != nil {
 		return fmt.Errorf("error creating argo workflow spec: %w", err)
 	}
@@ -103,7 +134,21 @@ func (r *WorkflowTemplateReconciler) updateWorkflowTemplate(wft *v1alpha1.Workfl
 }
 
 func (r *WorkflowTemplateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.Client = mgr.GetClient()
+	r.Scheme = mgr.GetScheme()
+	if r.SecretProvider == nil {
+		r.SecretProvider = provider.NewAccessReviewingSecretProvider(r.Client)
+	}
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor("workflowtemplate-controller")
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.WorkflowTemplate{}).
+		Owns(&wfv1.WorkflowTemplate{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, credentialRolloutHandler(r.Client, r.Log, func() client.ObjectList { return &v1alpha1.WorkflowTemplateList{} }),
+			builder.WithPredicates(credentialsChangedPredicate)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, credentialRolloutHandler(r.Client, r.Log, func() client.ObjectList { return &v1alpha1.WorkflowTemplateList{} }),
+			builder.WithPredicates(credentialsChangedPredicate)).
 		Complete(r)
 }
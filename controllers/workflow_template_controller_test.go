@@ -58,7 +58,7 @@ var _ = Describe("WorkflowTemplateReconciler", func() {
 				InjectableValues: api.InjectableValues{
 					api.InjectableValue{
 						Name:          "injectable-host",
-						ConnectionRef: v1.LocalObjectReference{Name: "default-connection"},
+						ConnectionRef: v1alpha1.ConnectionReference{Name: "default-connection"},
 						Content:       "{{.Host}}",
 						MountPath:     mountPath,
 					},
@@ -146,7 +146,7 @@ var _ = Describe("WorkflowTemplateReconciler", func() {
 				InjectableValues: api.InjectableValues{
 					api.InjectableValue{
 						Name:          "injectable-host",
-						ConnectionRef: v1.LocalObjectReference{Name: "default-connection"},
+						ConnectionRef: v1alpha1.ConnectionReference{Name: "default-connection"},
 						Content:       "{{.Host}}",
 						EnvName:       "HOST",
 					},
@@ -223,7 +223,7 @@ var _ = Describe("WorkflowTemplateReconciler", func() {
 				InjectableValues: api.InjectableValues{
 					api.InjectableValue{
 						Name:          "injectable-host",
-						ConnectionRef: v1.LocalObjectReference{Name: "default-connection"},
+						ConnectionRef: v1alpha1.ConnectionReference{Name: "default-connection"},
 						Content:       "{{.Host}}",
 						EnvName:       "HOST",
 					},
@@ -270,7 +270,7 @@ var _ = Describe("WorkflowTemplateReconciler", func() {
 				InjectableValues: api.InjectableValues{
 					api.InjectableValue{
 						Name:          "injectable-host",
-						ConnectionRef: v1.LocalObjectReference{Name: "default-connection"},
+						ConnectionRef: v1alpha1.ConnectionReference{Name: "default-connection"},
 						Content:       "{{.Host}}",
 						EnvName:       "HOST",
 					},
@@ -4,12 +4,15 @@ import (
 	// load authentication plugin for obtaining credentials from cloud providers.
 	"fmt"
 
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/dataworkz/kubeetl/api/v1alpha1"
 	"github.com/dataworkz/kubeetl/internal/provider"
+	"github.com/dataworkz/kubeetl/pkg/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -27,6 +30,10 @@ const (
 var (
 	workflow  string
 	namespace string
+	kind      string
+	run       string
+
+	secretBackendConfig string
 )
 
 func init() {
@@ -42,26 +49,43 @@ func initConfig() {
 
 func NewInjectionCommand() *cobra.Command {
 	command := &cobra.Command{
-		Use:   fmt.Sprintf("%s --workflow <workflow-name> --namespace <workflow-namespace>", CLIName),
+		Use:   fmt.Sprintf("%s --workflow <workflow-name> --namespace <workflow-namespace> [--kind <kind>] [--run <run-name>]", CLIName),
 		Short: fmt.Sprintf("%s provides injectable secrets for a workflow", CLIName),
 		Run: func(cmd *cobra.Command, args []string) {
 			// creates the in-cluster config
 			config, err := rest.InClusterConfig()
 			er(err)
 
-			scheme, err := v1alpha1.SchemeBuilder.Build()
-			er(err)
+			scheme := runtime.NewScheme()
+			er(clientgoscheme.AddToScheme(scheme))
+			er(v1alpha1.AddToScheme(scheme))
 
 			client, err := client.New(config, client.Options{Scheme: scheme})
 			er(err)
 
+			if secretBackendConfig != "" {
+				er(util.LoadSecretBackends(secretBackendConfig))
+			}
+
 			p := provider.NewSecretProvider(client)
 
-			err = p.ProvideWorkflowSecret(workflow, namespace)
+			if run != "" {
+				err = p.ProvideRunSecret(v1alpha1.WorkflowKind(kind), workflow, run, namespace)
+			} else {
+				err = p.ProvideSecret(v1alpha1.WorkflowKind(kind), workflow, namespace)
+			}
 			er(err)
 		},
 	}
 
+	command.Flags().StringVar(&workflow, "workflow", "", "Name of the resource to provide the connection secret for.")
+	command.Flags().StringVar(&namespace, "namespace", "", "Namespace of the resource to provide the connection secret for.")
+	command.Flags().StringVar(&kind, "kind", string(v1alpha1.WorkflowKindWorkflow), "Kind of the resource: Workflow, CronWorkflow or WorkflowTemplate.")
+	command.Flags().StringVar(&run, "run", "", "Name of the Argo Workflow run to provide a run-scoped connection secret for.")
+	command.Flags().StringVar(&secretBackendConfig, "secret-backend-config", "", "Path to a YAML file with the configuration of the external secret backends ValueSources can refer to.")
+	_ = command.MarkFlagRequired("workflow")
+	_ = command.MarkFlagRequired("namespace")
+
 	return command
 }
 
@@ -2,20 +2,26 @@ package commands
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 
+	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
 	etldataworkznlv1alpha1 "github.com/dataworkz/kubeetl/api/v1alpha1"
 	etlv1alpha1 "github.com/dataworkz/kubeetl/api/v1alpha1"
 	etlhooks "github.com/dataworkz/kubeetl/api/v1alpha1/webhooks"
 	"github.com/dataworkz/kubeetl/controllers"
+	"github.com/dataworkz/kubeetl/listers"
 	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/yaml"
 	// +kubebuilder:scaffold:imports
 
 	"github.com/dataworkz/kubeetl/pkg/manager"
+	"github.com/dataworkz/kubeetl/pkg/util"
 )
 
 var (
@@ -24,7 +30,7 @@ var (
 
 const (
 	ManagerCommand = "manager"
-	// TODO make configurable
+	// DockerImage is the default image of the injection container
 	DockerImage = "ghcr.io/dataworkz-nl/kubeetl:main"
 )
 
@@ -32,6 +38,11 @@ type managerConfig struct {
 	metricsAddr          string
 	enableLeaderElection bool
 	webhooksEnabled      bool
+	injectionMode        string
+	injectionConfig      string
+	injectionImage       string
+	injectionPullPolicy  string
+	secretBackendConfig  string
 }
 
 func NewManagerCommand() *cobra.Command {
@@ -47,32 +58,115 @@ func NewManagerCommand() *cobra.Command {
 	cmd.Flags().StringVar(&config.metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	cmd.Flags().BoolVar(&config.enableLeaderElection, "enable-leader-election", false, "Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
 	cmd.Flags().BoolVar(&config.webhooksEnabled, "webhooks-enabled", false, "Enable validating webhooks for KubeETL.")
+	cmd.Flags().StringVar(&config.injectionConfig, "injection-config", "", "Path to a YAML file, usually mounted from a ConfigMap, with the default injection container configuration.")
+	cmd.Flags().StringVar(&config.injectionImage, "injection-image", "", fmt.Sprintf("Image of the injection container, overrides the injection config (default %s).", DockerImage))
+	cmd.Flags().StringVar(&config.injectionPullPolicy, "injection-image-pull-policy", "", "Pull policy of the injection container, overrides the injection config.")
+	cmd.Flags().StringVar(&config.injectionMode, "injection-mode", string(etlv1alpha1.InjectionModeDaemon), "Default injection mode for Workflows: Daemon populates connection secrets from a step in the Argo Workflow, Controller lets the manager populate them.")
+	cmd.Flags().StringVar(&config.secretBackendConfig, "secret-backend-config", "", "Path to a YAML file with the configuration of the external secret backends ValueSources can refer to.")
 
 	return cmd
 }
 
 func (c *managerConfig) run() {
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+	injectionMode, err := etlv1alpha1.ParseInjectionMode(c.injectionMode)
+	if err != nil {
+		setupLog.Error(err, "invalid injection mode")
+		os.Exit(1)
+	}
+
+	injection, err := c.injectionTemplate()
+	if err != nil {
+		setupLog.Error(err, "invalid injection config")
+		os.Exit(1)
+	}
+
+	if c.secretBackendConfig != "" {
+		if err := util.LoadSecretBackends(c.secretBackendConfig); err != nil {
+			setupLog.Error(err, "invalid secret backend config")
+			os.Exit(1)
+		}
+	}
+
 	cm := manager.New(
 		manager.WithMetricsAddress(c.metricsAddr),
 		manager.WithLeaderElection(c.enableLeaderElection),
 		manager.WithWebhooksEnabled(c.webhooksEnabled),
 		manager.WithSchemas(
 			clientgoscheme.AddToScheme,
+			wfv1.AddToScheme,
 			etlv1alpha1.AddToScheme,
 			etldataworkznlv1alpha1.AddToScheme,
 		),
 		manager.WithWebhooks(
 			etlhooks.SetupValidatingConnectionWebhookWithManager,
 			etlhooks.SetupValidatingDataSetWebhookWithManager,
+			etlhooks.SetupValidatingWorkflowWebhookWithManager,
+			etlhooks.SetupValidatingConnectionTypeWebhookWithManager,
+			etlhooks.SetupValidatingDataSetTypeWebhookWithManager,
+			etlhooks.SetupMutatingConnectionWebhookWithManager,
+			etlhooks.SetupMutatingDataSetWebhookWithManager,
+		),
+		manager.WithIndexes(
+			controllers.SetupIndexes,
+			listers.SetupIndexes,
 		),
 		manager.WithReconcilers(
+			(&controllers.ConnectionReconciler{
+				Log: ctrl.Log.WithName("controllers").WithName("Connection"),
+			}).SetupWithManager,
 			(&controllers.DataSetReconciler{
 				Log: ctrl.Log.WithName("controllers").WithName("DataSet"),
 			}).SetupWithManager,
+			(&controllers.InUseProtectionReconciler{
+				Log:  ctrl.Log.WithName("controllers").WithName("ConnectionProtection"),
+				Kind: "Connection",
+			}).SetupWithManager,
+			(&controllers.InUseProtectionReconciler{
+				Log:  ctrl.Log.WithName("controllers").WithName("DataSetProtection"),
+				Kind: "DataSet",
+			}).SetupWithManager,
+			(&controllers.InUseProtectionReconciler{
+				Log:  ctrl.Log.WithName("controllers").WithName("ConnectionTypeProtection"),
+				Kind: "ConnectionType",
+			}).SetupWithManager,
+			(&controllers.InUseProtectionReconciler{
+				Log:  ctrl.Log.WithName("controllers").WithName("DataSetTypeProtection"),
+				Kind: "DataSetType",
+			}).SetupWithManager,
+			(&controllers.InUseProtectionReconciler{
+				Log:  ctrl.Log.WithName("controllers").WithName("ClusterConnectionTypeProtection"),
+				Kind: "ClusterConnectionType",
+			}).SetupWithManager,
+			(&controllers.InUseProtectionReconciler{
+				Log:  ctrl.Log.WithName("controllers").WithName("ClusterDataSetTypeProtection"),
+				Kind: "ClusterDataSetType",
+			}).SetupWithManager,
+			(&controllers.UsageReconciler{
+				Log:  ctrl.Log.WithName("controllers").WithName("ConnectionUsage"),
+				Kind: "Connection",
+			}).SetupWithManager,
+			(&controllers.UsageReconciler{
+				Log:  ctrl.Log.WithName("controllers").WithName("DataSetUsage"),
+				Kind: "DataSet",
+			}).SetupWithManager,
 			(&controllers.WorkflowReconciler{
-				Log:                      ctrl.Log.WithName("controllers").WithName("Workflow"),
-				ConnectionInjectionImage: DockerImage,
+				Log:               ctrl.Log.WithName("controllers").WithName("Workflow"),
+				InjectionTemplate: injection,
+				InjectionMode:     injectionMode,
+			}).SetupWithManager,
+			(&controllers.CronWorkflowReconciler{
+				Log:               ctrl.Log.WithName("controllers").WithName("CronWorkflow"),
+				InjectionTemplate: injection,
+				InjectionMode:     injectionMode,
+			}).SetupWithManager,
+			(&controllers.WorkflowTemplateReconciler{
+				Log:               ctrl.Log.WithName("controllers").WithName("WorkflowTemplate"),
+				InjectionTemplate: injection,
+				InjectionMode:     injectionMode,
+			}).SetupWithManager,
+			(&controllers.CredentialLeaseReconciler{
+				Log: ctrl.Log.WithName("controllers").WithName("CredentialLease"),
 			}).SetupWithManager,
 		),
 	)
@@ -84,3 +178,34 @@ func (c *managerConfig) run() {
 		os.Exit(1)
 	}
 }
+
+// injectionTemplate loads the default injection container configuration from the injection config file
+// and applies the injection flags on top of it.
+func (c *managerConfig) injectionTemplate() (etlv1alpha1.InjectionTemplate, error) {
+	var it etlv1alpha1.InjectionTemplate
+	if c.injectionConfig != "" {
+		data, err := ioutil.ReadFile(c.injectionConfig)
+		if err != nil {
+			return it, fmt.Errorf("unable to read injection config: %w", err)
+		}
+		if err := yaml.UnmarshalStrict(data, &it); err != nil {
+			return it, fmt.Errorf("unable to parse injection config: %w", err)
+		}
+	}
+
+	it = it.Merge(&etlv1alpha1.InjectionTemplate{
+		Image:           c.injectionImage,
+		ImagePullPolicy: corev1.PullPolicy(c.injectionPullPolicy),
+	})
+	if it.Image == "" {
+		it.Image = DockerImage
+	}
+
+	switch it.ImagePullPolicy {
+	case "", corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever:
+	default:
+		return it, fmt.Errorf("unknown image pull policy %q", it.ImagePullPolicy)
+	}
+
+	return it, nil
+}
@@ -0,0 +1,125 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/pkg/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// denyingReviewer denies access to a single resource and records the reviewed service accounts
+type denyingReviewer struct {
+	resource        string
+	serviceAccounts []string
+}
+
+func (r *denyingReviewer) ReviewAccess(ctx context.Context, namespace, serviceAccount string, attributes authorizationv1.ResourceAttributes) error {
+	r.serviceAccounts = append(r.serviceAccounts, serviceAccount)
+	if attributes.Resource == r.resource {
+		return &util.AccessDeniedError{ServiceAccount: serviceAccount, Namespace: namespace, Attributes: attributes}
+	}
+	return nil
+}
+
+var _ = Describe("Access reviewing SecretProvider", func() {
+	var sp *secretProvider
+	var reviewer *denyingReviewer
+	var wfs *v1alpha1.WorkflowSpec
+
+	BeforeEach(func() {
+		s := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(s)).To(Succeed())
+		Expect(v1alpha1.AddToScheme(s)).To(Succeed())
+
+		secret := &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{Name: "review-secret", Namespace: "default"},
+			Data:       map[string][]byte{"password": []byte("secret-value")},
+		}
+		conn := &v1alpha1.Connection{
+			ObjectMeta: v1.ObjectMeta{Name: "review-connection", Namespace: "default"},
+			Spec: v1alpha1.ConnectionSpec{
+				Credentials: v1alpha1.Credentials{
+					"password": v1alpha1.Value{ValueFrom: &v1alpha1.ValueSource{
+						SecretKeyRef: &corev1.SecretKeySelector{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+							Key:                  "password",
+						},
+					}},
+				},
+			},
+		}
+		sharedSecret := secret.DeepCopy()
+		sharedSecret.Namespace = "shared"
+		sharedConn := conn.DeepCopy()
+		sharedConn.Namespace = "shared"
+		grant := &v1alpha1.ConnectionGrant{
+			ObjectMeta: v1.ObjectMeta{Name: "review-grant", Namespace: "shared"},
+			Spec: v1alpha1.ConnectionGrantSpec{
+				From: []v1alpha1.ConnectionGrantFrom{{Namespace: "default", ServiceAccounts: []string{"injector"}}},
+				To:   []v1alpha1.ConnectionGrantTo{{Name: sharedConn.Name}},
+			},
+		}
+		client := fake.NewClientBuilder().WithScheme(s).WithObjects(secret, conn, sharedSecret, sharedConn, grant).Build()
+
+		reviewer = &denyingReviewer{}
+		sp = NewSecretProvider(client).(*secretProvider)
+		sp.accessReviewer = reviewer
+
+		wfs = &v1alpha1.WorkflowSpec{
+			InjectionServiceAccount: "injector",
+			InjectableValues: v1alpha1.InjectableValues{
+				{
+					Name:          "password",
+					Content:       "{{.password}}",
+					ConnectionRef: v1alpha1.ConnectionReference{Name: conn.Name},
+				},
+			},
+		}
+	})
+
+	It("Should render the secret if the injection service account is allowed to read all dependencies", func() {
+		data, _, err := sp.RenderSecretData(context.Background(), v1alpha1.WorkflowKindWorkflow, "default", wfs)
+		Expect(err).To(Succeed())
+		Expect(string(data["password"])).To(Equal("secret-value"))
+		Expect(reviewer.serviceAccounts).To(ConsistOf("injector", "injector"))
+	})
+
+	It("Should fail with an AccessDeniedError if the Connection may not be read", func() {
+		reviewer.resource = "connections"
+		_, _, err := sp.RenderSecretData(context.Background(), v1alpha1.WorkflowKindWorkflow, "default", wfs)
+		Expect(util.IsAccessDenied(err)).To(BeTrue())
+	})
+
+	It("Should fail with an AccessDeniedError if a Secret of the Connection may not be read", func() {
+		reviewer.resource = "secrets"
+		_, _, err := sp.RenderSecretData(context.Background(), v1alpha1.WorkflowKindWorkflow, "default", wfs)
+		Expect(util.IsAccessDenied(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("service account default/injector is not allowed to get secrets default/review-secret"))
+	})
+
+	It("Should not review access to a Connection in another namespace that a ConnectionGrant allows the service account to consume", func() {
+		reviewer.resource = "secrets"
+		wfs.InjectableValues[0].ConnectionRef.Namespace = "shared"
+		data, _, err := sp.RenderSecretData(context.Background(), v1alpha1.WorkflowKindWorkflow, "default", wfs)
+		Expect(err).To(Succeed())
+		Expect(string(data["password"])).To(Equal("secret-value"))
+		Expect(reviewer.serviceAccounts).To(BeEmpty())
+	})
+
+	It("Should fail if no ConnectionGrant allows the service account to consume a Connection in another namespace", func() {
+		wfs.InjectionServiceAccount = "other"
+		wfs.InjectableValues[0].ConnectionRef.Namespace = "shared"
+		_, _, err := sp.RenderSecretData(context.Background(), v1alpha1.WorkflowKindWorkflow, "default", wfs)
+		Expect(err).To(MatchError(ContainSubstring("Connection shared/review-connection is not granted to service account other in namespace default")))
+		Expect(reviewer.serviceAccounts).To(BeEmpty())
+	})
+})
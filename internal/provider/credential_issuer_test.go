@@ -0,0 +1,165 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/pkg/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// stubIssuer issues a numbered password and records the requested TTLs and revoked leases
+type stubIssuer struct {
+	ttls    []time.Duration
+	revoked []string
+	fail    bool
+}
+
+func (i *stubIssuer) Issue(ctx context.Context, ttl time.Duration) (*util.IssuedCredentials, error) {
+	if i.fail {
+		return nil, errors.New("issuer unavailable")
+	}
+	i.ttls = append(i.ttls, ttl)
+	return &util.IssuedCredentials{
+		Values:  map[string]string{"password": "issued-password"},
+		LeaseID: "lease-1",
+		TTL:     ttl,
+	}, nil
+}
+
+func (i *stubIssuer) Revoke(ctx context.Context, leaseID string) error {
+	i.revoked = append(i.revoked, leaseID)
+	return nil
+}
+
+var _ = Describe("SecretProvider with a CredentialIssuer", func() {
+	var sp *secretProvider
+	var issuer *stubIssuer
+	var wfs *v1alpha1.WorkflowSpec
+	var cl client.Client
+
+	BeforeEach(func() {
+		s := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(s)).To(Succeed())
+		Expect(v1alpha1.AddToScheme(s)).To(Succeed())
+
+		conn := &v1alpha1.Connection{
+			ObjectMeta: v1.ObjectMeta{Name: "issuing-connection", Namespace: "default"},
+			Spec: v1alpha1.ConnectionSpec{
+				Credentials: v1alpha1.Credentials{
+					"username": v1alpha1.Value{Value: "etl"},
+				},
+				CredentialIssuer: &v1alpha1.CredentialIssuer{
+					Fields:  []string{"password"},
+					Webhook: &v1alpha1.WebhookCredentialIssuer{URL: "https://issuer.example.com"},
+				},
+			},
+		}
+		cl = fake.NewClientBuilder().WithScheme(s).WithObjects(conn).Build()
+
+		util.SetCredentialIssuerRules([]util.CredentialIssuerRule{
+			{Namespaces: []string{"default"}, WebhookURLs: []string{"https://issuer.example.com"}},
+		})
+		issuer = &stubIssuer{}
+		sp = NewSecretProvider(cl).(*secretProvider)
+		sp.newCredentialIssuer = func(client.Client, *v1alpha1.Connection) (util.CredentialIssuer, error) {
+			return issuer, nil
+		}
+
+		deadline := int64(600)
+		wfs = &v1alpha1.WorkflowSpec{
+			InjectableValues: v1alpha1.InjectableValues{
+				{
+					Name:          "dsn",
+					Content:       "{{.username}}:{{.password}}",
+					ConnectionRef: v1alpha1.ConnectionReference{Name: conn.Name},
+				},
+				{
+					Name:          "password",
+					Content:       "{{.password}}",
+					ConnectionRef: v1alpha1.ConnectionReference{Name: conn.Name},
+				},
+			},
+			ArgoWorkflowSpec: wfv1.WorkflowSpec{ActiveDeadlineSeconds: &deadline},
+		}
+	})
+
+	AfterEach(func() {
+		util.SetCredentialIssuerRules(nil)
+	})
+
+	It("Should issue credentials once for the active deadline of the workflow and return their lease", func() {
+		data, leases, err := sp.RenderSecretData(context.Background(), v1alpha1.WorkflowKindWorkflow, "default", wfs)
+		Expect(err).To(Succeed())
+		Expect(string(data["dsn"])).To(Equal("etl:issued-password"))
+		Expect(string(data["password"])).To(Equal("issued-password"))
+		Expect(issuer.ttls).To(Equal([]time.Duration{10 * time.Minute}))
+
+		Expect(leases).To(HaveLen(1))
+		Expect(leases[0].Name).To(Equal("issuing-connection"))
+		Expect(leases[0].LeaseID).To(Equal("lease-1"))
+	})
+
+	It("Should revoke issued credentials if the secret cannot be rendered", func() {
+		wfs.InjectableValues[1].Content = "{{.password"
+		_, _, err := sp.RenderSecretData(context.Background(), v1alpha1.WorkflowKindWorkflow, "default", wfs)
+		Expect(err).To(HaveOccurred())
+		Expect(issuer.revoked).To(Equal([]string{"lease-1"}))
+	})
+
+	It("Should fail if credentials cannot be issued", func() {
+		issuer.fail = true
+		_, _, err := sp.RenderSecretData(context.Background(), v1alpha1.WorkflowKindWorkflow, "default", wfs)
+		Expect(err).To(MatchError(ContainSubstring("issuer unavailable")))
+	})
+
+	It("Should not issue credentials from an issuer the operator does not allow", func() {
+		util.SetCredentialIssuerRules([]util.CredentialIssuerRule{
+			{Namespaces: []string{"other"}, WebhookURLs: []string{"https://issuer.example.com"}},
+		})
+		_, _, err := sp.RenderSecretData(context.Background(), v1alpha1.WorkflowKindWorkflow, "default", wfs)
+		Expect(err).To(MatchError(ContainSubstring("not allowed to issue credentials from https://issuer.example.com")))
+		Expect(issuer.ttls).To(BeEmpty())
+	})
+
+	It("Should not issue credentials for a WorkflowTemplate", func() {
+		_, _, err := sp.RenderSecretData(context.Background(), v1alpha1.WorkflowKindWorkflowTemplate, "default", wfs)
+		Expect(err).To(MatchError(ContainSubstring("which WorkflowTemplates do not support")))
+		Expect(issuer.ttls).To(BeEmpty())
+	})
+
+	It("Should render issued fields that are expanded into the connection secret", func() {
+		ctx := context.Background()
+		conType := &v1alpha1.ConnectionType{
+			ObjectMeta: v1.ObjectMeta{Name: "issuing-type", Namespace: "default"},
+			Spec: v1alpha1.ConnectionTypeSpec{
+				Fields: []v1alpha1.CredentialFieldSpec{
+					{Name: "username", EnvKey: "DB_USER"},
+					{Name: "password", EnvKey: "DB_PASSWORD", Sensitive: true},
+				},
+			},
+		}
+		Expect(cl.Create(ctx, conType)).To(Succeed())
+		var conn v1alpha1.Connection
+		Expect(cl.Get(ctx, client.ObjectKey{Name: "issuing-connection", Namespace: "default"}, &conn)).To(Succeed())
+		conn.Spec.Type = conType.Name
+		Expect(cl.Update(ctx, &conn)).To(Succeed())
+
+		wfs.InjectableValues = v1alpha1.InjectableValues{
+			{Name: "database", ConnectionRef: v1alpha1.ConnectionReference{Name: conn.Name}, ExpandFields: true},
+		}
+		data, _, err := sp.RenderSecretData(ctx, v1alpha1.WorkflowKindWorkflow, "default", wfs)
+		Expect(err).To(Succeed())
+		Expect(data).To(Equal(map[string][]byte{"database.password": []byte("issued-password")}))
+	})
+})
@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+	"github.com/dataworkz/kubeetl/pkg/util"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("SecretProvider with an ExternalRef", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "secret-backends")
+		Expect(err).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(dir, "secrets", "default", "mysql"), 0700)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "secrets", "default", "mysql", "password"), []byte("s3cr3t"), 0600)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("Should render an ExternalRef with the secret backend config of the injection container", func() {
+		By("Loading the mounted secret backend config like the injection command in InjectionModeDaemon")
+		config := filepath.Join(dir, v1alpha1.SecretBackendConfigKey)
+		Expect(ioutil.WriteFile(config, []byte("file:\n  root: "+filepath.Join(dir, "secrets")+"\n"), 0600)).To(Succeed())
+		Expect(util.LoadSecretBackends(config)).To(Succeed())
+
+		s := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(s)).To(Succeed())
+		Expect(v1alpha1.AddToScheme(s)).To(Succeed())
+		conn := &v1alpha1.Connection{
+			ObjectMeta: v1.ObjectMeta{Name: "external-connection", Namespace: "default"},
+			Spec: v1alpha1.ConnectionSpec{
+				Credentials: v1alpha1.Credentials{
+					"password": v1alpha1.Value{ValueFrom: &v1alpha1.ValueSource{
+						ExternalRef: &v1alpha1.ExternalSecretKeySelector{Backend: v1alpha1.SecretBackendFile, Path: "mysql", Key: "password"},
+					}},
+				},
+			},
+		}
+		sp := NewSecretProvider(fake.NewClientBuilder().WithScheme(s).WithObjects(conn).Build())
+
+		wfs := &v1alpha1.WorkflowSpec{
+			InjectionMode: v1alpha1.InjectionModeDaemon,
+			InjectableValues: v1alpha1.InjectableValues{
+				{
+					Name:          "password",
+					Content:       "{{.password}}",
+					ConnectionRef: v1alpha1.ConnectionReference{Name: conn.Name},
+				},
+			},
+		}
+		data, _, err := sp.RenderSecretData(context.Background(), v1alpha1.WorkflowKindWorkflow, "default", wfs)
+		Expect(err).To(Succeed())
+		Expect(string(data["password"])).To(Equal("s3cr3t"))
+	})
+})
@@ -3,121 +3,385 @@ package provider
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/dataworkz/kubeetl/api/v1alpha1"
 	"github.com/dataworkz/kubeetl/listers"
 	"github.com/dataworkz/kubeetl/pkg/util"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+const (
+	// runSecretPollInterval is the interval at which the provider checks whether a run secret has been created
+	runSecretPollInterval = 2 * time.Second
+	// runSecretTimeout is the maximum time the provider waits for the controller to create a run secret
+	runSecretTimeout = time.Minute
+)
+
 type SecretProvider interface {
+	// ProvideWorkflowSecret populates the connection secret of a Workflow
 	ProvideWorkflowSecret(workflowName, workflowNamespace string) error
+
+	// ProvideSecret populates the connection secret of a Workflow, CronWorkflow or WorkflowTemplate
+	ProvideSecret(kind v1alpha1.WorkflowKind, name, namespace string) error
+
+	// ProvideRunSecret populates the connection secret of a single Argo Workflow run spawned from the given resource.
+	// The run secret is created by the controller, so ProvideRunSecret waits until it exists.
+	ProvideRunSecret(kind v1alpha1.WorkflowKind, name, run, namespace string) error
+
+	// RenderSecretData renders the InjectableValues of the WorkflowSpec of a resource of the given kind
+	// into connection secret data, together with the leases of the credentials issued for it
+	RenderSecretData(ctx context.Context, kind v1alpha1.WorkflowKind, namespace string, wfs *v1alpha1.WorkflowSpec) (map[string][]byte, []v1alpha1.CredentialLease, error)
+
+	// RevokeLeases revokes the credentials issued by RenderSecretData if the secret they were rendered into
+	// could not be stored, as only the leases recorded on a stored secret are revoked when it is no longer used
+	RevokeLeases(ctx context.Context, leases []v1alpha1.CredentialLease)
 }
 
 func NewSecretProvider(client client.Client) SecretProvider {
 	return &secretProvider{
-		client:           client,
-		workflowLister:   listers.NewWorkflowLister(client),
-		connectionLister: listers.NewConnectionLister(client),
-		datasetLister:    listers.NewDataSetLister(client),
+		client:                 client,
+		workflowLister:         listers.NewWorkflowLister(client),
+		cronWorkflowLister:     listers.NewCronWorkflowLister(client),
+		workflowTemplateLister: listers.NewWorkflowTemplateLister(client),
+		connectionLister:       listers.NewConnectionLister(client),
+		connectionGrantLister:  listers.NewConnectionGrantLister(client),
+		connectionTypeLister:   listers.NewConnectionTypeLister(client),
+		datasetLister:          listers.NewDataSetLister(client),
+		dataSetTypeLister:      listers.NewDataSetTypeLister(client),
+		newCredentialIssuer:    util.NewCredentialIssuer,
 	}
 }
 
+// NewAccessReviewingSecretProvider returns a SecretProvider that only dereferences the Connections, DataSets,
+// Secrets and ConfigMaps the injection service account of a WorkflowSpec is allowed to get.
+// It is meant for the reconcilers, which read with credentials other than those of the injection service account
+// from the cache of a manager on which the indexes of listers.SetupIndexes are registered.
+func NewAccessReviewingSecretProvider(client client.Client) SecretProvider {
+	sp := NewSecretProvider(client).(*secretProvider)
+	sp.accessReviewer = util.NewSubjectAccessReviewer(client)
+	sp.connectionGrantLister = listers.NewIndexedConnectionGrantLister(client)
+	return sp
+}
+
 type secretProvider struct {
-	client           client.Client
-	workflowLister   listers.WorkflowLister
-	connectionLister listers.ConnectionLister
-	datasetLister    listers.DataSetLister
+	client                 client.Client
+	workflowLister         listers.WorkflowLister
+	cronWorkflowLister     listers.CronWorkflowLister
+	workflowTemplateLister listers.WorkflowTemplateLister
+	connectionLister       listers.ConnectionLister
+	connectionGrantLister  listers.ConnectionGrantLister
+	connectionTypeLister   listers.ConnectionTypeLister
+	datasetLister          listers.DataSetLister
+	dataSetTypeLister      listers.DataSetTypeLister
+	accessReviewer         util.AccessReviewer
+	newCredentialIssuer    func(client.Client, *v1alpha1.Connection) (util.CredentialIssuer, error)
 }
 
-func (cp *secretProvider) ProvideWorkflowSecret(workflowName, workflowNamespace string) error {
-	ctx := context.Background()
-	wf, err := cp.workflowLister.Find(ctx, workflowNamespace, workflowName)
-	if err != nil {
-		return fmt.Errorf("failed to find workflow with name %s: %w", workflowName, err)
+// issuance tracks the credentials issued while rendering a connection secret,
+// so a Connection that is injected several times is only issued credentials once
+type issuance struct {
+	kind   v1alpha1.WorkflowKind
+	wfs    *v1alpha1.WorkflowSpec
+	values map[types.NamespacedName]map[string]string
+	leases []v1alpha1.CredentialLease
+}
+
+func newIssuance(kind v1alpha1.WorkflowKind, wfs *v1alpha1.WorkflowSpec) *issuance {
+	return &issuance{
+		kind:   kind,
+		wfs:    wfs,
+		values: make(map[types.NamespacedName]map[string]string),
 	}
+}
+
+func (cp *secretProvider) ProvideWorkflowSecret(workflowName, workflowNamespace string) error {
+	return cp.ProvideSecret(v1alpha1.WorkflowKindWorkflow, workflowName, workflowNamespace)
+}
 
+func (cp *secretProvider) ProvideSecret(kind v1alpha1.WorkflowKind, name, namespace string) error {
+	ctx := context.Background()
 	secret := corev1.Secret{}
-	m := v1alpha1.ConnectionSecret(wf.Namespace, wf.Name).ObjectMeta
+	m := v1alpha1.ConnectionSecret(name, namespace).ObjectMeta
 	if err := cp.client.Get(ctx, types.NamespacedName{Name: m.Name, Namespace: m.Namespace}, &secret); err != nil {
 		return fmt.Errorf("failed to find connection secret with name %s: %w", m.Name, err)
 	}
 
-	if err := cp.populateSecret(ctx, &secret, wf); err != nil {
+	return cp.provide(ctx, kind, name, namespace, &secret)
+}
+
+func (cp *secretProvider) ProvideRunSecret(kind v1alpha1.WorkflowKind, name, run, namespace string) error {
+	ctx := context.Background()
+	secret := corev1.Secret{}
+	m := v1alpha1.RunConnectionSecret(run, namespace).ObjectMeta
+	err := wait.PollImmediate(runSecretPollInterval, runSecretTimeout, func() (bool, error) {
+		err := cp.client.Get(ctx, types.NamespacedName{Name: m.Name, Namespace: m.Namespace}, &secret)
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find run connection secret with name %s: %w", m.Name, err)
+	}
+
+	// A run renders its credentials once, so restarts of the injection step do not change them mid-run
+	if len(secret.Data) > 0 {
+		return nil
+	}
+
+	return cp.provide(ctx, kind, name, namespace, &secret)
+}
+
+// issuedBefore returns true if credentials have been issued for the secret, which is then never rendered again
+func issuedBefore(secret *corev1.Secret) bool {
+	_, ok := secret.Annotations[v1alpha1.CredentialLeasesAnnotation]
+	return ok
+}
+
+// provide populates the secret with the InjectableValues of the resource of the given kind
+func (cp *secretProvider) provide(ctx context.Context, kind v1alpha1.WorkflowKind, name, namespace string, secret *corev1.Secret) error {
+	if issuedBefore(secret) {
+		return nil
+	}
+
+	wfs, err := cp.findWorkflowSpec(ctx, kind, name, namespace)
+	if err != nil {
+		return fmt.Errorf("failed to find %s with name %s: %w", kind, name, err)
+	}
+
+	if err := cp.populateSecret(ctx, secret, kind, namespace, wfs); err != nil {
 		return fmt.Errorf("failed to populate connection secret: %w", err)
 	}
 
-	if err := cp.client.Update(ctx, &secret); err != nil {
+	if err := cp.client.Update(ctx, secret); err != nil {
+		leases, _ := v1alpha1.GetCredentialLeases(secret)
+		cp.RevokeLeases(ctx, leases)
 		return fmt.Errorf("failed to update connection secret: %w", err)
 	}
 
 	return nil
 }
 
-// populateSecret renders the template for each InjectableValue in a Workflow and adds the result to the provided secret
-// using the name of the InjectableValue as a key
-func (cp *secretProvider) populateSecret(ctx context.Context, secret *corev1.Secret, wf *v1alpha1.Workflow) error {
+func (cp *secretProvider) RenderSecretData(ctx context.Context, kind v1alpha1.WorkflowKind, namespace string, wfs *v1alpha1.WorkflowSpec) (map[string][]byte, []v1alpha1.CredentialLease, error) {
+	var secret corev1.Secret
+	if err := cp.populateSecret(ctx, &secret, kind, namespace, wfs); err != nil {
+		return nil, nil, fmt.Errorf("failed to render connection secret: %w", err)
+	}
+
+	data := make(map[string][]byte, len(secret.StringData))
+	for k, v := range secret.StringData {
+		data[k] = []byte(v)
+	}
+	leases, err := v1alpha1.GetCredentialLeases(&secret)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, leases, nil
+}
+
+// findWorkflowSpec returns the WorkflowSpec embedded in the resource of the given kind
+func (cp *secretProvider) findWorkflowSpec(ctx context.Context, kind v1alpha1.WorkflowKind, name, namespace string) (*v1alpha1.WorkflowSpec, error) {
+	switch kind {
+	case v1alpha1.WorkflowKindWorkflow:
+		wf, err := cp.workflowLister.Find(ctx, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		return &wf.Spec, nil
+	case v1alpha1.WorkflowKindCronWorkflow:
+		cwf, err := cp.cronWorkflowLister.Find(ctx, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		return &cwf.Spec.WorkflowSpec, nil
+	case v1alpha1.WorkflowKindWorkflowTemplate:
+		wft, err := cp.workflowTemplateLister.Find(ctx, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		return &wft.Spec.WorkflowSpec, nil
+	}
+
+	return nil, fmt.Errorf("unsupported kind %s", kind)
+}
+
+// populateSecret renders the templates of each InjectableValue in a WorkflowSpec and adds the results to the provided secret
+// using the keys returned by InjectableValue.Templates. The leases of issued credentials are recorded on the secret,
+// or revoked if the secret cannot be rendered.
+func (cp *secretProvider) populateSecret(ctx context.Context, secret *corev1.Secret, kind v1alpha1.WorkflowKind, namespace string, wfs *v1alpha1.WorkflowSpec) error {
+	is := newIssuance(kind, wfs)
+	if err := cp.renderInjectableValues(ctx, secret, namespace, is); err != nil {
+		cp.RevokeLeases(ctx, is.leases)
+		return err
+	}
+	if err := v1alpha1.SetCredentialLeases(secret, is.leases); err != nil {
+		cp.RevokeLeases(ctx, is.leases)
+		return fmt.Errorf("failed to record credential leases: %w", err)
+	}
+	return nil
+}
+
+func (cp *secretProvider) renderInjectableValues(ctx context.Context, secret *corev1.Secret, namespace string, is *issuance) error {
+	wfs := is.wfs
 	secret.StringData = make(map[string]string)
-	for _, iv := range wf.Spec.InjectableValues {
-		if iv.ConnectionRef.Name != "" {
-			content, err := cp.renderConnectionValue(ctx, secret, wf, iv)
-			if err != nil {
+	serviceAccount := wfs.GetInjectionServiceAccount()
+	for _, iv := range wfs.InjectableValues {
+		if iv.ExpandFields {
+			if err := cp.renderExpandedFields(ctx, secret, namespace, serviceAccount, iv, is); err != nil {
 				return err
 			}
-			secret.StringData[iv.Name] = content
+			continue
+		}
+
+		var data interface{}
+		var err error
+		if iv.ConnectionRef.Name != "" {
+			data, err = cp.connectionTemplateData(ctx, namespace, serviceAccount, iv, is)
 		} else if iv.DataSetRef.Name != "" {
-			content, err := cp.renderDataSetValue(ctx, secret, wf, iv)
+			data, err = cp.dataSetTemplateData(ctx, namespace, serviceAccount, iv, is)
+		} else {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for key, ct := range iv.Templates() {
+			content, err := ct.Render(data)
 			if err != nil {
-				return err
+				return fmt.Errorf("failed to render content for InjectableValue %s: %w", iv.Name, err)
 			}
-			secret.StringData[iv.Name] = content
+			secret.StringData[key] = content
 		}
 	}
 
 	return nil
 }
 
-func (cp *secretProvider) renderConnectionValue(ctx context.Context, secret *corev1.Secret, wf *v1alpha1.Workflow, iv v1alpha1.InjectableValue) (string, error) {
-	conn, err := cp.connectionLister.Find(ctx, wf.Namespace, iv.ConnectionRef.Name)
+// renderExpandedFields adds the expanded fields of the Connection that cannot be injected from their sources directly
+// to the secret under their FieldKey. Those are the issued and derived fields and values from external secret backends.
+func (cp *secretProvider) renderExpandedFields(ctx context.Context, secret *corev1.Secret, namespace, serviceAccount string, iv v1alpha1.InjectableValue, is *issuance) error {
+	data, err := cp.connectionTemplateData(ctx, namespace, serviceAccount, iv, is)
 	if err != nil {
-		return "", fmt.Errorf("failed to find Connection %s: %w", iv.ConnectionRef.Name, err)
+		return err
 	}
+	values := data.(map[string]string)
 
-	credValues, err := cp.createCredentialsMap(ctx, conn, iv)
+	key := iv.ConnectionRef.GetNamespacedName(namespace)
+	conn, err := cp.connectionLister.Find(ctx, key.Namespace, key.Name)
+	if err != nil {
+		return fmt.Errorf("failed to find Connection %s: %w", iv.ConnectionRef.Name, err)
+	}
+	conType, err := cp.connectionTypeLister.Find(ctx, conn.Namespace, conn.Spec.Type)
 	if err != nil {
-		return "", err
+		return fmt.Errorf("failed to find ConnectionType %s: %w", conn.Spec.Type, err)
 	}
 
-	content, err := iv.Content.Render(credValues)
+	for _, field := range conn.ExpandedFields(conType.Spec.Fields) {
+		if field.Value == nil {
+			secret.StringData[iv.FieldKey(field.Name)] = values[field.Name]
+		}
+	}
+	return nil
+}
+
+// connectionTemplateData returns the credentials of the Connection referred to by the InjectableValue.
+// A Connection in another namespace is only read if a ConnectionGrant allows the service account to consume it.
+// The ConnectionGrant authorizes reading the Connection and its Secrets and ConfigMaps, so their access is
+// only reviewed for Connections in the namespace of the service account.
+func (cp *secretProvider) connectionTemplateData(ctx context.Context, namespace, serviceAccount string, iv v1alpha1.InjectableValue, is *issuance) (interface{}, error) {
+	key := iv.ConnectionRef.GetNamespacedName(namespace)
+	granted, err := cp.connectionGrantLister.Granted(ctx, key, namespace, serviceAccount)
 	if err != nil {
-		return "", fmt.Errorf("failed to render content for InjectableValue %s: %w", iv.Name, err)
+		return nil, fmt.Errorf("failed to check ConnectionGrants for Connection %s: %w", key, err)
+	}
+	if !granted {
+		return nil, fmt.Errorf("Connection %s is not granted to service account %s in namespace %s", key, serviceAccount, namespace)
+	}
+	local := key.Namespace == namespace
+	if local {
+		if err := cp.reviewAccess(ctx, namespace, serviceAccount, v1alpha1.GroupVersion.Group, "connections", key.Namespace, key.Name); err != nil {
+			return nil, err
+		}
 	}
 
-	return content, nil
+	conn, err := cp.connectionLister.Find(ctx, key.Namespace, key.Name)
+	if errors.IsNotFound(err) {
+		return nil, fmt.Errorf("Connection %s not found", iv.ConnectionRef.Name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find Connection %s: %w", iv.ConnectionRef.Name, err)
+	}
+	if local {
+		if err := cp.reviewCredentialAccess(ctx, namespace, serviceAccount, conn.Namespace, conn.Spec.Credentials); err != nil {
+			return nil, err
+		}
+	}
+
+	return cp.createCredentialsMap(ctx, conn, is)
 }
 
-func (cp *secretProvider) createCredentialsMap(ctx context.Context, conn *v1alpha1.Connection, iv v1alpha1.InjectableValue) (map[string]string, error) {
+func (cp *secretProvider) createCredentialsMap(ctx context.Context, conn *v1alpha1.Connection, is *issuance) (map[string]string, error) {
 	credValues := make(map[string]string, len(conn.Spec.Credentials))
 
 	for name := range conn.Spec.Credentials {
 		credReader := util.NewCredentialReader(cp.client, conn)
 		data, err := credReader.ReadValue(ctx, name)
 		if err != nil {
-			return credValues, fmt.Errorf("failed to read credential value %s in Connection %s: %w", name, iv.ConnectionRef.Name, err)
+			return credValues, fmt.Errorf("failed to read credential value %s in Connection %s: %w", name, conn.Name, err)
 		}
 
 		credValues[name] = data
 	}
 
+	if conn.Spec.CredentialIssuer != nil {
+		issued, err := cp.issueCredentials(ctx, conn, is)
+		if err != nil {
+			return credValues, err
+		}
+		for k, v := range issued {
+			credValues[k] = v
+		}
+	}
+
+	if conn.Spec.Type != "" {
+		conType, err := cp.connectionTypeLister.Find(ctx, conn.Namespace, conn.Spec.Type)
+		if err != nil && !errors.IsNotFound(err) {
+			return credValues, fmt.Errorf("failed to find ConnectionType %s: %w", conn.Spec.Type, err)
+		}
+		if conType != nil {
+			if err := v1alpha1.DeriveValues(conType.Spec.Fields, credValues); err != nil {
+				return credValues, fmt.Errorf("failed to derive credential values in Connection %s: %w", conn.Name, err)
+			}
+		}
+	}
+
 	return credValues, nil
 }
 
-func (cp *secretProvider) renderDataSetValue(ctx context.Context, secret *corev1.Secret, wf *v1alpha1.Workflow, iv v1alpha1.InjectableValue) (string, error) {
-	ds, err := cp.datasetLister.Find(ctx, wf.Namespace, iv.DataSetRef.Name)
+// dataSetTemplateData returns the metadata of the DataSet referred to by the InjectableValue,
+// together with the credentials of its Connection
+func (cp *secretProvider) dataSetTemplateData(ctx context.Context, namespace, serviceAccount string, iv v1alpha1.InjectableValue, is *issuance) (interface{}, error) {
+	if err := cp.reviewAccess(ctx, namespace, serviceAccount, v1alpha1.GroupVersion.Group, "datasets", namespace, iv.DataSetRef.Name); err != nil {
+		return nil, err
+	}
+
+	ds, err := cp.datasetLister.Find(ctx, namespace, iv.DataSetRef.Name)
+	if errors.IsNotFound(err) {
+		return nil, fmt.Errorf("DataSet %s not found", iv.DataSetRef.Name)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to find DataSet %s: %w", iv.DataSetRef.Name, err)
+		return nil, fmt.Errorf("failed to find DataSet %s: %w", iv.DataSetRef.Name, err)
+	}
+	if err := cp.reviewCredentialAccess(ctx, namespace, serviceAccount, ds.Namespace, ds.Spec.Metadata); err != nil {
+		return nil, err
 	}
 
 	credValues := make(map[string]string, len(ds.Spec.Metadata))
@@ -126,33 +390,135 @@ func (cp *secretProvider) renderDataSetValue(ctx context.Context, secret *corev1
 		credReader := util.NewDataSetCredentialReader(cp.client, ds)
 		data, err := credReader.ReadValue(ctx, name)
 		if err != nil {
-			return "", fmt.Errorf("failed to read credential value %s in Connection %s: %w", name, iv.ConnectionRef.Name, err)
+			return nil, fmt.Errorf("failed to read metadata value %s in DataSet %s: %w", name, iv.DataSetRef.Name, err)
 		}
 
 		credValues[name] = data
 	}
 
-	injectedValues := make(map[string]map[string]string)
-	injectedValues["metadata"] = credValues
+	if ds.Spec.Type != "" {
+		dsType, err := cp.dataSetTypeLister.Find(ctx, ds.Namespace, ds.Spec.Type)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to find DataSetType %s: %w", ds.Spec.Type, err)
+		}
+		if dsType != nil {
+			if err := v1alpha1.DeriveValues(dsType.Spec.MetadataFields.Fields, credValues); err != nil {
+				return nil, fmt.Errorf("failed to derive metadata values in DataSet %s: %w", ds.Name, err)
+			}
+		}
+	}
 
+	var connValues map[string]string
 	if ds.Spec.Connection.ConnectionFrom != nil {
-		conn, err := cp.connectionLister.Find(ctx, wf.Namespace, iv.ConnectionRef.Name)
+		connName := ds.Spec.Connection.ConnectionFrom.Name
+		if err := cp.reviewAccess(ctx, namespace, serviceAccount, v1alpha1.GroupVersion.Group, "connections", namespace, connName); err != nil {
+			return nil, err
+		}
+		conn, err := cp.connectionLister.Find(ctx, namespace, connName)
+		if errors.IsNotFound(err) {
+			return nil, fmt.Errorf("Connection %s of DataSet %s not found", connName, ds.Name)
+		}
 		if err != nil {
-			return "", fmt.Errorf("failed to find Connection for DataSet %s: %w", ds.Spec.Connection.ConnectionFrom.Name, err)
+			return nil, fmt.Errorf("failed to find Connection for DataSet %s: %w", connName, err)
+		}
+		if err := cp.reviewCredentialAccess(ctx, namespace, serviceAccount, conn.Namespace, conn.Spec.Credentials); err != nil {
+			return nil, err
 		}
 
-		connValues, err := cp.createCredentialsMap(ctx, conn, iv)
+		connValues, err = cp.createCredentialsMap(ctx, conn, is)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
+	}
 
-		injectedValues["connection"] = connValues
+	return v1alpha1.DataSetTemplateData(credValues, connValues), nil
+}
+
+// reviewAccess checks that the service account may get the named resource.
+// It always succeeds if the provider does not review access.
+func (cp *secretProvider) reviewAccess(ctx context.Context, namespace, serviceAccount, group, resource, resourceNamespace, name string) error {
+	if cp.accessReviewer == nil {
+		return nil
 	}
 
-	content, err := iv.Content.Render(injectedValues)
+	return cp.accessReviewer.ReviewAccess(ctx, namespace, serviceAccount, authorizationv1.ResourceAttributes{
+		Namespace: resourceNamespace,
+		Verb:      "get",
+		Group:     group,
+		Resource:  resource,
+		Name:      name,
+	})
+}
+
+// reviewCredentialAccess checks that the service account may get the Secrets and ConfigMaps the Credentials refer to
+func (cp *secretProvider) reviewCredentialAccess(ctx context.Context, namespace, serviceAccount, credentialsNamespace string, creds v1alpha1.Credentials) error {
+	for _, name := range creds.SecretNames() {
+		if err := cp.reviewAccess(ctx, namespace, serviceAccount, corev1.GroupName, "secrets", credentialsNamespace, name); err != nil {
+			return err
+		}
+	}
+	for _, name := range creds.ConfigMapNames() {
+		if err := cp.reviewAccess(ctx, namespace, serviceAccount, corev1.GroupName, "configmaps", credentialsNamespace, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// issueCredentials returns the fields issued by the CredentialIssuer of the Connection.
+// The credentials live as long as the Argo Workflow may run. Only the Vault roles and webhook URLs
+// the operator allows for the Connection issue credentials, and never for WorkflowTemplates.
+func (cp *secretProvider) issueCredentials(ctx context.Context, conn *v1alpha1.Connection, is *issuance) (map[string]string, error) {
+	key := types.NamespacedName{Namespace: conn.Namespace, Name: conn.Name}
+	if values, ok := is.values[key]; ok {
+		return values, nil
+	}
+	if is.kind == v1alpha1.WorkflowKindWorkflowTemplate {
+		return nil, fmt.Errorf("%s", v1alpha1.IssuedCredentialsMessage(conn.Name))
+	}
+	if err := util.AllowCredentialIssuer(conn); err != nil {
+		return nil, err
+	}
+
+	issuer, err := cp.newCredentialIssuer(cp.client, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create credential issuer of Connection %s: %w", conn.Name, err)
+	}
+	issued, err := issuer.Issue(ctx, conn.Spec.CredentialIssuer.TTL(is.wfs))
 	if err != nil {
-		return "", fmt.Errorf("failed to render content for InjectableValue %s: %w", iv.Name, err)
+		return nil, err
 	}
+	is.leases = append(is.leases, v1alpha1.CredentialLease{
+		Namespace: conn.Namespace,
+		Name:      conn.Name,
+		LeaseID:   issued.LeaseID,
+		ExpiresAt: metav1.NewTime(time.Now().Add(issued.TTL)),
+	})
 
-	return content, nil
+	values := make(map[string]string, len(conn.Spec.CredentialIssuer.Fields))
+	for _, name := range conn.Spec.CredentialIssuer.Fields {
+		value, ok := issued.Values[name]
+		if !ok {
+			return nil, fmt.Errorf("credential issuer of Connection %s did not issue field %s", conn.Name, name)
+		}
+		values[name] = value
+	}
+	is.values[key] = values
+	return values, nil
+}
+
+// RevokeLeases revokes credentials that were issued for a connection secret that could not be stored.
+// Credentials that cannot be revoked expire at the end of their lease.
+func (cp *secretProvider) RevokeLeases(ctx context.Context, leases []v1alpha1.CredentialLease) {
+	for _, lease := range leases {
+		var conn v1alpha1.Connection
+		if err := cp.client.Get(ctx, types.NamespacedName{Namespace: lease.Namespace, Name: lease.Name}, &conn); err != nil {
+			continue
+		}
+		issuer, err := cp.newCredentialIssuer(cp.client, &conn)
+		if err != nil {
+			continue
+		}
+		_ = issuer.Revoke(ctx, lease.LeaseID)
+	}
 }
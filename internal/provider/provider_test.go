@@ -125,32 +125,32 @@ var _ = Describe("Connection Provider", func() {
 					v1alpha1.InjectableValue{
 						Name:          "inline-content",
 						Content:       "{{.inline}}",
-						ConnectionRef: connectionRef,
+						ConnectionRef: v1alpha1.ConnectionReference{Name: connection.Name},
 					},
 					v1alpha1.InjectableValue{
 						Name:          "secret-ref",
 						Content:       "{{.secretRef}}",
-						ConnectionRef: connectionRef,
+						ConnectionRef: v1alpha1.ConnectionReference{Name: connection.Name},
 					},
 					v1alpha1.InjectableValue{
 						Name:          "configmap-ref",
 						Content:       "{{.configmapRef}}",
-						ConnectionRef: connectionRef,
+						ConnectionRef: v1alpha1.ConnectionReference{Name: connection.Name},
 					},
 					v1alpha1.InjectableValue{
 						Name:          "combination",
 						Content:       "{{.inline}} {{.configmapRef}} {{.secretRef}}",
-						ConnectionRef: connectionRef,
+						ConnectionRef: v1alpha1.ConnectionReference{Name: connection.Name},
 					},
 					v1alpha1.InjectableValue{
 						Name:          "from-dataset",
 						Content:       "{{metadata.inline}}",
-						ConnectionRef: datasetRef,
+						ConnectionRef: v1alpha1.ConnectionReference{Name: datasetRef.Name},
 					},
 					v1alpha1.InjectableValue{
 						Name:          "from-dataset-connection",
 						Content:       "{{connection.inline}}",
-						ConnectionRef: datasetRef,
+						ConnectionRef: v1alpha1.ConnectionReference{Name: datasetRef.Name},
 					},
 				},
 			},
@@ -198,4 +198,90 @@ var _ = Describe("Connection Provider", func() {
 		}, timeout, interval)
 
 	})
+
+	It("Should provide content to a CronWorkflow secret", func() {
+		ctx := context.Background()
+
+		cwf := &v1alpha1.CronWorkflow{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "test-cron-workflow",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.CronWorkflowSpec{
+				Schedule: "* * * * *",
+				WorkflowSpec: v1alpha1.WorkflowSpec{
+					InjectableValues: v1alpha1.InjectableValues{
+						v1alpha1.InjectableValue{
+							Name:          "inline-content",
+							Content:       "{{.inline}}",
+							ConnectionRef: v1alpha1.ConnectionReference{Name: connection.Name},
+						},
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, cwf)).ToNot(HaveOccurred())
+
+		cwfSecret := v1alpha1.ConnectionSecret(cwf.Name, cwf.Namespace)
+		Expect(k8sClient.Create(ctx, &cwfSecret)).ToNot(HaveOccurred())
+
+		Eventually(func(g Gomega) {
+			g.Expect(provider.ProvideSecret(v1alpha1.WorkflowKindCronWorkflow, cwf.Name, cwf.Namespace)).To(Succeed())
+
+			var res corev1.Secret
+			g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: cwfSecret.Name, Namespace: cwfSecret.Namespace}, &res)).To(Succeed())
+			g.Expect(string(res.Data["inline-content"])).To(Equal("inline-value"))
+		}, timeout, interval).Should(Succeed())
+	})
+
+	It("Should provide content to a CronWorkflow run secret only once", func() {
+		ctx := context.Background()
+
+		cwf := &v1alpha1.CronWorkflow{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "test-cron-workflow-runs",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.CronWorkflowSpec{
+				Schedule: "* * * * *",
+				WorkflowSpec: v1alpha1.WorkflowSpec{
+					InjectableValues: v1alpha1.InjectableValues{
+						v1alpha1.InjectableValue{
+							Name:          "inline-content",
+							Content:       "{{.inline}}",
+							ConnectionRef: v1alpha1.ConnectionReference{Name: connection.Name},
+						},
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, cwf)).ToNot(HaveOccurred())
+
+		runSecret := v1alpha1.RunConnectionSecret("test-cron-workflow-runs-1234", cwf.Namespace)
+		Expect(k8sClient.Create(ctx, &runSecret)).ToNot(HaveOccurred())
+		runSecretKey := types.NamespacedName{Name: runSecret.Name, Namespace: runSecret.Namespace}
+
+		Eventually(func(g Gomega) {
+			g.Expect(provider.ProvideRunSecret(v1alpha1.WorkflowKindCronWorkflow, cwf.Name, "test-cron-workflow-runs-1234", cwf.Namespace)).To(Succeed())
+
+			var res corev1.Secret
+			g.Expect(k8sClient.Get(ctx, runSecretKey, &res)).To(Succeed())
+			g.Expect(string(res.Data["inline-content"])).To(Equal("inline-value"))
+		}, timeout, interval).Should(Succeed())
+
+		By("Keeping the rendered credentials when the connection changes")
+		Eventually(func() error {
+			var conn v1alpha1.Connection
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: connection.Name, Namespace: connection.Namespace}, &conn); err != nil {
+				return err
+			}
+			conn.Spec.Credentials["inline"] = v1alpha1.Value{Value: "rotated-value"}
+			return k8sClient.Update(ctx, &conn)
+		}, timeout, interval).Should(Succeed())
+
+		Expect(provider.ProvideRunSecret(v1alpha1.WorkflowKindCronWorkflow, cwf.Name, "test-cron-workflow-runs-1234", cwf.Namespace)).To(Succeed())
+		var res corev1.Secret
+		Expect(k8sClient.Get(ctx, runSecretKey, &res)).To(Succeed())
+		Expect(string(res.Data["inline-content"])).To(Equal("inline-value"))
+	})
 })
@@ -4,14 +4,17 @@ import (
 	"context"
 	"fmt"
 
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/dataworkz/kubeetl/api/v1alpha1"
 )
 
-//ConnectionLister lists and finds Connections
+// ConnectionLister lists and finds Connections
 type ConnectionLister interface {
-	List(ctx context.Context, namespace string) (*v1alpha1.ConnectionList, error)
+	List(ctx context.Context, namespace string, selector labels.Selector) (*v1alpha1.ConnectionList, error)
 	Find(ctx context.Context, namespace string, name string) (*v1alpha1.Connection, error)
 }
 
@@ -25,28 +28,27 @@ func NewConnectionLister(client client.Client) ConnectionLister {
 	}
 }
 
-// List returns a ConnectionList in the given namespace.
-func (l *connectionLister) List(ctx context.Context, namespace string) (*v1alpha1.ConnectionList, error) {
+// List returns a ConnectionList with the Connections in the given namespace, or in all namespaces if the namespace is empty,
+// whose labels match the selector. A nil selector matches all Connections.
+func (l *connectionLister) List(ctx context.Context, namespace string, selector labels.Selector) (*v1alpha1.ConnectionList, error) {
 	connList := &v1alpha1.ConnectionList{}
-	if err := l.client.List(ctx, connList, &client.ListOptions{Namespace: namespace}); err != nil {
+	if err := l.client.List(ctx, connList, &client.ListOptions{Namespace: namespace, LabelSelector: selector}); err != nil {
 		return nil, fmt.Errorf("unable to list Connections: %w", err)
 	}
 
 	return connList, nil
 }
 
+// Find returns the Connection with the given name in the namespace.
+// It returns a NotFound error, see errors.IsNotFound, if the Connection does not exist.
 func (l *connectionLister) Find(ctx context.Context, namespace string, name string) (*v1alpha1.Connection, error) {
-	conList, err := l.List(ctx, namespace)
-	if err != nil {
-		return nil, err
-	}
-
-	var res *v1alpha1.Connection
-	for _, conn := range conList.Items {
-		if conn.Name == name {
-			res = &conn
+	conn := &v1alpha1.Connection{}
+	if err := l.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, conn); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, err
 		}
+		return nil, fmt.Errorf("unable to get Connection %s: %w", name, err)
 	}
 
-	return res, nil
+	return conn, nil
 }
@@ -0,0 +1,76 @@
+package listers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+// ConnectionGrantLister lists ConnectionGrants and checks whether they grant access to a Connection
+type ConnectionGrantLister interface {
+	List(ctx context.Context, namespace string, selector labels.Selector) (*v1alpha1.ConnectionGrantList, error)
+	Granted(ctx context.Context, connection types.NamespacedName, namespace, serviceAccount string) (bool, error)
+}
+
+type connectionGrantLister struct {
+	client  client.Client
+	indexed bool
+}
+
+// NewConnectionGrantLister returns a ConnectionGrantLister that checks every ConnectionGrant in the namespace of a Connection
+func NewConnectionGrantLister(client client.Client) ConnectionGrantLister {
+	return &connectionGrantLister{
+		client: client,
+	}
+}
+
+// NewIndexedConnectionGrantLister returns a ConnectionGrantLister that only checks the ConnectionGrants naming a Connection.
+// The client must read from the cache of a manager on which the indexes of SetupIndexes are registered.
+func NewIndexedConnectionGrantLister(client client.Client) ConnectionGrantLister {
+	return &connectionGrantLister{
+		client:  client,
+		indexed: true,
+	}
+}
+
+// List returns a ConnectionGrantList with the ConnectionGrants in the given namespace, or in all namespaces if the namespace is empty,
+// whose labels match the selector. A nil selector matches all ConnectionGrants.
+func (l *connectionGrantLister) List(ctx context.Context, namespace string, selector labels.Selector) (*v1alpha1.ConnectionGrantList, error) {
+	grantList := &v1alpha1.ConnectionGrantList{}
+	if err := l.client.List(ctx, grantList, &client.ListOptions{Namespace: namespace, LabelSelector: selector}); err != nil {
+		return nil, fmt.Errorf("unable to list ConnectionGrants: %w", err)
+	}
+
+	return grantList, nil
+}
+
+// Granted returns whether the service account in the namespace may consume the Connection.
+// A Connection in the same namespace may always be consumed, a Connection in another namespace
+// only if a ConnectionGrant in the namespace of the Connection allows it.
+func (l *connectionGrantLister) Granted(ctx context.Context, connection types.NamespacedName, namespace, serviceAccount string) (bool, error) {
+	if connection.Namespace == namespace {
+		return true, nil
+	}
+
+	opts := []client.ListOption{client.InNamespace(connection.Namespace)}
+	if l.indexed {
+		opts = append(opts, client.MatchingFields{ConnectionGrantConnectionIndex: connection.Name})
+	}
+	grantList := &v1alpha1.ConnectionGrantList{}
+	if err := l.client.List(ctx, grantList, opts...); err != nil {
+		return false, fmt.Errorf("unable to list ConnectionGrants: %w", err)
+	}
+
+	for _, g := range grantList.Items {
+		if g.Allows(connection.Name, namespace, serviceAccount) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
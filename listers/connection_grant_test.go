@@ -0,0 +1,81 @@
+package listers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+var _ = Describe("ConnectionGrantLister", func() {
+	var client client.Client
+	var cgl ConnectionGrantLister
+	var ctx context.Context
+	warehouse := types.NamespacedName{Name: "warehouse", Namespace: "platform"}
+	BeforeEach(func() {
+		s := runtime.NewScheme()
+		_ = v1alpha1.AddToScheme(s)
+		client = fake.NewClientBuilder().WithScheme(s).Build()
+		cgl = NewConnectionGrantLister(client)
+		ctx = context.Background()
+	})
+
+	It("Should grant Connections in the same namespace", func() {
+		granted, err := cgl.Granted(ctx, warehouse, "platform", "default")
+		Expect(err).To(Succeed())
+		Expect(granted).To(BeTrue())
+	})
+
+	It("Should only grant Connections in another namespace with a ConnectionGrant", func() {
+		granted, err := cgl.Granted(ctx, warehouse, "analytics", "default")
+		Expect(err).To(Succeed())
+		Expect(granted).To(BeFalse())
+
+		grant := &v1alpha1.ConnectionGrant{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "analytics",
+				Namespace: "platform",
+			},
+			Spec: v1alpha1.ConnectionGrantSpec{
+				From: []v1alpha1.ConnectionGrantFrom{{Namespace: "analytics"}},
+				To:   []v1alpha1.ConnectionGrantTo{{Name: "warehouse"}},
+			},
+		}
+		Expect(client.Create(ctx, grant)).To(Succeed())
+
+		granted, err = cgl.Granted(ctx, warehouse, "analytics", "default")
+		Expect(err).To(Succeed())
+		Expect(granted).To(BeTrue())
+	})
+
+	It("Should check the ConnectionGrants naming the Connection with an indexed lister", func() {
+		grant := &v1alpha1.ConnectionGrant{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "lake",
+				Namespace: "platform",
+			},
+			Spec: v1alpha1.ConnectionGrantSpec{
+				From: []v1alpha1.ConnectionGrantFrom{{Namespace: "analytics"}},
+				To:   []v1alpha1.ConnectionGrantTo{{Name: "lake"}},
+			},
+		}
+		Expect(client.Create(ctx, grant)).To(Succeed())
+
+		indexed := NewIndexedConnectionGrantLister(client)
+		granted, err := indexed.Granted(ctx, warehouse, "analytics", "default")
+		Expect(err).To(Succeed())
+		Expect(granted).To(BeFalse())
+
+		granted, err = indexed.Granted(ctx, types.NamespacedName{Name: "lake", Namespace: "platform"}, "analytics", "default")
+		Expect(err).To(Succeed())
+		Expect(granted).To(BeTrue())
+	})
+})
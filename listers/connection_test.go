@@ -6,7 +6,9 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -29,7 +31,7 @@ var _ = Describe("ConnectionLister", func() {
 
 	It("Should be able to find a Connection based on the type name", func() {
 		conn, err := cl.Find(ctx, "default", "test")
-		Expect(err).To(Succeed())
+		Expect(errors.IsNotFound(err)).To(BeTrue())
 		Expect(conn).To(BeNil())
 		c := &v1alpha1.Connection{
 			TypeMeta: metav1.TypeMeta{
@@ -47,4 +49,40 @@ var _ = Describe("ConnectionLister", func() {
 		Expect(err).To(Succeed())
 		Expect(conn).To(Not(BeNil()))
 	})
+
+	It("Should list Connections matching a label selector in a namespace or in all namespaces", func() {
+		for _, c := range []struct{ namespace, name, tier string }{
+			{"default", "warehouse", "gold"},
+			{"default", "staging", "bronze"},
+			{"analytics", "lake", "gold"},
+		} {
+			Expect(client.Create(ctx, &v1alpha1.Connection{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      c.name,
+					Namespace: c.namespace,
+					Labels:    map[string]string{"tier": c.tier},
+				},
+			})).To(Succeed())
+		}
+		names := func(list *v1alpha1.ConnectionList) []string {
+			var res []string
+			for _, c := range list.Items {
+				res = append(res, c.Name)
+			}
+			return res
+		}
+
+		list, err := cl.List(ctx, "default", nil)
+		Expect(err).To(Succeed())
+		Expect(names(list)).To(ConsistOf("warehouse", "staging"))
+
+		gold := labels.SelectorFromSet(labels.Set{"tier": "gold"})
+		list, err = cl.List(ctx, "default", gold)
+		Expect(err).To(Succeed())
+		Expect(names(list)).To(ConsistOf("warehouse"))
+
+		list, err = cl.List(ctx, metav1.NamespaceAll, gold)
+		Expect(err).To(Succeed())
+		Expect(names(list)).To(ConsistOf("warehouse", "lake"))
+	})
 })
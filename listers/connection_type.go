@@ -4,14 +4,18 @@ import (
 	"context"
 	"fmt"
 
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/dataworkz/kubeetl/api/v1alpha1"
 )
 
-//ConnectionTypeLister lists and finds ConnectionTypes
+// ConnectionTypeLister lists and finds ConnectionTypes
 type ConnectionTypeLister interface {
-	List(ctx context.Context, namespace string) (*v1alpha1.ConnectionTypeList, error)
+	List(ctx context.Context, namespace string, selector labels.Selector) (*v1alpha1.ConnectionTypeList, error)
+	ListCluster(ctx context.Context, selector labels.Selector) (*v1alpha1.ClusterConnectionTypeList, error)
 	Find(ctx context.Context, namespace string, conType string) (*v1alpha1.ConnectionType, error)
 }
 
@@ -25,28 +29,49 @@ func NewConnectionTypeLister(client client.Client) ConnectionTypeLister {
 	}
 }
 
-// List returns a ConnectionTypeList in the given namespace.
-func (l *connectionTypeLister) List(ctx context.Context, namespace string) (*v1alpha1.ConnectionTypeList, error) {
+// List returns a ConnectionTypeList with the ConnectionTypes in the given namespace, or in all namespaces if the namespace is empty,
+// whose labels match the selector. A nil selector matches all ConnectionTypes.
+func (l *connectionTypeLister) List(ctx context.Context, namespace string, selector labels.Selector) (*v1alpha1.ConnectionTypeList, error) {
 	typeList := &v1alpha1.ConnectionTypeList{}
-	if err := l.client.List(ctx, typeList, &client.ListOptions{Namespace: namespace}); err != nil {
+	if err := l.client.List(ctx, typeList, &client.ListOptions{Namespace: namespace, LabelSelector: selector}); err != nil {
 		return nil, fmt.Errorf("unable to list ConnectionTypes: %w", err)
 	}
 
 	return typeList, nil
 }
 
+// ListCluster returns the ClusterConnectionTypeList with the ClusterConnectionTypes whose labels match the selector.
+// A nil selector matches all ClusterConnectionTypes.
+func (l *connectionTypeLister) ListCluster(ctx context.Context, selector labels.Selector) (*v1alpha1.ClusterConnectionTypeList, error) {
+	typeList := &v1alpha1.ClusterConnectionTypeList{}
+	if err := l.client.List(ctx, typeList, &client.ListOptions{LabelSelector: selector}); err != nil {
+		return nil, fmt.Errorf("unable to list ClusterConnectionTypes: %w", err)
+	}
+
+	return typeList, nil
+}
+
+// Find returns the ConnectionType with the given name in the namespace or, if the namespace does not contain it,
+// the ClusterConnectionType with that name as a ConnectionType without a namespace.
+// It returns a NotFound error, see errors.IsNotFound, if neither exists.
 func (l *connectionTypeLister) Find(ctx context.Context, namespace string, conType string) (*v1alpha1.ConnectionType, error) {
-	typeList, err := l.List(ctx, namespace)
-	if err != nil {
-		return nil, err
+	ct := &v1alpha1.ConnectionType{}
+	err := l.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: conType}, ct)
+	if err == nil {
+		return ct, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("unable to get ConnectionType %s: %w", conType, err)
 	}
 
-	var res *v1alpha1.ConnectionType
-	for _, ct := range typeList.Items {
-		if ct.Name == conType {
-			res = &ct
-		}
+	clusterType := &v1alpha1.ClusterConnectionType{}
+	err = l.client.Get(ctx, types.NamespacedName{Name: conType}, clusterType)
+	if errors.IsNotFound(err) {
+		return nil, errors.NewNotFound(v1alpha1.GroupVersion.WithResource("connectiontypes").GroupResource(), conType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get ClusterConnectionType %s: %w", conType, err)
 	}
 
-	return res, nil
+	return clusterType.ConnectionType(), nil
 }
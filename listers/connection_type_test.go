@@ -6,6 +6,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -29,7 +30,7 @@ var _ = Describe("ConnectionTypeLister", func() {
 
 	It("Should be able to find a ConnectionType based on the type name", func() {
 		conType, err := ctl.Find(ctx, "default", "test")
-		Expect(err).To(Succeed())
+		Expect(errors.IsNotFound(err)).To(BeTrue())
 		Expect(conType).To(BeNil())
 		ct := &v1alpha1.ConnectionType{
 			TypeMeta: metav1.TypeMeta{
@@ -47,4 +48,55 @@ var _ = Describe("ConnectionTypeLister", func() {
 		Expect(err).To(Succeed())
 		Expect(conType).To(Not(BeNil()))
 	})
+
+	It("Should fall back to a ClusterConnectionType", func() {
+		clusterType := &v1alpha1.ClusterConnectionType{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "cluster",
+			},
+			Spec: v1alpha1.ConnectionTypeSpec{AllowExtraFields: true},
+		}
+		Expect(client.Create(ctx, clusterType)).To(Succeed())
+
+		conType, err := ctl.Find(ctx, "default", "cluster")
+		Expect(err).To(Succeed())
+		Expect(conType).To(Not(BeNil()))
+		Expect(conType.Namespace).To(BeEmpty())
+		Expect(conType.Spec.AllowExtraFields).To(BeTrue())
+	})
+
+	It("Should prefer a ConnectionType in the namespace over a ClusterConnectionType", func() {
+		clusterType := &v1alpha1.ClusterConnectionType{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "shadowed",
+			},
+			Spec: v1alpha1.ConnectionTypeSpec{AllowExtraFields: true},
+		}
+		Expect(client.Create(ctx, clusterType)).To(Succeed())
+		local := &v1alpha1.ConnectionType{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "shadowed",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.ConnectionTypeSpec{AllowExtraFields: false},
+		}
+		Expect(client.Create(ctx, local)).To(Succeed())
+
+		conType, err := ctl.Find(ctx, "default", "shadowed")
+		Expect(err).To(Succeed())
+		Expect(conType.Namespace).To(Equal("default"))
+		Expect(conType.Spec.AllowExtraFields).To(BeFalse())
+
+		conType, err = ctl.Find(ctx, "other", "shadowed")
+		Expect(err).To(Succeed())
+		Expect(conType.Namespace).To(BeEmpty())
+		Expect(conType.Spec.AllowExtraFields).To(BeTrue())
+	})
+
+	It("Should return a NotFound error if neither a ConnectionType nor a ClusterConnectionType exists", func() {
+		conType, err := ctl.Find(ctx, "default", "missing")
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring(`connectiontypes.etl.dataworkz.nl "missing" not found`)))
+		Expect(conType).To(BeNil())
+	})
 })
@@ -0,0 +1,85 @@
+package listers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+// CronWorkflowLister lists and finds CronWorkflows
+type CronWorkflowLister interface {
+	List(ctx context.Context, namespace string, selector labels.Selector) (*v1alpha1.CronWorkflowList, error)
+	Find(ctx context.Context, namespace string, name string) (*v1alpha1.CronWorkflow, error)
+	ListByConnection(ctx context.Context, connection types.NamespacedName) (*v1alpha1.CronWorkflowList, error)
+	ListByDataSet(ctx context.Context, namespace string, dataSet string) (*v1alpha1.CronWorkflowList, error)
+}
+
+type cronWorkflowLister struct {
+	client client.Client
+}
+
+func NewCronWorkflowLister(client client.Client) CronWorkflowLister {
+	return &cronWorkflowLister{
+		client: client,
+	}
+}
+
+// List returns a CronWorkflowList with the CronWorkflows in the given namespace, or in all namespaces if the namespace is empty,
+// whose labels match the selector. A nil selector matches all CronWorkflows.
+func (l *cronWorkflowLister) List(ctx context.Context, namespace string, selector labels.Selector) (*v1alpha1.CronWorkflowList, error) {
+	cwfList := &v1alpha1.CronWorkflowList{}
+	if err := l.client.List(ctx, cwfList, &client.ListOptions{Namespace: namespace, LabelSelector: selector}); err != nil {
+		return nil, fmt.Errorf("unable to list CronWorkflows: %w", err)
+	}
+
+	return cwfList, nil
+}
+
+// Find returns the CronWorkflow with the given name in the namespace.
+// It returns a NotFound error, see errors.IsNotFound, if the CronWorkflow does not exist.
+func (l *cronWorkflowLister) Find(ctx context.Context, namespace string, name string) (*v1alpha1.CronWorkflow, error) {
+	cwf := &v1alpha1.CronWorkflow{}
+	if err := l.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, cwf); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unable to get CronWorkflow %s: %w", name, err)
+	}
+
+	return cwf, nil
+}
+
+// ListByConnection returns a CronWorkflowList with the CronWorkflows that inject the Connection, both in the namespace
+// of the Connection and in the namespaces it is granted to. The client must read from the cache of a manager
+// on which the indexes of SetupIndexes are registered.
+func (l *cronWorkflowLister) ListByConnection(ctx context.Context, connection types.NamespacedName) (*v1alpha1.CronWorkflowList, error) {
+	cwfList := &v1alpha1.CronWorkflowList{}
+	if err := l.client.List(ctx, cwfList, client.InNamespace(connection.Namespace), client.MatchingFields{WorkflowConnectionIndex: connection.Name}); err != nil {
+		return nil, fmt.Errorf("unable to list CronWorkflows: %w", err)
+	}
+
+	granted := &v1alpha1.CronWorkflowList{}
+	if err := l.client.List(ctx, granted, client.MatchingFields{WorkflowGrantedConnectionIndex: connection.String()}); err != nil {
+		return nil, fmt.Errorf("unable to list CronWorkflows: %w", err)
+	}
+	cwfList.Items = append(cwfList.Items, granted.Items...)
+
+	return cwfList, nil
+}
+
+// ListByDataSet returns a CronWorkflowList with the CronWorkflows in the namespace that inject the DataSet.
+// The client must read from the cache of a manager on which the indexes of SetupIndexes are registered.
+func (l *cronWorkflowLister) ListByDataSet(ctx context.Context, namespace string, dataSet string) (*v1alpha1.CronWorkflowList, error) {
+	cwfList := &v1alpha1.CronWorkflowList{}
+	if err := l.client.List(ctx, cwfList, client.InNamespace(namespace), client.MatchingFields{WorkflowDataSetIndex: dataSet}); err != nil {
+		return nil, fmt.Errorf("unable to list CronWorkflows: %w", err)
+	}
+
+	return cwfList, nil
+}
@@ -0,0 +1,51 @@
+package listers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+var _ = Describe("CronWorkflowLister", func() {
+	var client client.Client
+	var cwfl CronWorkflowLister
+	var ctx context.Context
+	BeforeEach(func() {
+		s := runtime.NewScheme()
+		s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.CronWorkflowList{}, &v1alpha1.CronWorkflow{})
+		_ = v1alpha1.AddToScheme(s)
+		client = fake.NewClientBuilder().WithScheme(s).Build()
+		cwfl = NewCronWorkflowLister(client)
+		ctx = context.Background()
+	})
+
+	It("Should be able to find a CronWorkflow based on the type name", func() {
+		cronWorkflow, err := cwfl.Find(ctx, "default", "test")
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(cronWorkflow).To(BeNil())
+		cwf := &v1alpha1.CronWorkflow{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "etl.dataworkz.nl/v1alpha1",
+				Kind:       "CronWorkflow",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+		}
+		err = client.Create(ctx, cwf)
+		Expect(err).To(Succeed())
+		cronWorkflow, err = cwfl.Find(ctx, "default", "test")
+		Expect(err).To(Succeed())
+		Expect(cronWorkflow).To(Not(BeNil()))
+	})
+})
@@ -4,15 +4,19 @@ import (
 	"context"
 	"fmt"
 
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/dataworkz/kubeetl/api/v1alpha1"
 )
 
-//DataSetLister lists and finds DataSets
+// DataSetLister lists and finds DataSets
 type DataSetLister interface {
-	List(ctx context.Context, namespace string) (*v1alpha1.DataSetList, error)
+	List(ctx context.Context, namespace string, selector labels.Selector) (*v1alpha1.DataSetList, error)
 	Find(ctx context.Context, namespace string, name string) (*v1alpha1.DataSet, error)
+	ListByConnection(ctx context.Context, connection types.NamespacedName) (*v1alpha1.DataSetList, error)
 }
 
 type dataSetLister struct {
@@ -25,28 +29,38 @@ func NewDataSetLister(client client.Client) DataSetLister {
 	}
 }
 
-// List returns a DataSetList in the given namespace.
-func (l *dataSetLister) List(ctx context.Context, namespace string) (*v1alpha1.DataSetList, error) {
+// List returns a DataSetList with the DataSets in the given namespace, or in all namespaces if the namespace is empty,
+// whose labels match the selector. A nil selector matches all DataSets.
+func (l *dataSetLister) List(ctx context.Context, namespace string, selector labels.Selector) (*v1alpha1.DataSetList, error) {
 	dataList := &v1alpha1.DataSetList{}
-	if err := l.client.List(ctx, dataList, &client.ListOptions{Namespace: namespace}); err != nil {
+	if err := l.client.List(ctx, dataList, &client.ListOptions{Namespace: namespace, LabelSelector: selector}); err != nil {
 		return nil, fmt.Errorf("unable to list DataSets: %w", err)
 	}
 
 	return dataList, nil
 }
 
+// Find returns the DataSet with the given name in the namespace.
+// It returns a NotFound error, see errors.IsNotFound, if the DataSet does not exist.
 func (l *dataSetLister) Find(ctx context.Context, namespace string, name string) (*v1alpha1.DataSet, error) {
-	dataList, err := l.List(ctx, namespace)
-	if err != nil {
-		return nil, err
+	ds := &v1alpha1.DataSet{}
+	if err := l.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, ds); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unable to get DataSet %s: %w", name, err)
 	}
 
-	var res *v1alpha1.DataSet
-	for _, ds := range dataList.Items {
-		if ds.Name == name {
-			res = &ds
-		}
+	return ds, nil
+}
+
+// ListByConnection returns a DataSetList with the DataSets that refer to the Connection.
+// The client must read from the cache of a manager on which the indexes of SetupIndexes are registered.
+func (l *dataSetLister) ListByConnection(ctx context.Context, connection types.NamespacedName) (*v1alpha1.DataSetList, error) {
+	dataList := &v1alpha1.DataSetList{}
+	if err := l.client.List(ctx, dataList, client.InNamespace(connection.Namespace), client.MatchingFields{DataSetConnectionIndex: connection.Name}); err != nil {
+		return nil, fmt.Errorf("unable to list DataSets: %w", err)
 	}
 
-	return res, nil
+	return dataList, nil
 }
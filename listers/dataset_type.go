@@ -4,14 +4,18 @@ import (
 	"context"
 	"fmt"
 
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/dataworkz/kubeetl/api/v1alpha1"
 )
 
-// DataSetLister lists and finds DataSets
+// DataSetTypeLister lists and finds DataSetTypes
 type DataSetTypeLister interface {
-	List(ctx context.Context, namespace string) (*v1alpha1.DataSetTypeList, error)
+	List(ctx context.Context, namespace string, selector labels.Selector) (*v1alpha1.DataSetTypeList, error)
+	ListCluster(ctx context.Context, selector labels.Selector) (*v1alpha1.ClusterDataSetTypeList, error)
 	Find(ctx context.Context, namespace string, dsType string) (*v1alpha1.DataSetType, error)
 }
 
@@ -25,28 +29,49 @@ func NewDataSetTypeLister(client client.Client) DataSetTypeLister {
 	}
 }
 
-// List returns a DataSetTypeList in the given namespace.
-func (l *dataSetTypeLister) List(ctx context.Context, namespace string) (*v1alpha1.DataSetTypeList, error) {
+// List returns a DataSetTypeList with the DataSetTypes in the given namespace, or in all namespaces if the namespace is empty,
+// whose labels match the selector. A nil selector matches all DataSetTypes.
+func (l *dataSetTypeLister) List(ctx context.Context, namespace string, selector labels.Selector) (*v1alpha1.DataSetTypeList, error) {
 	typeList := &v1alpha1.DataSetTypeList{}
-	if err := l.client.List(ctx, typeList, &client.ListOptions{Namespace: namespace}); err != nil {
-		return nil, fmt.Errorf("unable to list DatasetTypes: %w", err)
+	if err := l.client.List(ctx, typeList, &client.ListOptions{Namespace: namespace, LabelSelector: selector}); err != nil {
+		return nil, fmt.Errorf("unable to list DataSetTypes: %w", err)
 	}
 
 	return typeList, nil
 }
 
+// ListCluster returns the ClusterDataSetTypeList with the ClusterDataSetTypes whose labels match the selector.
+// A nil selector matches all ClusterDataSetTypes.
+func (l *dataSetTypeLister) ListCluster(ctx context.Context, selector labels.Selector) (*v1alpha1.ClusterDataSetTypeList, error) {
+	typeList := &v1alpha1.ClusterDataSetTypeList{}
+	if err := l.client.List(ctx, typeList, &client.ListOptions{LabelSelector: selector}); err != nil {
+		return nil, fmt.Errorf("unable to list ClusterDataSetTypes: %w", err)
+	}
+
+	return typeList, nil
+}
+
+// Find returns the DataSetType with the given name in the namespace or, if the namespace does not contain it,
+// the ClusterDataSetType with that name as a DataSetType without a namespace.
+// It returns a NotFound error, see errors.IsNotFound, if neither exists.
 func (l *dataSetTypeLister) Find(ctx context.Context, namespace string, dsType string) (*v1alpha1.DataSetType, error) {
-	typeList, err := l.List(ctx, namespace)
-	if err != nil {
-		return nil, err
+	dt := &v1alpha1.DataSetType{}
+	err := l.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: dsType}, dt)
+	if err == nil {
+		return dt, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("unable to get DataSetType %s: %w", dsType, err)
 	}
 
-	var res *v1alpha1.DataSetType
-	for _, dt := range typeList.Items {
-		if dt.Name == dsType {
-			res = &dt
-		}
+	clusterType := &v1alpha1.ClusterDataSetType{}
+	err = l.client.Get(ctx, types.NamespacedName{Name: dsType}, clusterType)
+	if errors.IsNotFound(err) {
+		return nil, errors.NewNotFound(v1alpha1.GroupVersion.WithResource("datasettypes").GroupResource(), dsType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get ClusterDataSetType %s: %w", dsType, err)
 	}
 
-	return res, nil
+	return clusterType.DataSetType(), nil
 }
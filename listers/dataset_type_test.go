@@ -6,6 +6,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -29,7 +30,7 @@ var _ = Describe("DataSetTypeLister", func() {
 
 	It("Should be able to find a DataSetType based on the type name", func() {
 		dsType, err := dstl.Find(ctx, "default", "test")
-		Expect(err).To(Succeed())
+		Expect(errors.IsNotFound(err)).To(BeTrue())
 		Expect(dsType).To(BeNil())
 		dt := &v1alpha1.DataSetType{
 			ObjectMeta: metav1.ObjectMeta{
@@ -43,4 +44,48 @@ var _ = Describe("DataSetTypeLister", func() {
 		Expect(err).To(Succeed())
 		Expect(dsType).To(Not(BeNil()))
 	})
+
+	It("Should fall back to a ClusterDataSetType", func() {
+		clusterType := &v1alpha1.ClusterDataSetType{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "cluster",
+			},
+			Spec: v1alpha1.DataSetTypeSpec{MetadataFields: v1alpha1.MetadataValidation{AllowExtraFields: true}},
+		}
+		Expect(client.Create(ctx, clusterType)).To(Succeed())
+
+		dsType, err := dstl.Find(ctx, "default", "cluster")
+		Expect(err).To(Succeed())
+		Expect(dsType).To(Not(BeNil()))
+		Expect(dsType.Namespace).To(BeEmpty())
+		Expect(dsType.Spec.MetadataFields.AllowExtraFields).To(BeTrue())
+	})
+
+	It("Should prefer a DataSetType in the namespace over a ClusterDataSetType", func() {
+		clusterType := &v1alpha1.ClusterDataSetType{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "shadowed",
+			},
+			Spec: v1alpha1.DataSetTypeSpec{MetadataFields: v1alpha1.MetadataValidation{AllowExtraFields: true}},
+		}
+		Expect(client.Create(ctx, clusterType)).To(Succeed())
+		local := &v1alpha1.DataSetType{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "shadowed",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.DataSetTypeSpec{MetadataFields: v1alpha1.MetadataValidation{AllowExtraFields: false}},
+		}
+		Expect(client.Create(ctx, local)).To(Succeed())
+
+		dsType, err := dstl.Find(ctx, "default", "shadowed")
+		Expect(err).To(Succeed())
+		Expect(dsType.Namespace).To(Equal("default"))
+		Expect(dsType.Spec.MetadataFields.AllowExtraFields).To(BeFalse())
+
+		dsType, err = dstl.Find(ctx, "other", "shadowed")
+		Expect(err).To(Succeed())
+		Expect(dsType.Namespace).To(BeEmpty())
+		Expect(dsType.Spec.MetadataFields.AllowExtraFields).To(BeTrue())
+	})
 })
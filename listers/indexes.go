@@ -0,0 +1,119 @@
+package listers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+const (
+	// ConnectionGrantConnectionIndex indexes ConnectionGrants by the names of the Connections they grant access to
+	ConnectionGrantConnectionIndex = ".spec.to.name"
+	// DataSetConnectionIndex indexes DataSets by the Connection they refer to
+	DataSetConnectionIndex = ".spec.connection.connectionFrom.name"
+	// WorkflowConnectionIndex indexes Workflows, CronWorkflows and WorkflowTemplates by the injected Connections
+	// in their own namespace
+	WorkflowConnectionIndex = ".spec.injectableValues.connectionRef.name"
+	// WorkflowGrantedConnectionIndex indexes Workflows, CronWorkflows and WorkflowTemplates by the namespace/name
+	// of the injected Connections in other namespaces
+	WorkflowGrantedConnectionIndex = ".spec.injectableValues.connectionRef.namespace"
+	// WorkflowDataSetIndex indexes Workflows, CronWorkflows and WorkflowTemplates by the injected DataSets
+	WorkflowDataSetIndex = ".spec.injectableValues.dataSetRef.name"
+)
+
+// workflowSpecs returns the WorkflowSpec embedded in each kind that injects Connections and DataSets
+var workflowSpecs = map[client.Object]func(client.Object) v1alpha1.WorkflowSpec{
+	&v1alpha1.Workflow{}: func(obj client.Object) v1alpha1.WorkflowSpec {
+		return obj.(*v1alpha1.Workflow).Spec
+	},
+	&v1alpha1.CronWorkflow{}: func(obj client.Object) v1alpha1.WorkflowSpec {
+		return obj.(*v1alpha1.CronWorkflow).Spec.WorkflowSpec
+	},
+	&v1alpha1.WorkflowTemplate{}: func(obj client.Object) v1alpha1.WorkflowSpec {
+		return obj.(*v1alpha1.WorkflowTemplate).Spec.WorkflowSpec
+	},
+}
+
+// SetupIndexes registers the field indexes used by the indexed listers and lookups on the cache of the manager.
+// The indexes must be registered once, before the manager is started.
+func SetupIndexes(mgr ctrl.Manager) error {
+	ctx := context.Background()
+	indexer := mgr.GetFieldIndexer()
+
+	if err := indexer.IndexField(ctx, &v1alpha1.ConnectionGrant{}, ConnectionGrantConnectionIndex, func(obj client.Object) []string {
+		var names []string
+		for _, to := range obj.(*v1alpha1.ConnectionGrant).Spec.To {
+			names = append(names, to.Name)
+		}
+		return names
+	}); err != nil {
+		return fmt.Errorf("unable to index ConnectionGrants: %w", err)
+	}
+
+	if err := indexer.IndexField(ctx, &v1alpha1.DataSet{}, DataSetConnectionIndex, func(obj client.Object) []string {
+		ds := obj.(*v1alpha1.DataSet)
+		if ds.Spec.Connection.ConnectionFrom == nil || ds.Spec.Connection.ConnectionFrom.Name == "" {
+			return nil
+		}
+		return []string{ds.Spec.Connection.ConnectionFrom.Name}
+	}); err != nil {
+		return fmt.Errorf("unable to index DataSets: %w", err)
+	}
+
+	for obj, specOf := range workflowSpecs {
+		specOf := specOf
+		if err := indexer.IndexField(ctx, obj, WorkflowConnectionIndex, func(obj client.Object) []string {
+			var names []string
+			for _, key := range injectedConnections(obj, specOf(obj)) {
+				if key.Namespace == obj.GetNamespace() {
+					names = append(names, key.Name)
+				}
+			}
+			return names
+		}); err != nil {
+			return fmt.Errorf("unable to index %T: %w", obj, err)
+		}
+
+		if err := indexer.IndexField(ctx, obj, WorkflowGrantedConnectionIndex, func(obj client.Object) []string {
+			var keys []string
+			for _, key := range injectedConnections(obj, specOf(obj)) {
+				if key.Namespace != obj.GetNamespace() {
+					keys = append(keys, key.String())
+				}
+			}
+			return keys
+		}); err != nil {
+			return fmt.Errorf("unable to index %T: %w", obj, err)
+		}
+
+		if err := indexer.IndexField(ctx, obj, WorkflowDataSetIndex, func(obj client.Object) []string {
+			var names []string
+			for _, iv := range specOf(obj).InjectableValues {
+				if iv.DataSetRef.Name != "" {
+					names = append(names, iv.DataSetRef.Name)
+				}
+			}
+			return names
+		}); err != nil {
+			return fmt.Errorf("unable to index %T: %w", obj, err)
+		}
+	}
+
+	return nil
+}
+
+// injectedConnections returns the Connections referred to by the InjectableValues of the object
+func injectedConnections(obj client.Object, wfs v1alpha1.WorkflowSpec) []types.NamespacedName {
+	var keys []types.NamespacedName
+	for _, iv := range wfs.InjectableValues {
+		if iv.ConnectionRef.Name != "" {
+			keys = append(keys, iv.ConnectionRef.GetNamespacedName(obj.GetNamespace()))
+		}
+	}
+	return keys
+}
@@ -4,15 +4,20 @@ import (
 	"context"
 	"fmt"
 
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/dataworkz/kubeetl/api/v1alpha1"
 )
 
-//WorkflowLister lists and finds Workflows
+// WorkflowLister lists and finds Workflows
 type WorkflowLister interface {
-	List(ctx context.Context, namespace string) (*v1alpha1.WorkflowList, error)
+	List(ctx context.Context, namespace string, selector labels.Selector) (*v1alpha1.WorkflowList, error)
 	Find(ctx context.Context, namespace string, name string) (*v1alpha1.Workflow, error)
+	ListByConnection(ctx context.Context, connection types.NamespacedName) (*v1alpha1.WorkflowList, error)
+	ListByDataSet(ctx context.Context, namespace string, dataSet string) (*v1alpha1.WorkflowList, error)
 }
 
 type workflowLister struct {
@@ -25,28 +30,56 @@ func NewWorkflowLister(client client.Client) WorkflowLister {
 	}
 }
 
-// List returns a WorkflowList in the given namespace.
-func (l *workflowLister) List(ctx context.Context, namespace string) (*v1alpha1.WorkflowList, error) {
+// List returns a WorkflowList with the Workflows in the given namespace, or in all namespaces if the namespace is empty,
+// whose labels match the selector. A nil selector matches all Workflows.
+func (l *workflowLister) List(ctx context.Context, namespace string, selector labels.Selector) (*v1alpha1.WorkflowList, error) {
 	wfList := &v1alpha1.WorkflowList{}
-	if err := l.client.List(ctx, wfList, &client.ListOptions{Namespace: namespace}); err != nil {
+	if err := l.client.List(ctx, wfList, &client.ListOptions{Namespace: namespace, LabelSelector: selector}); err != nil {
 		return nil, fmt.Errorf("unable to list Workflows: %w", err)
 	}
 
 	return wfList, nil
 }
 
+// Find returns the Workflow with the given name in the namespace.
+// It returns a NotFound error, see errors.IsNotFound, if the Workflow does not exist.
 func (l *workflowLister) Find(ctx context.Context, namespace string, name string) (*v1alpha1.Workflow, error) {
-	wfList, err := l.List(ctx, namespace)
-	if err != nil {
-		return nil, err
+	wf := &v1alpha1.Workflow{}
+	if err := l.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, wf); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unable to get Workflow %s: %w", name, err)
 	}
 
-	var res *v1alpha1.Workflow
-	for _, wf := range wfList.Items {
-		if wf.Name == name {
-			res = &wf
-		}
+	return wf, nil
+}
+
+// ListByConnection returns a WorkflowList with the Workflows that inject the Connection, both in the namespace
+// of the Connection and in the namespaces it is granted to. The client must read from the cache of a manager
+// on which the indexes of SetupIndexes are registered.
+func (l *workflowLister) ListByConnection(ctx context.Context, connection types.NamespacedName) (*v1alpha1.WorkflowList, error) {
+	wfList := &v1alpha1.WorkflowList{}
+	if err := l.client.List(ctx, wfList, client.InNamespace(connection.Namespace), client.MatchingFields{WorkflowConnectionIndex: connection.Name}); err != nil {
+		return nil, fmt.Errorf("unable to list Workflows: %w", err)
+	}
+
+	granted := &v1alpha1.WorkflowList{}
+	if err := l.client.List(ctx, granted, client.MatchingFields{WorkflowGrantedConnectionIndex: connection.String()}); err != nil {
+		return nil, fmt.Errorf("unable to list Workflows: %w", err)
 	}
+	wfList.Items = append(wfList.Items, granted.Items...)
 
-	return res, nil
+	return wfList, nil
+}
+
+// ListByDataSet returns a WorkflowList with the Workflows in the namespace that inject the DataSet.
+// The client must read from the cache of a manager on which the indexes of SetupIndexes are registered.
+func (l *workflowLister) ListByDataSet(ctx context.Context, namespace string, dataSet string) (*v1alpha1.WorkflowList, error) {
+	wfList := &v1alpha1.WorkflowList{}
+	if err := l.client.List(ctx, wfList, client.InNamespace(namespace), client.MatchingFields{WorkflowDataSetIndex: dataSet}); err != nil {
+		return nil, fmt.Errorf("unable to list Workflows: %w", err)
+	}
+
+	return wfList, nil
 }
@@ -0,0 +1,85 @@
+package listers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+// WorkflowTemplateLister lists and finds WorkflowTemplates
+type WorkflowTemplateLister interface {
+	List(ctx context.Context, namespace string, selector labels.Selector) (*v1alpha1.WorkflowTemplateList, error)
+	Find(ctx context.Context, namespace string, name string) (*v1alpha1.WorkflowTemplate, error)
+	ListByConnection(ctx context.Context, connection types.NamespacedName) (*v1alpha1.WorkflowTemplateList, error)
+	ListByDataSet(ctx context.Context, namespace string, dataSet string) (*v1alpha1.WorkflowTemplateList, error)
+}
+
+type workflowTemplateLister struct {
+	client client.Client
+}
+
+func NewWorkflowTemplateLister(client client.Client) WorkflowTemplateLister {
+	return &workflowTemplateLister{
+		client: client,
+	}
+}
+
+// List returns a WorkflowTemplateList with the WorkflowTemplates in the given namespace, or in all namespaces if the namespace is empty,
+// whose labels match the selector. A nil selector matches all WorkflowTemplates.
+func (l *workflowTemplateLister) List(ctx context.Context, namespace string, selector labels.Selector) (*v1alpha1.WorkflowTemplateList, error) {
+	wftList := &v1alpha1.WorkflowTemplateList{}
+	if err := l.client.List(ctx, wftList, &client.ListOptions{Namespace: namespace, LabelSelector: selector}); err != nil {
+		return nil, fmt.Errorf("unable to list WorkflowTemplates: %w", err)
+	}
+
+	return wftList, nil
+}
+
+// Find returns the WorkflowTemplate with the given name in the namespace.
+// It returns a NotFound error, see errors.IsNotFound, if the WorkflowTemplate does not exist.
+func (l *workflowTemplateLister) Find(ctx context.Context, namespace string, name string) (*v1alpha1.WorkflowTemplate, error) {
+	wft := &v1alpha1.WorkflowTemplate{}
+	if err := l.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, wft); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unable to get WorkflowTemplate %s: %w", name, err)
+	}
+
+	return wft, nil
+}
+
+// ListByConnection returns a WorkflowTemplateList with the WorkflowTemplates that inject the Connection, both in the namespace
+// of the Connection and in the namespaces it is granted to. The client must read from the cache of a manager
+// on which the indexes of SetupIndexes are registered.
+func (l *workflowTemplateLister) ListByConnection(ctx context.Context, connection types.NamespacedName) (*v1alpha1.WorkflowTemplateList, error) {
+	wftList := &v1alpha1.WorkflowTemplateList{}
+	if err := l.client.List(ctx, wftList, client.InNamespace(connection.Namespace), client.MatchingFields{WorkflowConnectionIndex: connection.Name}); err != nil {
+		return nil, fmt.Errorf("unable to list WorkflowTemplates: %w", err)
+	}
+
+	granted := &v1alpha1.WorkflowTemplateList{}
+	if err := l.client.List(ctx, granted, client.MatchingFields{WorkflowGrantedConnectionIndex: connection.String()}); err != nil {
+		return nil, fmt.Errorf("unable to list WorkflowTemplates: %w", err)
+	}
+	wftList.Items = append(wftList.Items, granted.Items...)
+
+	return wftList, nil
+}
+
+// ListByDataSet returns a WorkflowTemplateList with the WorkflowTemplates in the namespace that inject the DataSet.
+// The client must read from the cache of a manager on which the indexes of SetupIndexes are registered.
+func (l *workflowTemplateLister) ListByDataSet(ctx context.Context, namespace string, dataSet string) (*v1alpha1.WorkflowTemplateList, error) {
+	wftList := &v1alpha1.WorkflowTemplateList{}
+	if err := l.client.List(ctx, wftList, client.InNamespace(namespace), client.MatchingFields{WorkflowDataSetIndex: dataSet}); err != nil {
+		return nil, fmt.Errorf("unable to list WorkflowTemplates: %w", err)
+	}
+
+	return wftList, nil
+}
@@ -0,0 +1,51 @@
+package listers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+var _ = Describe("WorkflowTemplateLister", func() {
+	var client client.Client
+	var wftl WorkflowTemplateLister
+	var ctx context.Context
+	BeforeEach(func() {
+		s := runtime.NewScheme()
+		s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.WorkflowTemplateList{}, &v1alpha1.WorkflowTemplate{})
+		_ = v1alpha1.AddToScheme(s)
+		client = fake.NewClientBuilder().WithScheme(s).Build()
+		wftl = NewWorkflowTemplateLister(client)
+		ctx = context.Background()
+	})
+
+	It("Should be able to find a WorkflowTemplate based on the type name", func() {
+		template, err := wftl.Find(ctx, "default", "test")
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(template).To(BeNil())
+		wft := &v1alpha1.WorkflowTemplate{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "etl.dataworkz.nl/v1alpha1",
+				Kind:       "WorkflowTemplate",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+		}
+		err = client.Create(ctx, wft)
+		Expect(err).To(Succeed())
+		template, err = wftl.Find(ctx, "default", "test")
+		Expect(err).To(Succeed())
+		Expect(template).To(Not(BeNil()))
+	})
+})
@@ -6,6 +6,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -29,7 +30,7 @@ var _ = Describe("WorkflowLister", func() {
 
 	It("Should be able to find a Workflow based on the type name", func() {
 		workflow, err := wfl.Find(ctx, "default", "test")
-		Expect(err).To(Succeed())
+		Expect(errors.IsNotFound(err)).To(BeTrue())
 		Expect(workflow).To(BeNil())
 		wf := &v1alpha1.Workflow{
 			TypeMeta: metav1.TypeMeta{
@@ -23,6 +23,7 @@ type ControllerManager struct {
 	scheme              *runtime.Scheme
 	schemasRegistration []SchemeRegistration
 
+	indexRegistration     []IndexRegistration
 	reconcilerRegstration []ReconcilerRegistration
 
 	webhooksEnabled     bool
@@ -49,7 +50,7 @@ func (cm *ControllerManager) setDefaults() {
 // Init initializes all components of the KubeETL operator:
 // - It configures defaults for the CM and applies all options
 // - It adds the KubeETL schemas to the runtime.Scheme
-// - It registers all reconcilers & webhooks
+// - It registers all indexes, reconcilers & webhooks
 func (cm *ControllerManager) init() error {
 	cm.log.Info("initializing controller-manager")
 	cm.setDefaults()
@@ -77,6 +78,13 @@ func (cm *ControllerManager) init() error {
 	}
 	cm.mgr = mgr
 
+	cm.log.Info("registering indexes")
+	for _, registerIndex := range cm.indexRegistration {
+		if err := registerIndex(mgr); err != nil {
+			return fmt.Errorf("could not register index: %v", err)
+		}
+	}
+
 	cm.log.Info("registering reconcilers")
 	for _, registerReconciler := range cm.reconcilerRegstration {
 		if err := registerReconciler(mgr); err != nil {
@@ -157,6 +165,14 @@ func WithSchemas(schemas ...SchemeRegistration) ControllerManagerOpts {
 	}
 }
 
+type IndexRegistration func(ctrl.Manager) error
+
+func WithIndexes(indexes ...IndexRegistration) ControllerManagerOpts {
+	return func(cm *ControllerManager) {
+		cm.indexRegistration = indexes
+	}
+}
+
 type ReconcilerRegistration func(ctrl.Manager) error
 
 func WithReconcilers(reconcilers ...ReconcilerRegistration) ControllerManagerOpts {
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AccessReviewer checks whether a service account may read the resources KubeETL dereferences on its behalf
+type AccessReviewer interface {
+	// ReviewAccess returns an *AccessDeniedError if the service account in the given namespace
+	// is not allowed to get the resource described by the attributes
+	ReviewAccess(ctx context.Context, namespace, serviceAccount string, attributes authorizationv1.ResourceAttributes) error
+}
+
+// AccessDeniedError is returned by an AccessReviewer when a service account is not allowed to get a resource
+type AccessDeniedError struct {
+	ServiceAccount string
+	Namespace      string
+	Attributes     authorizationv1.ResourceAttributes
+	Reason         string
+}
+
+func (e *AccessDeniedError) Error() string {
+	resource := e.Attributes.Resource
+	if e.Attributes.Group != "" {
+		resource = fmt.Sprintf("%s.%s", resource, e.Attributes.Group)
+	}
+	msg := fmt.Sprintf("service account %s/%s is not allowed to %s %s %s/%s",
+		e.Namespace, e.ServiceAccount, e.Attributes.Verb, resource, e.Attributes.Namespace, e.Attributes.Name)
+	if e.Reason != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Reason)
+	}
+	return msg
+}
+
+// IsAccessDenied returns true if the error, or any error it wraps, is an *AccessDeniedError
+func IsAccessDenied(err error) bool {
+	var denied *AccessDeniedError
+	return errors.As(err, &denied)
+}
+
+type subjectAccessReviewer struct {
+	client client.Client
+}
+
+// NewSubjectAccessReviewer returns an AccessReviewer that creates a SubjectAccessReview for every check
+func NewSubjectAccessReviewer(client client.Client) AccessReviewer {
+	return &subjectAccessReviewer{
+		client: client,
+	}
+}
+
+func (r *subjectAccessReviewer) ReviewAccess(ctx context.Context, namespace, serviceAccount string, attributes authorizationv1.ResourceAttributes) error {
+	if attributes.Verb == "" {
+		attributes.Verb = "get"
+	}
+
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:               fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccount),
+			Groups:             []string{"system:serviceaccounts", "system:serviceaccounts:" + namespace},
+			ResourceAttributes: &attributes,
+		},
+	}
+	if err := r.client.Create(ctx, review); err != nil {
+		return fmt.Errorf("failed to review access of service account %s/%s: %w", namespace, serviceAccount, err)
+	}
+
+	if !review.Status.Allowed || review.Status.Denied {
+		return &AccessDeniedError{
+			ServiceAccount: serviceAccount,
+			Namespace:      namespace,
+			Attributes:     attributes,
+			Reason:         review.Status.Reason,
+		}
+	}
+	return nil
+}
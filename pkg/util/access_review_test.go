@@ -0,0 +1,58 @@
+package util
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reviewClient answers SubjectAccessReviews the way the API server does and remembers the last review
+type reviewClient struct {
+	client.Client
+	allowed bool
+	review  *authorizationv1.SubjectAccessReview
+}
+
+func (c *reviewClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.review = obj.(*authorizationv1.SubjectAccessReview)
+	c.review.Status.Allowed = c.allowed
+	return nil
+}
+
+var _ = Describe("AccessReviewer", func() {
+	attributes := authorizationv1.ResourceAttributes{
+		Namespace: "default",
+		Resource:  "secrets",
+		Name:      "mysql",
+	}
+
+	It("Should review access as the service account", func() {
+		c := &reviewClient{allowed: true}
+		Expect(NewSubjectAccessReviewer(c).ReviewAccess(context.Background(), "default", "injector", attributes)).To(Succeed())
+		Expect(c.review.Spec.User).To(Equal("system:serviceaccount:default:injector"))
+		Expect(c.review.Spec.Groups).To(ConsistOf("system:serviceaccounts", "system:serviceaccounts:default"))
+		Expect(c.review.Spec.ResourceAttributes.Verb).To(Equal("get"))
+	})
+
+	It("Should deny access the SubjectAccessReview does not allow", func() {
+		err := NewSubjectAccessReviewer(&reviewClient{}).ReviewAccess(context.Background(), "default", "injector", attributes)
+		Expect(IsAccessDenied(err)).To(BeTrue())
+		Expect(err.Error()).To(Equal("service account default/injector is not allowed to get secrets default/mysql"))
+	})
+
+	It("Should recognize wrapped AccessDeniedErrors", func() {
+		err := fmt.Errorf("failed to render connection secret: %w", &AccessDeniedError{
+			ServiceAccount: "injector",
+			Namespace:      "default",
+			Attributes:     attributes,
+			Reason:         "no RBAC policy matched",
+		})
+		Expect(IsAccessDenied(err)).To(BeTrue())
+		Expect(err.Error()).To(HaveSuffix("secrets default/mysql: no RBAC policy matched"))
+		Expect(IsAccessDenied(fmt.Errorf("not found"))).To(BeFalse())
+	})
+})
@@ -0,0 +1,314 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+const (
+	// webhookIssuerTimeout is the maximum duration of a request to a webhook credential issuer
+	webhookIssuerTimeout = 10 * time.Second
+)
+
+// IssuedCredentials are short-lived credentials issued by a CredentialIssuer
+type IssuedCredentials struct {
+	// Values of the issued credentials, keyed by field name
+	Values map[string]string
+	// LeaseID identifies the credentials when revoking them
+	LeaseID string
+	// TTL is the lifetime the issuer granted, which may be shorter than requested
+	TTL time.Duration
+}
+
+// CredentialIssuer issues and revokes short-lived credentials of a Connection
+type CredentialIssuer interface {
+	// Issue returns new credentials that expire after the TTL
+	Issue(ctx context.Context, ttl time.Duration) (*IssuedCredentials, error)
+	// Revoke revokes the credentials with the given lease before they expire
+	Revoke(ctx context.Context, leaseID string) error
+}
+
+// CredentialIssuerRule allows the Connections it selects to issue credentials from the given Vault roles and webhook URLs
+type CredentialIssuerRule struct {
+	// Namespaces of the Connections the rule applies to. The rule applies to all namespaces if empty.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// ConnectionTypes of the Connections the rule applies to. The rule applies to all types if empty.
+	ConnectionTypes []string `json:"connectionTypes,omitempty"`
+	// VaultRoles are the Vault database roles, as mount/role, the Connections may issue credentials for.
+	// A role may contain the patterns of path.Match, e.g. database/team-a-*.
+	VaultRoles []string `json:"vaultRoles,omitempty"`
+	// WebhookURLs are the URLs of the webhook credential issuers the Connections may call
+	WebhookURLs []string `json:"webhookURLs,omitempty"`
+}
+
+// selects returns true if the rule applies to the Connection
+func (r CredentialIssuerRule) selects(conn *v1alpha1.Connection) bool {
+	return (len(r.Namespaces) == 0 || containsString(r.Namespaces, conn.Namespace)) &&
+		(len(r.ConnectionTypes) == 0 || containsString(r.ConnectionTypes, conn.Spec.Type))
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	credentialIssuerRulesMu sync.RWMutex
+	credentialIssuerRules   []CredentialIssuerRule
+)
+
+// SetCredentialIssuerRules replaces the rules that allow Connections to issue credentials.
+// Without rules no Connection may issue credentials.
+func SetCredentialIssuerRules(rules []CredentialIssuerRule) {
+	credentialIssuerRulesMu.Lock()
+	defer credentialIssuerRulesMu.Unlock()
+	credentialIssuerRules = rules
+}
+
+// AllowCredentialIssuer returns an error unless a CredentialIssuerRule allows the Connection to issue credentials
+// from the Vault role or webhook URL it configures. Only the operator configures the rules, so a Connection
+// cannot make the manager issue credentials of Vault roles or call URLs that are not meant for its namespace.
+func AllowCredentialIssuer(conn *v1alpha1.Connection) error {
+	ci := conn.Spec.CredentialIssuer
+	if ci == nil {
+		return fmt.Errorf("Connection %s does not have a credential issuer", conn.Name)
+	}
+
+	credentialIssuerRulesMu.RLock()
+	defer credentialIssuerRulesMu.RUnlock()
+	for _, rule := range credentialIssuerRules {
+		if !rule.selects(conn) {
+			continue
+		}
+		switch {
+		case ci.Vault != nil:
+			role := vaultRole(ci.Vault)
+			for _, pattern := range rule.VaultRoles {
+				if ok, _ := path.Match(strings.Trim(pattern, "/"), role); ok {
+					return nil
+				}
+			}
+		case ci.Webhook != nil:
+			if containsString(rule.WebhookURLs, ci.Webhook.URL) {
+				return nil
+			}
+		}
+	}
+
+	switch {
+	case ci.Vault != nil:
+		return fmt.Errorf("Connection %s is not allowed to issue credentials for vault role %s", conn.Name, vaultRole(ci.Vault))
+	case ci.Webhook != nil:
+		return fmt.Errorf("Connection %s is not allowed to issue credentials from %s", conn.Name, ci.Webhook.URL)
+	}
+	return fmt.Errorf("credential issuer of Connection %s does not configure vault or webhook", conn.Name)
+}
+
+// vaultMount returns the mount of the database secrets engine of the issuer
+func vaultMount(vault *v1alpha1.VaultCredentialIssuer) string {
+	if vault.Mount == "" {
+		return "database"
+	}
+	return strings.Trim(vault.Mount, "/")
+}
+
+// vaultRole returns the database role of the issuer as mount/role
+func vaultRole(vault *v1alpha1.VaultCredentialIssuer) string {
+	return vaultMount(vault) + "/" + vault.Role
+}
+
+// NewCredentialIssuer returns the CredentialIssuer configured on the Connection
+func NewCredentialIssuer(cl client.Client, conn *v1alpha1.Connection) (CredentialIssuer, error) {
+	ci := conn.Spec.CredentialIssuer
+	switch {
+	case ci == nil:
+		return nil, fmt.Errorf("Connection %s does not have a credential issuer", conn.Name)
+	case ci.Vault != nil:
+		backend, err := lookupSecretBackend(v1alpha1.SecretBackendVault)
+		if err != nil {
+			return nil, err
+		}
+		vault, ok := backend.(*vaultBackend)
+		if !ok {
+			return nil, errors.New("vault credential issuer requires the Vault secret backend")
+		}
+		return &vaultCredentialIssuer{
+			vault: vault,
+			mount: vaultMount(ci.Vault),
+			role:  ci.Vault.Role,
+		}, nil
+	case ci.Webhook != nil:
+		return &webhookCredentialIssuer{
+			client:     cl,
+			connection: conn,
+			config:     *ci.Webhook,
+			http:       &http.Client{Timeout: webhookIssuerTimeout},
+		}, nil
+	}
+	return nil, fmt.Errorf("credential issuer of Connection %s does not configure vault or webhook", conn.Name)
+}
+
+// vaultCredentialIssuer issues credentials from a Vault database secrets engine
+type vaultCredentialIssuer struct {
+	vault *vaultBackend
+	mount string
+	role  string
+}
+
+// vaultLease is the part of a Vault response that describes a lease
+type vaultLease struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int64                  `json:"lease_duration"`
+	Data          map[string]interface{} `json:"data"`
+}
+
+func (i *vaultCredentialIssuer) Issue(ctx context.Context, ttl time.Duration) (*IssuedCredentials, error) {
+	body, status, err := i.vault.request(ctx, http.MethodGet, fmt.Sprintf("%s/creds/%s", escapePath(i.mount), url.PathEscape(i.role)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue credentials for vault role %s: %w", i.role, err)
+	}
+	if status == http.StatusNotFound {
+		return nil, fmt.Errorf("vault role %s not found", i.role)
+	}
+
+	var lease vaultLease
+	if err := json.Unmarshal(body, &lease); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials for vault role %s: %w", i.role, err)
+	}
+
+	// the role determines the initial lifetime, which is extended up to the maximum TTL of the role if the run needs longer
+	if granted := time.Duration(lease.LeaseDuration) * time.Second; granted < ttl {
+		renewal := map[string]interface{}{"lease_id": lease.LeaseID, "increment": int64(ttl.Seconds())}
+		body, _, err := i.vault.request(ctx, http.MethodPut, "sys/leases/renew", renewal)
+		if err != nil {
+			return nil, fmt.Errorf("failed to renew credentials for vault role %s: %w", i.role, err)
+		}
+		var renewed vaultLease
+		if err := json.Unmarshal(body, &renewed); err != nil {
+			return nil, fmt.Errorf("failed to parse renewed credentials for vault role %s: %w", i.role, err)
+		}
+		lease.LeaseDuration = renewed.LeaseDuration
+	}
+
+	values := make(map[string]string, len(lease.Data))
+	for k, v := range lease.Data {
+		values[k] = fmt.Sprint(v)
+	}
+	return &IssuedCredentials{
+		Values:  values,
+		LeaseID: lease.LeaseID,
+		TTL:     time.Duration(lease.LeaseDuration) * time.Second,
+	}, nil
+}
+
+func (i *vaultCredentialIssuer) Revoke(ctx context.Context, leaseID string) error {
+	if _, _, err := i.vault.request(ctx, http.MethodPut, "sys/leases/revoke", map[string]string{"lease_id": leaseID}); err != nil {
+		return fmt.Errorf("failed to revoke vault lease %s: %w", leaseID, err)
+	}
+	return nil
+}
+
+// webhookCredentialIssuer issues credentials by calling an HTTP endpoint
+type webhookCredentialIssuer struct {
+	client     client.Client
+	connection *v1alpha1.Connection
+	config     v1alpha1.WebhookCredentialIssuer
+	http       *http.Client
+}
+
+type webhookIssuerRequest struct {
+	Action     string `json:"action"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	TTLSeconds int64  `json:"ttlSeconds,omitempty"`
+	LeaseID    string `json:"leaseID,omitempty"`
+}
+
+type webhookIssuerResponse struct {
+	LeaseID     string            `json:"leaseID"`
+	TTLSeconds  int64             `json:"ttlSeconds"`
+	Credentials map[string]string `json:"credentials"`
+}
+
+func (i *webhookCredentialIssuer) Issue(ctx context.Context, ttl time.Duration) (*IssuedCredentials, error) {
+	body, err := i.call(ctx, webhookIssuerRequest{Action: "issue", TTLSeconds: int64(ttl.Seconds())})
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue credentials for Connection %s: %w", i.connection.Name, err)
+	}
+
+	var resp webhookIssuerResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials for Connection %s: %w", i.connection.Name, err)
+	}
+	if resp.TTLSeconds <= 0 || resp.TTLSeconds > int64(ttl.Seconds()) {
+		resp.TTLSeconds = int64(ttl.Seconds())
+	}
+	return &IssuedCredentials{
+		Values:  resp.Credentials,
+		LeaseID: resp.LeaseID,
+		TTL:     time.Duration(resp.TTLSeconds) * time.Second,
+	}, nil
+}
+
+func (i *webhookCredentialIssuer) Revoke(ctx context.Context, leaseID string) error {
+	if _, err := i.call(ctx, webhookIssuerRequest{Action: "revoke", LeaseID: leaseID}); err != nil {
+		return fmt.Errorf("failed to revoke lease %s of Connection %s: %w", leaseID, i.connection.Name, err)
+	}
+	return nil
+}
+
+// call posts the request for the Connection to the endpoint and returns the response body
+func (i *webhookCredentialIssuer) call(ctx context.Context, issuerReq webhookIssuerRequest) ([]byte, error) {
+	issuerReq.Namespace = i.connection.Namespace
+	issuerReq.Name = i.connection.Name
+	data, err := json.Marshal(issuerReq)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.config.URL, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if i.config.TokenSecretRef != nil {
+		token, err := readSecretKey(ctx, i.client, i.connection.Namespace, i.config.TokenSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(token))
+	}
+
+	resp, err := i.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("%s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
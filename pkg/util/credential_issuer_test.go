@@ -0,0 +1,149 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+var _ = Describe("CredentialIssuer", func() {
+	var server *httptest.Server
+	var requests []map[string]interface{}
+	var paths []string
+
+	BeforeEach(func() {
+		requests = nil
+		paths = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.Method+" "+r.URL.Path)
+			var body map[string]interface{}
+			if data, _ := ioutil.ReadAll(r.Body); len(data) > 0 {
+				_ = json.Unmarshal(data, &body)
+			}
+			requests = append(requests, body)
+
+			switch r.URL.Path {
+			case "/v1/database/creds/etl":
+				_, _ = w.Write([]byte(`{"lease_id":"database/creds/etl/1","lease_duration":300,"data":{"username":"v-etl-1","password":"p4ss"}}`))
+			case "/v1/sys/leases/renew":
+				_, _ = w.Write([]byte(`{"lease_id":"database/creds/etl/1","lease_duration":1800}`))
+			case "/v1/sys/leases/revoke":
+				w.WriteHeader(http.StatusNoContent)
+			case "/issuer":
+				if r.Header.Get("Authorization") != "" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = w.Write([]byte(`{"leaseID":"webhook-1","ttlSeconds":60,"credentials":{"password":"p4ss"}}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	connection := func(ci v1alpha1.CredentialIssuer) *v1alpha1.Connection {
+		return &v1alpha1.Connection{
+			ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "default"},
+			Spec:       v1alpha1.ConnectionSpec{CredentialIssuer: &ci},
+		}
+	}
+
+	It("Should issue database credentials from Vault and extend the lease to the TTL", func() {
+		dir, err := ioutil.TempDir("", "vault")
+		Expect(err).To(Succeed())
+		defer os.RemoveAll(dir)
+		tokenFile := filepath.Join(dir, "token")
+		Expect(ioutil.WriteFile(tokenFile, []byte("dev-token"), 0600)).To(Succeed())
+
+		backend, err := NewVaultBackend(VaultConfig{Address: server.URL, TokenFile: tokenFile})
+		Expect(err).To(Succeed())
+		RegisterSecretBackend(v1alpha1.SecretBackendVault, backend)
+		defer func() {
+			secretBackendsMu.Lock()
+			delete(secretBackends, v1alpha1.SecretBackendVault)
+			secretBackendsMu.Unlock()
+		}()
+
+		issuer, err := NewCredentialIssuer(nil, connection(v1alpha1.CredentialIssuer{Vault: &v1alpha1.VaultCredentialIssuer{Role: "etl"}}))
+		Expect(err).To(Succeed())
+
+		issued, err := issuer.Issue(context.Background(), 30*time.Minute)
+		Expect(err).To(Succeed())
+		Expect(issued.Values).To(Equal(map[string]string{"username": "v-etl-1", "password": "p4ss"}))
+		Expect(issued.LeaseID).To(Equal("database/creds/etl/1"))
+		Expect(issued.TTL).To(Equal(30 * time.Minute))
+		Expect(requests[1]).To(HaveKeyWithValue("increment", BeNumerically("==", 1800)))
+
+		Expect(issuer.Revoke(context.Background(), issued.LeaseID)).To(Succeed())
+		Expect(paths).To(Equal([]string{"GET /v1/database/creds/etl", "PUT /v1/sys/leases/renew", "PUT /v1/sys/leases/revoke"}))
+		Expect(requests[2]).To(HaveKeyWithValue("lease_id", "database/creds/etl/1"))
+	})
+
+	It("Should issue credentials from a webhook and cap the TTL", func() {
+		issuer, err := NewCredentialIssuer(nil, connection(v1alpha1.CredentialIssuer{Webhook: &v1alpha1.WebhookCredentialIssuer{URL: server.URL + "/issuer"}}))
+		Expect(err).To(Succeed())
+
+		issued, err := issuer.Issue(context.Background(), 10*time.Second)
+		Expect(err).To(Succeed())
+		Expect(issued.Values).To(HaveKeyWithValue("password", "p4ss"))
+		Expect(issued.TTL).To(Equal(10 * time.Second))
+		Expect(requests[0]).To(HaveKeyWithValue("action", "issue"))
+		Expect(requests[0]).To(HaveKeyWithValue("name", "mysql"))
+		Expect(requests[0]).To(HaveKeyWithValue("ttlSeconds", BeNumerically("==", 10)))
+
+		Expect(issuer.Revoke(context.Background(), issued.LeaseID)).To(Succeed())
+		Expect(requests[1]).To(HaveKeyWithValue("action", "revoke"))
+		Expect(requests[1]).To(HaveKeyWithValue("leaseID", "webhook-1"))
+	})
+
+	It("Should require the Vault secret backend for a Vault issuer", func() {
+		_, err := NewCredentialIssuer(nil, connection(v1alpha1.CredentialIssuer{Vault: &v1alpha1.VaultCredentialIssuer{Role: "etl"}}))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should only allow the Vault roles and webhook URLs the rules configure for the Connection", func() {
+		dir, err := ioutil.TempDir("", "issuers")
+		Expect(err).To(Succeed())
+		defer os.RemoveAll(dir)
+		config := filepath.Join(dir, "backends.yaml")
+		Expect(ioutil.WriteFile(config, []byte(`credentialIssuers:
+- namespaces: [default]
+  vaultRoles: [database/etl-*]
+- connectionTypes: [MySQL]
+  webhookURLs: [https://issuer.example.com/mysql]
+`), 0600)).To(Succeed())
+		Expect(LoadSecretBackends(config)).To(Succeed())
+		defer SetCredentialIssuerRules(nil)
+
+		Expect(AllowCredentialIssuer(connection(v1alpha1.CredentialIssuer{Vault: &v1alpha1.VaultCredentialIssuer{Role: "etl-reader"}}))).To(Succeed())
+		Expect(AllowCredentialIssuer(connection(v1alpha1.CredentialIssuer{Vault: &v1alpha1.VaultCredentialIssuer{Role: "admin"}}))).
+			To(MatchError(ContainSubstring("not allowed to issue credentials for vault role database/admin")))
+		Expect(AllowCredentialIssuer(connection(v1alpha1.CredentialIssuer{Vault: &v1alpha1.VaultCredentialIssuer{Mount: "other", Role: "etl-reader"}}))).ToNot(Succeed())
+
+		webhook := connection(v1alpha1.CredentialIssuer{Webhook: &v1alpha1.WebhookCredentialIssuer{URL: "https://issuer.example.com/mysql"}})
+		Expect(AllowCredentialIssuer(webhook)).ToNot(Succeed())
+		webhook.Spec.Type = "MySQL"
+		Expect(AllowCredentialIssuer(webhook)).To(Succeed())
+		webhook.Spec.CredentialIssuer.Webhook.URL = "http://169.254.169.254/latest/meta-data"
+		Expect(AllowCredentialIssuer(webhook)).To(MatchError(ContainSubstring("not allowed to issue credentials from")))
+
+		By("Denying all issuers without rules")
+		SetCredentialIssuerRules(nil)
+		Expect(AllowCredentialIssuer(connection(v1alpha1.CredentialIssuer{Vault: &v1alpha1.VaultCredentialIssuer{Role: "etl-reader"}}))).ToNot(Succeed())
+	})
+})
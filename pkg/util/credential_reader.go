@@ -75,8 +75,11 @@ func readValueSource(ctx context.Context, cl client.Client, namespace string, va
 	if valueSource.SecretKeyRef != nil {
 		return readSecretKey(ctx, cl, namespace, valueSource.SecretKeyRef)
 	}
+	if valueSource.ExternalRef != nil {
+		return readExternalKey(ctx, namespace, valueSource.ExternalRef)
+	}
 
-	return "", errors.New("no configmapkeyref or secretkeyref found")
+	return "", errors.New("no configmapkeyref, secretkeyref or externalref found")
 }
 
 func readSecretKey(ctx context.Context, cl client.Client, namespace string, selector *corev1.SecretKeySelector) (string, error) {
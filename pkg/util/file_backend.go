@@ -0,0 +1,64 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+// FileBackendConfig configures the File secret backend
+type FileBackendConfig struct {
+	// Root is the directory the paths of ExternalRefs are relative to
+	Root string `json:"root"`
+}
+
+type fileBackend struct {
+	root string
+}
+
+// NewFileBackend returns a SecretBackend that reads the key of a secret from the file <root>/<namespace>/<path>/<key>,
+// the layout in which Kubernetes, secrets store CSI drivers and Vault agents mount secrets
+func NewFileBackend(config FileBackendConfig) (SecretBackend, error) {
+	if config.Root == "" {
+		return nil, errors.New("file secret backend requires a root directory")
+	}
+	root, err := filepath.Abs(config.Root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid root directory %s: %w", config.Root, err)
+	}
+
+	return &fileBackend{
+		root: root,
+	}, nil
+}
+
+func (b *fileBackend) ReadKey(ctx context.Context, namespace, path, key string) (string, error) {
+	secretPath, err := namespacedSecretPath(namespace, path)
+	if err != nil {
+		return "", err
+	}
+	if err := v1alpha1.ValidateExternalFileKey(key); err != nil {
+		return "", fmt.Errorf("invalid key %s of secret %s: %w", key, path, err)
+	}
+
+	dir := filepath.Join(b.root, namespace)
+	name := filepath.Join(b.root, filepath.FromSlash(secretPath), key)
+	if !strings.HasPrefix(name, dir+string(os.PathSeparator)) {
+		return "", fmt.Errorf("key %s of secret %s is outside of the directory of namespace %s", key, path, namespace)
+	}
+
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("key %s not found in secret %s", key, path)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,58 @@
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("File SecretBackend", func() {
+	var root string
+	var backend SecretBackend
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "secrets")
+		Expect(err).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(root, "default", "mysql"), 0700)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(root, "default", "mysql", "password"), []byte("s3cr3t"), 0600)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(filepath.Dir(root), "outside"), []byte("outside"), 0600)).To(Succeed())
+
+		backend, err = NewFileBackend(FileBackendConfig{Root: root})
+		Expect(err).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(root)).To(Succeed())
+		Expect(os.Remove(filepath.Join(filepath.Dir(root), "outside"))).To(Succeed())
+	})
+
+	It("Should read the file of a key", func() {
+		value, err := backend.ReadKey(context.Background(), "default", "mysql", "password")
+		Expect(err).To(Succeed())
+		Expect(value).To(Equal("s3cr3t"))
+	})
+
+	It("Should return an error for a missing key", func() {
+		_, err := backend.ReadKey(context.Background(), "default", "mysql", "username")
+		Expect(err).To(MatchError("key username not found in secret mysql"))
+	})
+
+	It("Should not read files outside of the directory of the namespace", func() {
+		_, err := backend.ReadKey(context.Background(), "other", "../default/mysql", "password")
+		Expect(err).To(MatchError("invalid secret path ../default/mysql: path must not refer to a parent directory"))
+
+		_, err = backend.ReadKey(context.Background(), "default", "mysql", "../../../outside")
+		Expect(err).To(MatchError("invalid key ../../../outside of secret mysql: key must be a file name"))
+
+		_, err = backend.ReadKey(context.Background(), "..", "mysql", "password")
+		Expect(err).To(MatchError(`invalid namespace ".." for secret mysql`))
+
+		_, err = backend.ReadKey(context.Background(), "other", "mysql", "password")
+		Expect(err).To(MatchError("key password not found in secret mysql"))
+	})
+})
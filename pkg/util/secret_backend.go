@@ -0,0 +1,112 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+// SecretBackend reads secrets that are stored outside of Kubernetes.
+// The secrets of a namespace are confined to the directory of that namespace in the backend,
+// so a Connection or DataSet can only refer to the secrets stored for its own namespace.
+type SecretBackend interface {
+	// ReadKey returns the value of the key in the secret at the given path below the directory of the namespace
+	ReadKey(ctx context.Context, namespace, path, key string) (string, error)
+}
+
+var (
+	secretBackendsMu sync.RWMutex
+	secretBackends   = map[v1alpha1.SecretBackendType]SecretBackend{}
+)
+
+// RegisterSecretBackend makes the backend available to ValueSources with an ExternalRef to the given backend type.
+// Registering a backend for a type that already has one replaces it.
+func RegisterSecretBackend(backendType v1alpha1.SecretBackendType, backend SecretBackend) {
+	secretBackendsMu.Lock()
+	defer secretBackendsMu.Unlock()
+	secretBackends[backendType] = backend
+}
+
+func lookupSecretBackend(backendType v1alpha1.SecretBackendType) (SecretBackend, error) {
+	secretBackendsMu.RLock()
+	defer secretBackendsMu.RUnlock()
+	backend, ok := secretBackends[backendType]
+	if !ok {
+		return nil, fmt.Errorf("secret backend %s is not configured", backendType)
+	}
+	return backend, nil
+}
+
+// SecretBackendConfig configures the external secret backends
+type SecretBackendConfig struct {
+	// Vault configures the Vault backend
+	Vault *VaultConfig `json:"vault,omitempty"`
+	// File configures the File backend
+	File *FileBackendConfig `json:"file,omitempty"`
+	// CredentialIssuers are the rules that allow Connections to issue credentials.
+	// Without rules no Connection may issue credentials.
+	CredentialIssuers []CredentialIssuerRule `json:"credentialIssuers,omitempty"`
+}
+
+// LoadSecretBackends reads a SecretBackendConfig from a YAML file and registers the backends
+// and the credential issuer rules it configures
+func LoadSecretBackends(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read secret backend config: %w", err)
+	}
+
+	var config SecretBackendConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return fmt.Errorf("unable to parse secret backend config: %w", err)
+	}
+
+	if config.Vault != nil {
+		backend, err := NewVaultBackend(*config.Vault)
+		if err != nil {
+			return err
+		}
+		RegisterSecretBackend(v1alpha1.SecretBackendVault, backend)
+	}
+	if config.File != nil {
+		backend, err := NewFileBackend(*config.File)
+		if err != nil {
+			return err
+		}
+		RegisterSecretBackend(v1alpha1.SecretBackendFile, backend)
+	}
+	SetCredentialIssuerRules(config.CredentialIssuers)
+	return nil
+}
+
+func readExternalKey(ctx context.Context, namespace string, selector *v1alpha1.ExternalSecretKeySelector) (string, error) {
+	backend, err := lookupSecretBackend(selector.Backend)
+	if err != nil {
+		return "", err
+	}
+
+	value, err := backend.ReadKey(ctx, namespace, selector.Path, selector.Key)
+	if err != nil {
+		return "", fmt.Errorf("readExternalKey failed: %w", err)
+	}
+	return value, nil
+}
+
+// namespacedSecretPath returns the path of the secret below the directory of the namespace.
+// Absolute paths and paths that refer to a parent directory are rejected, as they could escape the directory of the namespace.
+func namespacedSecretPath(namespace, secretPath string) (string, error) {
+	if namespace == "" || strings.Contains(namespace, "/") || namespace == "." || namespace == ".." {
+		return "", fmt.Errorf("invalid namespace %q for secret %s", namespace, secretPath)
+	}
+	if err := v1alpha1.ValidateExternalPath(secretPath); err != nil {
+		return "", fmt.Errorf("invalid secret path %s: %w", secretPath, err)
+	}
+	return path.Join(namespace, secretPath), nil
+}
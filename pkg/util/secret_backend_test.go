@@ -0,0 +1,64 @@
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/dataworkz/kubeetl/api/v1alpha1"
+)
+
+var _ = Describe("LoadSecretBackends", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "backends")
+		Expect(err).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(dir, "secrets", "default", "mysql"), 0700)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "secrets", "default", "mysql", "password"), []byte("s3cr3t"), 0600)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("Should register the configured backends for ExternalRefs", func() {
+		config := filepath.Join(dir, "backends.yaml")
+		Expect(ioutil.WriteFile(config, []byte("file:\n  root: "+filepath.Join(dir, "secrets")+"\n"), 0600)).To(Succeed())
+		Expect(LoadSecretBackends(config)).To(Succeed())
+
+		conn := &v1alpha1.Connection{
+			ObjectMeta: metav1.ObjectMeta{Name: "external", Namespace: "default"},
+			Spec: v1alpha1.ConnectionSpec{
+				Credentials: v1alpha1.Credentials{
+					"password": v1alpha1.Value{ValueFrom: &v1alpha1.ValueSource{
+						ExternalRef: &v1alpha1.ExternalSecretKeySelector{Backend: v1alpha1.SecretBackendFile, Path: "mysql", Key: "password"},
+					}},
+					"token": v1alpha1.Value{ValueFrom: &v1alpha1.ValueSource{
+						ExternalRef: &v1alpha1.ExternalSecretKeySelector{Backend: "Unknown", Path: "mysql", Key: "token"},
+					}},
+				},
+			},
+		}
+		reader := NewCredentialReader(nil, conn)
+
+		value, err := reader.ReadValue(context.Background(), "password")
+		Expect(err).To(Succeed())
+		Expect(value).To(Equal("s3cr3t"))
+
+		_, err = reader.ReadValue(context.Background(), "token")
+		Expect(err).To(MatchError("secret backend Unknown is not configured"))
+	})
+
+	It("Should reject unknown configuration", func() {
+		config := filepath.Join(dir, "backends.yaml")
+		Expect(ioutil.WriteFile(config, []byte("keychain: {}\n"), 0600)).To(Succeed())
+		Expect(LoadSecretBackends(config)).ToNot(Succeed())
+	})
+})
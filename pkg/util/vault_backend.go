@@ -0,0 +1,203 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// vaultRequestTimeout is the maximum duration of a request to Vault
+	vaultRequestTimeout = 10 * time.Second
+)
+
+// VaultConfig configures the Vault secret backend
+type VaultConfig struct {
+	// Address of the Vault server. Defaults to the VAULT_ADDR environment variable.
+	Address string `json:"address,omitempty"`
+	// Mount is the path the KV secrets engine is mounted at. Defaults to secret.
+	Mount string `json:"mount,omitempty"`
+	// KVVersion is the version of the KV secrets engine, 1 or 2. Defaults to 2.
+	KVVersion int `json:"kvVersion,omitempty"`
+	// Namespace is the Vault Enterprise namespace of the secrets engine
+	Namespace string `json:"namespace,omitempty"`
+	// TokenFile is read for every request, so the token can be renewed by a Vault agent.
+	// Defaults to the VAULT_TOKEN environment variable.
+	TokenFile string `json:"tokenFile,omitempty"`
+}
+
+type vaultBackend struct {
+	config VaultConfig
+	client *http.Client
+}
+
+// NewVaultBackend returns a SecretBackend that reads secrets from a HashiCorp Vault KV secrets engine
+func NewVaultBackend(config VaultConfig) (SecretBackend, error) {
+	if config.Address == "" {
+		config.Address = os.Getenv("VAULT_ADDR")
+	}
+	if config.Address == "" {
+		return nil, errors.New("vault secret backend requires an address")
+	}
+	if _, err := url.Parse(config.Address); err != nil {
+		return nil, fmt.Errorf("invalid vault address: %w", err)
+	}
+	if config.Mount == "" {
+		config.Mount = "secret"
+	}
+	if config.KVVersion == 0 {
+		config.KVVersion = 2
+	}
+	if config.KVVersion != 1 && config.KVVersion != 2 {
+		return nil, fmt.Errorf("unsupported vault KV version %d", config.KVVersion)
+	}
+
+	return &vaultBackend{
+		config: config,
+		client: &http.Client{Timeout: vaultRequestTimeout},
+	}, nil
+}
+
+// ReadKey reads the key of the secret <mount>/<namespace>/<path> of the KV secrets engine
+func (b *vaultBackend) ReadKey(ctx context.Context, namespace, path, key string) (string, error) {
+	secretPath, err := namespacedSecretPath(namespace, path)
+	if err != nil {
+		return "", err
+	}
+
+	body, status, err := b.request(ctx, http.MethodGet, b.secretPath(secretPath), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s from vault: %w", path, err)
+	}
+	if status == http.StatusNotFound {
+		return "", fmt.Errorf("secret %s not found in vault", path)
+	}
+
+	data, err := b.secretData(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse secret %s from vault: %w", path, err)
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found in secret %s", key, path)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	// non-string values are returned as JSON, e.g. numbers and nested objects
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode key %s of secret %s: %w", key, path, err)
+	}
+	return string(raw), nil
+}
+
+// request sends a request to the Vault API and returns the response body and status.
+// Error statuses other than 404 Not Found are returned as an error.
+func (b *vaultBackend) request(ctx context.Context, method, apiPath string, payload interface{}) ([]byte, int, error) {
+	token, err := b.token()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var reqBody io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, 0, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(b.config.Address, "/")+"/v1/"+apiPath, reqBody)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if b.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", b.config.Namespace)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest && resp.StatusCode != http.StatusNotFound {
+		var errResp struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.Unmarshal(body, &errResp)
+		return nil, resp.StatusCode, fmt.Errorf("%s %s", resp.Status, strings.Join(errResp.Errors, ", "))
+	}
+	return body, resp.StatusCode, nil
+}
+
+// secretPath returns the API path of the secret, which contains /data/ for version 2 of the KV secrets engine
+func (b *vaultBackend) secretPath(path string) string {
+	segments := []string{escapePath(b.config.Mount)}
+	if b.config.KVVersion == 2 {
+		segments = append(segments, "data")
+	}
+	return strings.Join(append(segments, escapePath(path)), "/")
+}
+
+// escapePath escapes every segment of the path, so the path cannot change the query or fragment of a request
+// or smuggle in segments that Vault would decode
+func escapePath(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// secretData returns the key-value pairs of a secret, which version 2 of the KV secrets engine nests with its metadata
+func (b *vaultBackend) secretData(body []byte) (map[string]interface{}, error) {
+	if b.config.KVVersion == 1 {
+		var resp struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		err := json.Unmarshal(body, &resp)
+		return resp.Data, err
+	}
+
+	var resp struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	err := json.Unmarshal(body, &resp)
+	return resp.Data.Data, err
+}
+
+func (b *vaultBackend) token() (string, error) {
+	if b.config.TokenFile == "" {
+		if token := os.Getenv("VAULT_TOKEN"); token != "" {
+			return token, nil
+		}
+		return "", errors.New("vault secret backend requires a token file or VAULT_TOKEN")
+	}
+
+	data, err := ioutil.ReadFile(b.config.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("unable to read vault token: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
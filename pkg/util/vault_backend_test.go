@@ -0,0 +1,134 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Vault SecretBackend", func() {
+	var server *httptest.Server
+	var requests []*http.Request
+	var tokenFile string
+
+	BeforeEach(func() {
+		requests = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+			if r.Header.Get("X-Vault-Token") != "dev-token" {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+				return
+			}
+
+			data := map[string]interface{}{"password": "s3cr3t", "port": 3306}
+			var body interface{}
+			switch r.URL.Path {
+			case "/v1/secret/data/default/mysql":
+				body = map[string]interface{}{"data": map[string]interface{}{"data": data, "metadata": map[string]interface{}{"version": 1}}}
+			case "/v1/kv/default/mysql":
+				body = map[string]interface{}{"data": data}
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"errors":[]}`))
+				return
+			}
+			_ = json.NewEncoder(w).Encode(body)
+		}))
+
+		dir, err := ioutil.TempDir("", "vault")
+		Expect(err).To(Succeed())
+		tokenFile = filepath.Join(dir, "token")
+		Expect(ioutil.WriteFile(tokenFile, []byte("dev-token\n"), 0600)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		server.Close()
+		Expect(os.RemoveAll(filepath.Dir(tokenFile))).To(Succeed())
+	})
+
+	It("Should read a key from version 2 of the KV secrets engine", func() {
+		backend, err := NewVaultBackend(VaultConfig{Address: server.URL, TokenFile: tokenFile, Namespace: "etl"})
+		Expect(err).To(Succeed())
+
+		value, err := backend.ReadKey(context.Background(), "default", "mysql", "password")
+		Expect(err).To(Succeed())
+		Expect(value).To(Equal("s3cr3t"))
+		Expect(requests[0].Header.Get("X-Vault-Namespace")).To(Equal("etl"))
+
+		value, err = backend.ReadKey(context.Background(), "default", "mysql", "port")
+		Expect(err).To(Succeed())
+		Expect(value).To(Equal("3306"))
+	})
+
+	It("Should read a key from version 1 of the KV secrets engine", func() {
+		backend, err := NewVaultBackend(VaultConfig{Address: server.URL, TokenFile: tokenFile, Mount: "kv", KVVersion: 1})
+		Expect(err).To(Succeed())
+
+		value, err := backend.ReadKey(context.Background(), "default", "mysql/", "password")
+		Expect(err).To(Succeed())
+		Expect(value).To(Equal("s3cr3t"))
+	})
+
+	It("Should return an error for missing secrets and keys", func() {
+		backend, err := NewVaultBackend(VaultConfig{Address: server.URL, TokenFile: tokenFile})
+		Expect(err).To(Succeed())
+
+		_, err = backend.ReadKey(context.Background(), "default", "postgres", "password")
+		Expect(err).To(MatchError("secret postgres not found in vault"))
+
+		_, err = backend.ReadKey(context.Background(), "default", "mysql", "username")
+		Expect(err).To(MatchError("key username not found in secret mysql"))
+	})
+
+	It("Should only read secrets in the directory of the namespace", func() {
+		backend, err := NewVaultBackend(VaultConfig{Address: server.URL, TokenFile: tokenFile})
+		Expect(err).To(Succeed())
+
+		_, err = backend.ReadKey(context.Background(), "other", "mysql", "password")
+		Expect(err).To(MatchError("secret mysql not found in vault"))
+		Expect(requests[0].URL.Path).To(Equal("/v1/secret/data/other/mysql"))
+
+		_, err = backend.ReadKey(context.Background(), "other", "../default/mysql", "password")
+		Expect(err).To(MatchError("invalid secret path ../default/mysql: path must not refer to a parent directory"))
+
+		_, err = backend.ReadKey(context.Background(), "other", "/default/mysql", "password")
+		Expect(err).To(MatchError("invalid secret path /default/mysql: path must be relative"))
+
+		for _, path := range []string{"x%2f..%2fdefault/mysql", "mysql?list=true", "mysql#frag", `..\default\mysql`, "team//mysql"} {
+			_, err = backend.ReadKey(context.Background(), "other", path, "password")
+			Expect(err).To(MatchError(ContainSubstring("invalid secret path " + path)))
+		}
+		Expect(requests).To(HaveLen(1))
+	})
+
+	It("Should escape every segment of the secret path", func() {
+		backend, err := NewVaultBackend(VaultConfig{Address: server.URL, TokenFile: tokenFile})
+		Expect(err).To(Succeed())
+
+		_, err = backend.ReadKey(context.Background(), "other", "team a/mysql;1", "password")
+		Expect(err).To(MatchError("secret team a/mysql;1 not found in vault"))
+		Expect(requests[0].URL.EscapedPath()).To(Equal("/v1/secret/data/other/team%20a/mysql%3B1"))
+	})
+
+	It("Should return the errors reported by Vault", func() {
+		Expect(ioutil.WriteFile(tokenFile, []byte("expired-token"), 0600)).To(Succeed())
+		backend, err := NewVaultBackend(VaultConfig{Address: server.URL, TokenFile: tokenFile})
+		Expect(err).To(Succeed())
+
+		_, err = backend.ReadKey(context.Background(), "default", "mysql", "password")
+		Expect(err).To(MatchError("failed to read secret mysql from vault: 403 Forbidden permission denied"))
+	})
+
+	It("Should reject an unsupported KV version", func() {
+		_, err := NewVaultBackend(VaultConfig{Address: server.URL, KVVersion: 3})
+		Expect(err).To(HaveOccurred())
+	})
+})